@@ -0,0 +1,71 @@
+// Command conformance runs the OCPP 1.6 conformance suite and prints a
+// pass/fail matrix. Without -url it starts the server in-process, with an
+// in-memory Redis and a fake backend; with -url it tests a running server:
+//
+//	go run ./cmd/conformance
+//	go run ./cmd/conformance -url ws://localhost:10800 -run '^RPC-'
+//
+// It exits 1 when a case fails.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/conformance"
+	"go.uber.org/zap"
+)
+
+func main() {
+	var target conformance.Target
+	flag.StringVar(&target.URL, "url", "", "central system WebSocket URL, empty starts the server in-process")
+	flag.StringVar(&target.API, "api", "", "HTTP base of /command/ (default: derived from -url)")
+	flag.StringVar(&target.Token, "token", os.Getenv("API_TOKEN"), "bearer token of /command/ (default: $API_TOKEN)")
+	flag.DurationVar(&target.Timeout, "timeout", 10*time.Second, "time limit of each case")
+	pattern := flag.String("run", "", "only run the cases whose ID matches this regexp")
+	verbose := flag.Bool("v", false, "log what the server and the chargers do")
+	jsonFile := flag.String("json", "", "also write the report as JSON to this file")
+	flag.Parse()
+
+	logger := zap.NewNop()
+	if *verbose {
+		logger, _ = zap.NewDevelopment(zap.AddStacktrace(zap.FatalLevel))
+	}
+	defer logger.Sync()
+	cases, err := conformance.Select(conformance.Cases, *pattern)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if target.URL == "" {
+		server, stopServer, err := conformance.StartServer(ctx, logger)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer stopServer()
+		server.Timeout = target.Timeout
+		target = server
+	}
+	target.Log = logger
+
+	report := conformance.Run(ctx, target, cases)
+	report.WriteText(os.Stdout)
+	if *jsonFile != "" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		if err := os.WriteFile(*jsonFile, data, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,79 @@
+// Command loadtest opens many simulated charge points against a central
+// system and reports answer latency per action and event throughput:
+//
+//	go run ./cmd/loadtest -url ws://localhost:10800 -n 5000 -ramp 200 -duration 5m \
+//		-tx-duration 2m -tx-idle 1m -meter-interval 30s -redis localhost:6379
+//
+// With -backend the server's BASE_URL can point at a built-in fake backend,
+// so transactions start without the real one.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/client"
+	"github.com/JscorpTech/ocpp/internal/loadtest"
+	"github.com/JscorpTech/ocpp/internal/services"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func main() {
+	opts := loadtest.Options{Progress: os.Stderr}
+	flag.StringVar(&opts.URL, "url", "ws://localhost:10800", "central system WebSocket URL")
+	flag.IntVar(&opts.Chargers, "n", 100, "number of chargers")
+	flag.StringVar(&opts.Prefix, "prefix", "LOAD", "charger ID prefix")
+	flag.Float64Var(&opts.Ramp, "ramp", 50, "chargers connecting per second, 0 for all at once")
+	flag.DurationVar(&opts.Duration, "duration", time.Minute, "run time once every charger is connected")
+	flag.DurationVar(&opts.Heartbeat, "heartbeat", 0, "heartbeat interval (default: what the server asks for)")
+	flag.DurationVar(&opts.MeterInterval, "meter-interval", 10*time.Second, "meter values period, 0 disables")
+	flag.DurationVar(&opts.TxDuration, "tx-duration", 30*time.Second, "transaction length, 0 runs no transactions")
+	flag.DurationVar(&opts.TxIdle, "tx-idle", 30*time.Second, "pause between transactions")
+	flag.DurationVar(&opts.Timeout, "timeout", 30*time.Second, "wait for each answer")
+	flag.DurationVar(&opts.ProgressInterval, "progress", 10*time.Second, "status line period")
+	redisAddr := flag.String("redis", "", "Redis address to count events in, empty skips it")
+	flag.StringVar(&opts.EventsKey, "events-key", services.EventsKey, "events list or stream")
+	backendAddr := flag.String("backend", "", "serve a fake backend on this address, e.g. :8000")
+	jsonFile := flag.String("json", "", "also write the report as JSON to this file")
+	flag.Parse()
+
+	logger, _ := zap.NewDevelopment(zap.AddStacktrace(zap.FatalLevel), zap.IncreaseLevel(zap.WarnLevel))
+	defer logger.Sync()
+	opts.Log = logger
+	if *redisAddr != "" {
+		opts.Redis = redis.NewClient(&redis.Options{Addr: *redisAddr})
+		defer opts.Redis.Close()
+	}
+	if *backendAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(*backendAddr, &client.FakeBackend{}); err != nil {
+				fmt.Fprintln(os.Stderr, "fake backend:", err)
+				os.Exit(1)
+			}
+		}()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := loadtest.Run(ctx, opts)
+	if report != nil {
+		report.WriteText(os.Stdout)
+		if *jsonFile != "" {
+			data, _ := json.MarshalIndent(report, "", "  ")
+			if err := os.WriteFile(*jsonFile, data, 0o644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -2,32 +2,111 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/JscorpTech/ocpp/internal/client"
 	"github.com/JscorpTech/ocpp/internal/config"
 	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"github.com/JscorpTech/ocpp/internal/services"
+	"github.com/JscorpTech/ocpp/internal/tracing"
 	"github.com/joho/godotenv"
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func main() {
-	logger, _ := zap.NewProduction()
 	_ = godotenv.Load()
-	cfg := config.NewConfig()
-	ctx := context.Background()
-	rdb := redis.NewClient(&redis.Options{
-		Addr: cfg.RedisAddr,
-		DB:   0,
-	})
-	if err := rdb.Ping(ctx).Err(); err != nil {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file; environment variables override it")
+	flag.Parse()
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	level := zap.NewAtomicLevelAt(parseLevel(cfg.LogLevel))
+	logConfig := zap.NewProductionConfig()
+	logConfig.Level = level
+	logger, _ := logConfig.Build()
+	defer logger.Sync()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shutdownTracing, err := tracing.Setup(ctx, cfg)
+	if err != nil {
 		panic(err)
 	}
-	server := ocpp.NewServer(ctx, cfg, logger, rdb)
-	go func() {
-		if err := server.Run(); err != nil {
-			log.Panic(err)
+	defer func() {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFlush()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Error("Trace flush error", zap.Error(err))
 		}
 	}()
-	select {}
+	rdb, err := services.NewRedisClient(cfg)
+	if err != nil {
+		logger.Fatal("Redis setup failed", zap.Error(err))
+	}
+	defer rdb.Close()
+	tracing.InstrumentRedis(rdb)
+	// Start even when Redis is down; /readyz reports it until it comes back.
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		logger.Error("Redis not reachable", zap.Error(err))
+	}
+	server := ocpp.NewServer(ctx, cfg, logger, rdb, client.NewTransactionClient(cfg))
+
+	signals, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Run()
+	}()
+	for running := true; running; {
+		select {
+		case err := <-errc:
+			if err != nil {
+				log.Panic(err)
+			}
+			return
+		case <-reload:
+			next, err := config.Load(*configFile)
+			if err != nil {
+				logger.Error("Config reload failed", zap.Error(err))
+				continue
+			}
+			restart := server.Reload(next)
+			level.SetLevel(parseLevel(next.LogLevel))
+			if len(restart) > 0 {
+				logger.Warn("Config changes need a restart", zap.Strings("settings", restart))
+			}
+			logger.Info("Config reloaded")
+		case <-signals.Done():
+			running = false
+		}
+	}
+
+	logger.Info("Shutting down", zap.Duration("drain_timeout", cfg.DrainTimeout))
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	defer cancelDrain()
+	if err := server.Shutdown(drainCtx); err != nil {
+		logger.Error("Shutdown error", zap.Error(err))
+	}
+	cancel()
+	if err := server.Close(); err != nil {
+		logger.Error("Event sink close error", zap.Error(err))
+	}
+	logger.Info("Shutdown complete")
+}
+
+// parseLevel reads a LOG_LEVEL, which config.Load has checked.
+func parseLevel(name string) zapcore.Level {
+	level, _ := zapcore.ParseLevel(name)
+	return level
 }
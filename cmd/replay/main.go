@@ -0,0 +1,51 @@
+// Command replay plays a charger's message journal back against a central
+// system to reproduce a bug:
+//
+//	go run ./cmd/replay -url ws://localhost:10800 journal/example.com%3ACP-1.jsonl
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/journal"
+	"github.com/JscorpTech/ocpp/internal/replay"
+)
+
+func main() {
+	var opts replay.Options
+	flag.StringVar(&opts.URL, "url", "ws://localhost:10800", "central system WebSocket URL")
+	flag.StringVar(&opts.ID, "id", "", "charger ID to connect as (default: from the journal)")
+	flag.StringVar(&opts.Protocol, "protocol", "", "OCPP subprotocol (default: from the journal)")
+	flag.Float64Var(&opts.Speed, "speed", 0, "pacing relative to the recording, 0 sends as fast as answered")
+	flag.DurationVar(&opts.Timeout, "timeout", 10*time.Second, "wait for each answer")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <journal.jsonl>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	entries, err := journal.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := replay.Replay(ctx, entries, opts, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if result.Mismatched > 0 || result.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,96 @@
+// Command simulator acts as one or many OCPP 1.6J charge points, to run the
+// server end to end without hardware:
+//
+//	go run ./cmd/simulator -url ws://localhost:10800 -n 10 -scenario charge
+//
+// Every charger runs the scenario, a built-in one (charge, idle) or a JSON
+// file of steps, and answers remote commands meanwhile. With -stay, or a
+// scenario that does not disconnect, chargers stay connected until
+// interrupted.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/simulator"
+	"go.uber.org/zap"
+)
+
+func main() {
+	var opts simulator.Options
+	flag.StringVar(&opts.URL, "url", "ws://localhost:10800", "central system WebSocket URL")
+	id := flag.String("id", "SIM", "charger ID, or the prefix of <id>-<n> with -n > 1")
+	n := flag.Int("n", 1, "number of chargers")
+	scenario := flag.String("scenario", "charge", "built-in scenario (charge, idle) or JSON file of steps")
+	stay := flag.Bool("stay", false, "stay connected after the scenario, answering remote commands")
+	stagger := flag.Duration("stagger", 10*time.Millisecond, "delay between charger connects")
+	flag.IntVar(&opts.Connectors, "connectors", 1, "connectors per charger")
+	flag.DurationVar(&opts.Timeout, "timeout", 30*time.Second, "wait for each answer")
+	flag.DurationVar(&opts.MeterInterval, "meter-interval", 10*time.Second, "meter values period of remotely started transactions, 0 disables")
+	flag.IntVar(&opts.MeterEnergy, "meter-energy", 100, "Wh added per meter sample")
+	flag.Parse()
+
+	steps, err := simulator.LoadScenario(*scenario)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger, _ := zap.NewDevelopment(zap.AddStacktrace(zap.FatalLevel))
+	defer logger.Sync()
+	opts.Log = logger
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var wg sync.WaitGroup
+	var failed atomic.Int64
+	for i := range *n {
+		chargerOpts := opts
+		chargerOpts.ID = *id
+		if *n > 1 {
+			chargerOpts.ID = fmt.Sprintf("%s-%04d", *id, i+1)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := run(ctx, chargerOpts, steps, *stay); err != nil && ctx.Err() == nil {
+				logger.Error("Charger failed", zap.String("cp_id", chargerOpts.ID), zap.Error(err))
+				failed.Add(1)
+			}
+		}()
+		select {
+		case <-time.After(*stagger):
+		case <-ctx.Done():
+		}
+	}
+	wg.Wait()
+	if failed.Load() > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d chargers failed\n", failed.Load(), *n)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, opts simulator.Options, steps []simulator.Step, stay bool) error {
+	cp, err := simulator.Dial(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer cp.Close()
+	if err := cp.Run(ctx, steps); err != nil {
+		return err
+	}
+	if !stay && len(steps) > 0 && steps[len(steps)-1].Action == "Disconnect" {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+	case <-cp.Done():
+	}
+	return nil
+}
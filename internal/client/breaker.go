@@ -0,0 +1,69 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/metrics"
+)
+
+// breaker fails calls fast once the backend failed threshold times in a
+// row. After cooldown it lets one call through: its success closes the
+// breaker, its failure opens it for another cooldown.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mux      sync.Mutex
+	failures int
+	// openedAt is zero while the breaker is closed.
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a call may go to the backend. A call it allows must
+// end in done.
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// done records the outcome of an allowed call. A call the caller gave up on
+// is neither: it only frees the probe.
+func (b *breaker) done(failed, abandoned bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.probing = false
+	switch {
+	case abandoned:
+	case !failed:
+		b.failures = 0
+		b.openedAt = time.Time{}
+		metrics.BackendCircuitOpen.Set(0)
+	default:
+		b.failures++
+		if !b.openedAt.IsZero() || b.failures >= b.threshold {
+			b.openedAt = b.now()
+			metrics.BackendCircuitOpen.Set(1)
+		}
+	}
+}
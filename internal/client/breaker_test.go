@@ -0,0 +1,56 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.allow()
+	b.done(true, false)
+	if !b.allow() {
+		t.Fatal("allow() = false below the threshold")
+	}
+	b.done(true, false)
+	if b.allow() {
+		t.Fatal("allow() = true after 2 failures")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Fatal("allow() = false after the cooldown")
+	}
+	if b.allow() {
+		t.Error("allow() = true for a second call while probing")
+	}
+	// An abandoned probe lets the next call probe.
+	b.done(false, true)
+	if !b.allow() {
+		t.Fatal("allow() = false after an abandoned probe")
+	}
+	b.done(true, false)
+	if b.allow() {
+		t.Error("allow() = true after a failed probe")
+	}
+
+	now = now.Add(time.Minute)
+	b.allow()
+	b.done(false, false)
+	if !b.allow() || !b.allow() {
+		t.Error("allow() = false after a successful probe")
+	}
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	b := newBreaker(0, time.Minute)
+	for range 10 {
+		b.done(true, false)
+	}
+	if !b.allow() {
+		t.Error("allow() = false with the breaker disabled")
+	}
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// FakeBackend answers the backend API the server calls, for load tests and
+// local runs without the real backend. Every tag gets a new transaction.
+type FakeBackend struct {
+	transactions atomic.Int64
+}
+
+func (f *FakeBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tag, ok := strings.CutPrefix(r.URL.Path, "/api/transaction/tag/")
+	if !ok {
+		w.Write([]byte("{}"))
+		return
+	}
+	var transaction Transaction
+	transaction.Status = true
+	transaction.Data.Id = int(f.transactions.Add(1))
+	transaction.Data.Status = "active"
+	transaction.Data.Tag = strings.TrimSuffix(tag, "/")
+	json.NewEncoder(w).Encode(&transaction)
+}
+
+// Transactions is the number of transactions handed out.
+func (f *FakeBackend) Transactions() int64 {
+	return f.transactions.Load()
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JscorpTech/ocpp/internal/config"
+)
+
+func TestFakeBackend(t *testing.T) {
+	backend := &FakeBackend{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	client := NewTransactionClient(&config.Config{BaseUrl: server.URL})
+
+	first, err := client.GetTransactionFromTag(context.Background(), "TAG-1")
+	if err != nil {
+		t.Fatalf("GetTransactionFromTag() error = %v", err)
+	}
+	second, _ := client.GetTransactionFromTag(context.Background(), "TAG-1")
+	if first.Data.Tag != "TAG-1" || first.Data.Id == second.Data.Id || backend.Transactions() != 2 {
+		t.Errorf("transactions = %+v, %+v", first, second)
+	}
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+}
@@ -1,14 +1,45 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+// maxResponseSize bounds how much of a backend answer is read.
+const maxResponseSize = 1 << 20
+
+// ErrCircuitOpen is returned without calling the backend while it is
+// considered down.
+var ErrCircuitOpen = errors.New("backend circuit open")
+
+// StatusError is a backend answer outside 2xx.
+type StatusError struct {
+	Operation  string
+	StatusCode int
+	// Body is the start of the answer, for the logs.
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("backend %s: %d %s: %s", e.Operation, e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// Temporary reports whether the same call may succeed later: 429 and 5xx.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
 type Transaction struct {
 	Status bool `json:"status"`
 	Data   struct {
@@ -19,36 +50,185 @@ type Transaction struct {
 }
 
 type TransactionClient interface {
-	GetTransactionFromTag(string) (*Transaction, error)
+	GetTransactionFromTag(ctx context.Context, tag string) (*Transaction, error)
+	// Ping checks that the backend answers HTTP at all.
+	Ping(context.Context) error
 }
 
 type transactionClient struct {
-	Client *http.Client
-	Config *config.Config
+	Client  *http.Client
+	Config  *config.Config
+	breaker *breaker
 }
 
+// NewTransactionClient calls the backend at BASE_URL. Every request is
+// bounded by BACKEND_TIMEOUT; idempotent calls are retried BACKEND_RETRIES
+// times with exponential backoff, and after BACKEND_BREAKER_THRESHOLD failed
+// calls in a row the client fails fast with ErrCircuitOpen for
+// BACKEND_BREAKER_COOLDOWN.
 func NewTransactionClient(cfg *config.Config) TransactionClient {
 	return &transactionClient{
-		Client: &http.Client{},
-		Config: cfg,
+		// The transport starts a client span and sends the trace context in
+		// the traceparent header.
+		Client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   cfg.BackendTimeout,
+		},
+		Config:  cfg,
+		breaker: newBreaker(int(cfg.BackendBreakerThreshold), cfg.BackendBreakerCooldown),
 	}
 }
 
-func (t *transactionClient) GetTransactionFromTag(tag string) (*Transaction, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/transaction/tag/%s/", t.Config.BaseUrl, tag), nil)
+func (t *transactionClient) GetTransactionFromTag(ctx context.Context, tag string) (*Transaction, error) {
+	var transaction Transaction
+	err := t.call(ctx, "get_transaction_from_tag", true, func() (*http.Request, error) {
+		return t.newRequest(ctx, http.MethodGet, "/api/transaction/tag/"+url.PathEscape(tag)+"/")
+	}, &transaction)
 	if err != nil {
 		return nil, err
 	}
+	return &transaction, nil
+}
+
+// call sends the request newRequest builds and decodes a 2xx answer into
+// out. Idempotent calls are retried on network errors, 429 and 5xx.
+func (t *transactionClient) call(ctx context.Context, operation string, idempotent bool, newRequest func() (*http.Request, error), out any) error {
+	start := time.Now()
+	defer func() {
+		metrics.BackendDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}()
+	if !t.breaker.allow() {
+		metrics.BackendErrors.WithLabelValues(operation).Inc()
+		return ErrCircuitOpen
+	}
+	attempts := 1
+	if idempotent {
+		attempts += int(max(t.Config.BackendRetries, 0))
+	}
+	var err error
+	for attempt := range attempts {
+		if attempt > 0 {
+			metrics.BackendRetries.WithLabelValues(operation).Inc()
+			if !sleep(ctx, backoff(t.Config.BackendRetryBackoff, attempt)) {
+				break
+			}
+		}
+		if err = t.send(operation, newRequest, out); !retryable(ctx, err) {
+			break
+		}
+	}
+	// A 4xx answer is the backend working as meant.
+	var status *StatusError
+	failed := err != nil && !(errors.As(err, &status) && !status.Temporary())
+	t.breaker.done(failed, ctx.Err() != nil)
+	if err != nil {
+		metrics.BackendErrors.WithLabelValues(operation).Inc()
+	}
+	return err
+}
+
+func (t *transactionClient) send(operation string, newRequest func() (*http.Request, error), out any) error {
+	req, err := newRequest()
+	if err != nil {
+		return err
+	}
 	res, err := t.Client.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer res.Body.Close()
-	body, _ := io.ReadAll(res.Body)
-	var transaction Transaction
-	if err := json.Unmarshal(body, &transaction); err != nil {
-		fmt.Println(string(body))
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &StatusError{Operation: operation, StatusCode: res.StatusCode, Body: truncate(string(body), 200)}
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("backend %s: invalid answer: %w", operation, err)
+	}
+	return nil
+}
+
+func (t *transactionClient) newRequest(ctx context.Context, method, path string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.Config.BaseUrl+path, nil)
+	if err != nil {
 		return nil, err
 	}
-	return &transaction, nil
+	if t.Config.BackendToken != "" {
+		req.Header.Set("Authorization", t.Config.BackendAuthScheme+" "+t.Config.BackendToken)
+	}
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// retryable reports whether a failed call may succeed if sent again: the
+// request failed on the way, timed out after BACKEND_TIMEOUT or got 429 or
+// 5xx, and the caller still waits.
+func retryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Temporary()
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// maxBackoff caps the wait between two attempts.
+const maxBackoff = 30 * time.Second
+
+// backoff is base doubled for each attempt after the first, up to
+// maxBackoff, with jitter.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	delay := min(base, maxBackoff)
+	for range attempt - 1 {
+		if delay >= maxBackoff/2 {
+			delay = maxBackoff
+			break
+		}
+		delay *= 2
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// sleep waits for d unless ctx ends first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func (t *transactionClient) Ping(ctx context.Context) error {
+	req, err := t.newRequest(ctx, http.MethodGet, "/")
+	if err != nil {
+		return err
+	}
+	res, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("backend returned %s", res.Status)
+	}
+	return nil
 }
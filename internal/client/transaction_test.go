@@ -1,12 +1,20 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/JscorpTech/ocpp/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func TestNewTransactionClient(t *testing.T) {
@@ -55,7 +63,7 @@ func TestTransactionClient_GetTransactionFromTag_Success(t *testing.T) {
 	}
 
 	client := NewTransactionClient(cfg)
-	transaction, err := client.GetTransactionFromTag("RFID-12345")
+	transaction, err := client.GetTransactionFromTag(context.Background(), "RFID-12345")
 
 	if err != nil {
 		t.Fatalf("GetTransactionFromTag() error = %v", err)
@@ -87,7 +95,7 @@ func TestTransactionClient_GetTransactionFromTag_InvalidJSON(t *testing.T) {
 	}
 
 	client := NewTransactionClient(cfg)
-	_, err := client.GetTransactionFromTag("test-tag")
+	_, err := client.GetTransactionFromTag(context.Background(), "test-tag")
 
 	if err == nil {
 		t.Error("Expected error for invalid JSON, got nil")
@@ -106,7 +114,7 @@ func TestTransactionClient_GetTransactionFromTag_ServerError(t *testing.T) {
 	}
 
 	client := NewTransactionClient(cfg)
-	transaction, err := client.GetTransactionFromTag("test-tag")
+	transaction, err := client.GetTransactionFromTag(context.Background(), "test-tag")
 
 	// Server xatosi bo'lsa ham, response qaytarish mumkin
 	if err != nil {
@@ -118,3 +126,181 @@ func TestTransactionClient_GetTransactionFromTag_ServerError(t *testing.T) {
 		t.Error("Expected Status to be false on server error")
 	}
 }
+
+func TestTransactionClient_Ping(t *testing.T) {
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewTransactionClient(&config.Config{BaseUrl: server.URL})
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v, want nil for a 404", err)
+	}
+	status = http.StatusBadGateway
+	if err := client.Ping(context.Background()); err == nil {
+		t.Error("Ping() expected error for a 502")
+	}
+	server.Close()
+	if err := client.Ping(context.Background()); err == nil {
+		t.Error("Ping() expected error for a closed server")
+	}
+}
+
+func TestTransactionClient_PropagatesTrace(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		json.NewEncoder(w).Encode(Transaction{Status: true})
+	}))
+	defer server.Close()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "StartTransaction")
+	defer span.End()
+	client := NewTransactionClient(&config.Config{BaseUrl: server.URL})
+	if _, err := client.GetTransactionFromTag(ctx, "RFID"); err != nil {
+		t.Fatalf("GetTransactionFromTag() error = %v", err)
+	}
+	if !strings.Contains(traceparent, span.SpanContext().TraceID().String()) {
+		t.Errorf("traceparent = %q, want trace %s", traceparent, span.SpanContext().TraceID())
+	}
+}
+
+func TestTransactionClient_Retries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(Transaction{Status: true})
+	}))
+	defer server.Close()
+
+	client := NewTransactionClient(&config.Config{
+		BaseUrl:             server.URL,
+		BackendToken:        "secret",
+		BackendAuthScheme:   "Token",
+		BackendRetries:      2,
+		BackendRetryBackoff: time.Millisecond,
+	})
+	if _, err := client.GetTransactionFromTag(context.Background(), "RFID"); err != nil {
+		t.Fatalf("GetTransactionFromTag() error = %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+
+	calls.Store(-10)
+	_, err := client.GetTransactionFromTag(context.Background(), "RFID")
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GetTransactionFromTag() error = %v, want a 503 StatusError", err)
+	}
+	if calls.Load() != -7 {
+		t.Errorf("calls = %d, want 3 attempts", calls.Load()+10)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		base    time.Duration
+		attempt int
+		max     time.Duration
+	}{
+		{100 * time.Millisecond, 1, 100 * time.Millisecond},
+		{100 * time.Millisecond, 3, 400 * time.Millisecond},
+		{time.Second, 100, maxBackoff},
+		{time.Hour, 1, maxBackoff},
+		{0, 2, 0},
+		{-time.Second, 2, 0},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.base, tt.attempt); got < tt.max/2 || got > tt.max {
+			t.Errorf("backoff(%v, %d) = %v, want between %v and %v", tt.base, tt.attempt, got, tt.max/2, tt.max)
+		}
+	}
+}
+
+func TestTransactionClient_StatusError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"detail":"Not found."}`))
+	}))
+	defer server.Close()
+
+	client := NewTransactionClient(&config.Config{BaseUrl: server.URL, BackendRetries: 2, BackendBreakerThreshold: 1, BackendBreakerCooldown: time.Minute})
+	for range 2 {
+		_, err := client.GetTransactionFromTag(context.Background(), "RFID")
+		var status *StatusError
+		if !errors.As(err, &status) || status.StatusCode != http.StatusNotFound || status.Temporary() || !strings.Contains(status.Body, "Not found") {
+			t.Fatalf("GetTransactionFromTag() error = %v, want a 404 StatusError", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2: no retries and no open breaker on 404", calls.Load())
+	}
+}
+
+func TestTransactionClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewTransactionClient(&config.Config{BaseUrl: server.URL, BackendTimeout: 50 * time.Millisecond})
+	start := time.Now()
+	if _, err := client.GetTransactionFromTag(context.Background(), "RFID"); err == nil {
+		t.Fatal("GetTransactionFromTag() returned no error from a hung backend")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetTransactionFromTag() took %s, want BACKEND_TIMEOUT", elapsed)
+	}
+}
+
+func TestTransactionClient_CircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	healthy := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(Transaction{Status: true})
+	}))
+	defer server.Close()
+
+	client := NewTransactionClient(&config.Config{BaseUrl: server.URL, BackendBreakerThreshold: 2, BackendBreakerCooldown: 50 * time.Millisecond})
+	for range 2 {
+		client.GetTransactionFromTag(context.Background(), "RFID")
+	}
+	if _, err := client.GetTransactionFromTag(context.Background(), "RFID"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("GetTransactionFromTag() error = %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want the open breaker not to call the backend", calls.Load())
+	}
+
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	if _, err := client.GetTransactionFromTag(context.Background(), "RFID"); err != nil {
+		t.Fatalf("GetTransactionFromTag() error = %v after the cooldown", err)
+	}
+	if _, err := client.GetTransactionFromTag(context.Background(), "RFID"); err != nil {
+		t.Errorf("GetTransactionFromTag() error = %v, want the breaker closed", err)
+	}
+}
@@ -1,32 +1,347 @@
 package config
 
 import (
-	"os"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
 )
 
+// EventSinkNames are the sinks EVENT_SINKS can choose from.
+var EventSinkNames = []string{"redis", "webhook", "nats", "kafka", "mqtt"}
+
+// minOutboxBytes keeps OUTBOX_MAX_BYTES above the size of an event.
+const minOutboxBytes = 64 << 10
+
+// maxBackendRetries keeps the doubled backoff of BACKEND_RETRIES in reach.
+const maxBackendRetries = 10
+
+// LogLevels are the levels LOG_LEVEL can choose from.
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// Config is the server configuration. The env tag names the environment
+// variable of a setting; see Load for the file keys.
 type Config struct {
-	Addr      string
-	BaseUrl   string
-	RedisAddr string
+	Addr string `env:"ADDR"`
+	// TLSCertFile and TLSKeyFile make the listener serve wss:// and https://.
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+	// TrustedProxies are the proxies whose X-Forwarded-For is believed for
+	// the address of a charger; without them the peer address is used.
+	TrustedProxies []netip.Prefix `env:"TRUSTED_PROXIES"`
+	BaseUrl        string         `env:"BASE_URL"`
+	// BackendTimeout bounds one request to the backend API.
+	BackendTimeout time.Duration `env:"BACKEND_TIMEOUT"`
+	// BackendToken is sent as "Authorization: <BackendAuthScheme> <token>".
+	BackendToken      string `env:"BACKEND_TOKEN"`
+	BackendAuthScheme string `env:"BACKEND_AUTH_SCHEME"`
+	// BackendRetries is how many times an idempotent call is sent again,
+	// waiting BackendRetryBackoff, then twice as long each time.
+	BackendRetries      int64         `env:"BACKEND_RETRIES"`
+	BackendRetryBackoff time.Duration `env:"BACKEND_RETRY_BACKOFF"`
+	// BackendBreakerThreshold failed calls in a row make the client fail
+	// fast for BackendBreakerCooldown; 0 disables the breaker.
+	BackendBreakerThreshold int64         `env:"BACKEND_BREAKER_THRESHOLD"`
+	BackendBreakerCooldown  time.Duration `env:"BACKEND_BREAKER_COOLDOWN"`
+	RedisAddr               string        `env:"REDIS_ADDR"`
+	RedisDB                 int64         `env:"REDIS_DB"`
+	RedisUsername           string        `env:"REDIS_USERNAME"`
+	RedisPassword           string        `env:"REDIS_PASSWORD"`
+	// RedisSentinelMaster switches to Redis Sentinel: the master of that name
+	// is looked up through RedisSentinelAddrs.
+	RedisSentinelMaster   string   `env:"REDIS_SENTINEL_MASTER"`
+	RedisSentinelAddrs    []string `env:"REDIS_SENTINEL_ADDRS"`
+	RedisSentinelUsername string   `env:"REDIS_SENTINEL_USERNAME"`
+	RedisSentinelPassword string   `env:"REDIS_SENTINEL_PASSWORD"`
+	// RedisClusterAddrs switches to Redis Cluster with these seed nodes.
+	RedisClusterAddrs []string `env:"REDIS_CLUSTER_ADDRS"`
+	// RedisTLS connects over TLS, trusting RedisTLSCAFile on top of the
+	// system roots and presenting the client certificate when there is one.
+	RedisTLS           bool   `env:"REDIS_TLS"`
+	RedisTLSCAFile     string `env:"REDIS_TLS_CA_FILE"`
+	RedisTLSCertFile   string `env:"REDIS_TLS_CERT_FILE"`
+	RedisTLSKeyFile    string `env:"REDIS_TLS_KEY_FILE"`
+	RedisTLSServerName string `env:"REDIS_TLS_SERVER_NAME"`
+	// RedisPoolSize and RedisMinIdleConns size the pool of every node; 0
+	// keeps the go-redis default of 10 connections per CPU.
+	RedisPoolSize     int64         `env:"REDIS_POOL_SIZE"`
+	RedisMinIdleConns int64         `env:"REDIS_MIN_IDLE_CONNS"`
+	RedisPoolTimeout  time.Duration `env:"REDIS_POOL_TIMEOUT"`
+	RedisDialTimeout  time.Duration `env:"REDIS_DIAL_TIMEOUT"`
+	RedisReadTimeout  time.Duration `env:"REDIS_READ_TIMEOUT"`
+	RedisWriteTimeout time.Duration `env:"REDIS_WRITE_TIMEOUT"`
+	// HeartbeatInterval is sent to chargers in BootNotification.
+	HeartbeatInterval time.Duration `env:"HEARTBEAT_INTERVAL"`
+	// HeartbeatGrace is how long past the heartbeat interval a charger may
+	// stay silent before it is reported offline.
+	HeartbeatGrace time.Duration `env:"HEARTBEAT_GRACE"`
+	// InstanceID identifies this replica in the charger registry.
+	InstanceID string `env:"INSTANCE_ID"`
+	LogLevel   string `env:"LOG_LEVEL"`
+	// DrainTimeout bounds how long shutdown waits for in-flight messages.
+	DrainTimeout time.Duration `env:"DRAIN_TIMEOUT"`
+	// ReadyMaxEventBacklog marks the instance not ready once this many events
+	// wait in Redis; 0 disables the check.
+	ReadyMaxEventBacklog int64 `env:"READY_MAX_EVENT_BACKLOG"`
+	// APIToken is the bearer token of /command/, /chargers, /configuration,
+	// /journal and /profiles/drift; empty leaves them open.
+	APIToken string `env:"API_TOKEN"`
+	// TraceExporter is none, otlp, stdout or file.
+	TraceExporter string `env:"TRACE_EXPORTER"`
+	TraceFile     string `env:"TRACE_FILE"`
+	// JournalDir enables the per-charger message journal; empty disables it.
+	JournalDir      string `env:"JOURNAL_DIR"`
+	JournalMaxSize  int64  `env:"JOURNAL_MAX_SIZE"`
+	JournalMaxFiles int64  `env:"JOURNAL_MAX_FILES"`
+	// EventsTransport is "list" (RPUSH to the events list) or "stream".
+	EventsTransport    string `env:"EVENTS_TRANSPORT"`
+	EventsStream       string `env:"EVENTS_STREAM"`
+	EventsStreamMaxLen int64  `env:"EVENTS_STREAM_MAXLEN"`
+	EventsStreamGroup  string `env:"EVENTS_STREAM_GROUP"`
+	// OutboxDir keeps events a sink did not take, in a subdirectory per
+	// sink; empty disables the outbox.
+	OutboxDir      string `env:"OUTBOX_DIR"`
+	OutboxMaxBytes int64  `env:"OUTBOX_MAX_BYTES"`
+	// EventSinks are the sinks every event goes to.
+	EventSinks []string `env:"EVENT_SINKS"`
+	// SinkEvents limits a sink to some event types; a sink without an entry
+	// gets every event.
+	SinkEvents map[string][]domain.EventTypes `env:"<SINK>_EVENTS"`
+	// SinkFormats is "native" or "cloudevents" per sink.
+	SinkFormats      map[string]string `env:"<SINK>_FORMAT"`
+	EventSinkTimeout time.Duration     `env:"EVENT_SINK_TIMEOUT"`
+	WebhookURL       string            `env:"WEBHOOK_URL"`
+	WebhookSecret    string            `env:"WEBHOOK_SECRET"`
+	NatsURL          string            `env:"NATS_URL"`
+	NatsSubject      string            `env:"NATS_SUBJECT"`
+	KafkaBrokers     []string          `env:"KAFKA_BROKERS"`
+	KafkaTopic       string            `env:"KAFKA_TOPIC"`
+	MqttURL          string            `env:"MQTT_URL"`
+	MqttTopic        string            `env:"MQTT_TOPIC"`
+	MqttQoS          int64             `env:"MQTT_QOS"`
+	MqttClientID     string            `env:"MQTT_CLIENT_ID"`
+	// EventValidation checks outgoing events against their JSON Schema:
+	// "off", "warn" (log and publish anyway) or "strict" (drop them).
+	EventValidation string `env:"EVENT_VALIDATION"`
+	// LiveEventsToken is the bearer token of /events/stream; empty disables
+	// the feed.
+	LiveEventsToken  string `env:"LIVE_EVENTS_TOKEN"`
+	LiveEventsBuffer int64  `env:"LIVE_EVENTS_BUFFER"`
+	// ProfilesFile holds the configuration profiles applied to chargers
+	// after they boot; empty applies none.
+	ProfilesFile string    `env:"PROFILES_FILE"`
+	Profiles     []Profile `env:"-"`
 }
 
-func NewConfig() *Config {
-	baseUrl := os.Getenv("BASE_URL")
-	if baseUrl == "" {
-		panic("Base url is required")
+// Load reads the configuration file at path, when there is one, and the
+// environment, which overrides the file. Every problem found is reported in
+// one ValidationError.
+func Load(path string) (*Config, error) {
+	l := &loader{}
+	if path != "" {
+		if err := l.readFile(path); err != nil {
+			return nil, err
+		}
 	}
-	addr := os.Getenv("ADDR")
-	if addr == "" {
-		addr = ":10800"
+	cfg := l.config()
+	if len(l.errs) > 0 {
+		return nil, l.errs
 	}
+	return cfg, nil
+}
 
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "127.0.0.1:6379"
+func (l *loader) config() *Config {
+	baseUrl := l.str("BASE_URL", "")
+	if baseUrl == "" {
+		l.fail("BASE_URL", "is required")
+	} else if !strings.HasPrefix(baseUrl, "http://") && !strings.HasPrefix(baseUrl, "https://") {
+		l.invalid("BASE_URL", baseUrl)
+	}
+	backendRetries := l.int("BACKEND_RETRIES", 2)
+	if backendRetries < 0 || backendRetries > maxBackendRetries {
+		l.invalid("BACKEND_RETRIES", strconv.FormatInt(backendRetries, 10))
+	}
+	backendRetryBackoff := l.duration("BACKEND_RETRY_BACKOFF", 200*time.Millisecond)
+	if backendRetryBackoff <= 0 {
+		l.fail("BACKEND_RETRY_BACKOFF", "must be positive")
+	}
+	backendBreakerThreshold := l.int("BACKEND_BREAKER_THRESHOLD", 5)
+	if backendBreakerThreshold < 0 {
+		l.invalid("BACKEND_BREAKER_THRESHOLD", strconv.FormatInt(backendBreakerThreshold, 10))
+	}
+	tlsCertFile := l.str("TLS_CERT_FILE", "")
+	tlsKeyFile := l.str("TLS_KEY_FILE", "")
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		l.fail("TLS_KEY_FILE", "must be set together with "+l.name("TLS_CERT_FILE"))
+	}
+	redisSentinelMaster := l.str("REDIS_SENTINEL_MASTER", "")
+	redisSentinelAddrs := l.list("REDIS_SENTINEL_ADDRS")
+	if redisSentinelMaster != "" && len(redisSentinelAddrs) == 0 {
+		l.fail("REDIS_SENTINEL_ADDRS", "is required with "+l.name("REDIS_SENTINEL_MASTER"))
+	}
+	redisClusterAddrs := l.list("REDIS_CLUSTER_ADDRS")
+	if redisSentinelMaster != "" && len(redisClusterAddrs) > 0 {
+		l.fail("REDIS_CLUSTER_ADDRS", "cannot be used with "+l.name("REDIS_SENTINEL_MASTER"))
+	}
+	redisDB := l.int("REDIS_DB", 0)
+	if redisDB < 0 {
+		l.invalid("REDIS_DB", strconv.FormatInt(redisDB, 10))
+	} else if redisDB != 0 && len(redisClusterAddrs) > 0 {
+		l.fail("REDIS_DB", "must be 0 with Redis Cluster")
+	}
+	redisTLSCertFile := l.str("REDIS_TLS_CERT_FILE", "")
+	redisTLSKeyFile := l.str("REDIS_TLS_KEY_FILE", "")
+	if (redisTLSCertFile == "") != (redisTLSKeyFile == "") {
+		l.fail("REDIS_TLS_KEY_FILE", "must be set together with "+l.name("REDIS_TLS_CERT_FILE"))
+	}
+	redisTLS := l.bool("REDIS_TLS", false)
+	if !redisTLS && (redisTLSCertFile != "" || l.str("REDIS_TLS_CA_FILE", "") != "") {
+		l.fail("REDIS_TLS", "must be true to use a CA or client certificate")
+	}
+	redisPoolSize := l.int("REDIS_POOL_SIZE", 0)
+	if redisPoolSize < 0 {
+		l.invalid("REDIS_POOL_SIZE", strconv.FormatInt(redisPoolSize, 10))
+	}
+	heartbeatInterval := l.duration("HEARTBEAT_INTERVAL", 60*time.Second)
+	if heartbeatInterval <= 0 {
+		l.fail("HEARTBEAT_INTERVAL", "must be positive")
+	}
+	instanceID := l.str("INSTANCE_ID", "")
+	if instanceID == "" {
+		instanceID = hostname()
+	}
+	logLevel := l.oneOf("LOG_LEVEL", "info", LogLevels)
+	traceExporter := l.oneOf("TRACE_EXPORTER", "none", []string{"none", "otlp", "stdout", "file"})
+	eventsTransport := l.oneOf("EVENTS_TRANSPORT", "list", []string{"list", "stream"})
+	eventSinks := l.list("EVENT_SINKS")
+	if len(eventSinks) == 0 {
+		eventSinks = []string{"redis"}
+	}
+	sinkEvents := map[string][]domain.EventTypes{}
+	sinkFormats := map[string]string{}
+	for _, sink := range eventSinks {
+		if !slices.Contains(EventSinkNames, sink) {
+			l.invalid("EVENT_SINKS", sink)
+			continue
+		}
+		formatKey := strings.ToUpper(sink) + "_FORMAT"
+		sinkFormats[sink] = l.oneOf(formatKey, "native", []string{"native", "cloudevents"})
+		key := strings.ToUpper(sink) + "_EVENTS"
+		for _, event := range l.list(key) {
+			if !slices.Contains(domain.EventTypesAll, domain.EventTypes(event)) {
+				l.invalid(key, event)
+				continue
+			}
+			sinkEvents[sink] = append(sinkEvents[sink], domain.EventTypes(event))
+		}
+	}
+	for sink, key := range map[string]string{"webhook": "WEBHOOK_URL", "nats": "NATS_URL", "kafka": "KAFKA_BROKERS", "mqtt": "MQTT_URL"} {
+		if slices.Contains(eventSinks, sink) && l.str(key, "") == "" {
+			l.fail(key, "is required with the "+sink+" sink")
+		}
+	}
+	mqttQoS := l.int("MQTT_QOS", 1)
+	if mqttQoS < 0 || mqttQoS > 2 {
+		l.invalid("MQTT_QOS", strconv.FormatInt(mqttQoS, 10))
+	}
+	mqttClientID := l.str("MQTT_CLIENT_ID", "")
+	if mqttClientID == "" {
+		mqttClientID = "ocpp-" + instanceID
+	}
+	liveEventsBuffer := l.int("LIVE_EVENTS_BUFFER", 1000)
+	if liveEventsBuffer < 0 {
+		l.invalid("LIVE_EVENTS_BUFFER", strconv.FormatInt(liveEventsBuffer, 10))
+	}
+	outboxDir := l.str("OUTBOX_DIR", "outbox")
+	outboxMaxBytes := l.int("OUTBOX_MAX_BYTES", 1<<30)
+	if outboxDir != "" && outboxMaxBytes < minOutboxBytes {
+		l.fail("OUTBOX_MAX_BYTES", fmt.Sprintf("must be at least %d", minOutboxBytes))
+	}
+	// Webhooks are retried by the outbox alone.
+	if outboxDir == "" && slices.Contains(eventSinks, "webhook") {
+		l.fail("OUTBOX_DIR", "is required with the webhook sink")
+	}
+	profilesFile := l.str("PROFILES_FILE", "")
+	var profiles []Profile
+	if profilesFile != "" {
+		var err error
+		if profiles, err = loadProfiles(profilesFile); err != nil {
+			l.fail("PROFILES_FILE", err.Error())
+		}
 	}
 	return &Config{
-		BaseUrl:   baseUrl,
-		Addr:      addr,
-		RedisAddr: redisAddr,
+		BaseUrl:                 baseUrl,
+		Addr:                    l.str("ADDR", ":10800"),
+		TLSCertFile:             tlsCertFile,
+		TLSKeyFile:              tlsKeyFile,
+		TrustedProxies:          l.prefixes("TRUSTED_PROXIES"),
+		BackendTimeout:          l.duration("BACKEND_TIMEOUT", 10*time.Second),
+		BackendToken:            l.str("BACKEND_TOKEN", ""),
+		BackendAuthScheme:       l.str("BACKEND_AUTH_SCHEME", "Bearer"),
+		BackendRetries:          backendRetries,
+		BackendRetryBackoff:     backendRetryBackoff,
+		BackendBreakerThreshold: backendBreakerThreshold,
+		BackendBreakerCooldown:  l.duration("BACKEND_BREAKER_COOLDOWN", 30*time.Second),
+		RedisAddr:               l.str("REDIS_ADDR", "127.0.0.1:6379"),
+		RedisDB:                 redisDB,
+		RedisUsername:           l.str("REDIS_USERNAME", ""),
+		RedisPassword:           l.str("REDIS_PASSWORD", ""),
+		RedisSentinelMaster:     redisSentinelMaster,
+		RedisSentinelAddrs:      redisSentinelAddrs,
+		RedisSentinelUsername:   l.str("REDIS_SENTINEL_USERNAME", ""),
+		RedisSentinelPassword:   l.str("REDIS_SENTINEL_PASSWORD", ""),
+		RedisClusterAddrs:       redisClusterAddrs,
+		RedisTLS:                redisTLS,
+		RedisTLSCAFile:          l.str("REDIS_TLS_CA_FILE", ""),
+		RedisTLSCertFile:        redisTLSCertFile,
+		RedisTLSKeyFile:         redisTLSKeyFile,
+		RedisTLSServerName:      l.str("REDIS_TLS_SERVER_NAME", ""),
+		RedisPoolSize:           redisPoolSize,
+		RedisMinIdleConns:       l.int("REDIS_MIN_IDLE_CONNS", 0),
+		RedisPoolTimeout:        l.duration("REDIS_POOL_TIMEOUT", 4*time.Second),
+		RedisDialTimeout:        l.duration("REDIS_DIAL_TIMEOUT", 5*time.Second),
+		RedisReadTimeout:        l.duration("REDIS_READ_TIMEOUT", 3*time.Second),
+		RedisWriteTimeout:       l.duration("REDIS_WRITE_TIMEOUT", 3*time.Second),
+		HeartbeatInterval:       heartbeatInterval,
+		HeartbeatGrace:          l.duration("HEARTBEAT_GRACE", 60*time.Second),
+		InstanceID:              instanceID,
+		LogLevel:                logLevel,
+		DrainTimeout:            l.duration("DRAIN_TIMEOUT", 25*time.Second),
+		ReadyMaxEventBacklog:    l.int("READY_MAX_EVENT_BACKLOG", 10000),
+		APIToken:                l.str("API_TOKEN", ""),
+		TraceExporter:           traceExporter,
+		TraceFile:               l.str("TRACE_FILE", "traces.jsonl"),
+		JournalDir:              l.str("JOURNAL_DIR", ""),
+		JournalMaxSize:          l.int("JOURNAL_MAX_SIZE", 10<<20),
+		JournalMaxFiles:         l.int("JOURNAL_MAX_FILES", 5),
+		EventsTransport:         eventsTransport,
+		EventsStream:            l.str("EVENTS_STREAM", "events:stream"),
+		EventsStreamMaxLen:      l.int("EVENTS_STREAM_MAXLEN", 1000000),
+		EventsStreamGroup:       l.str("EVENTS_STREAM_GROUP", "backend"),
+		OutboxDir:               outboxDir,
+		OutboxMaxBytes:          outboxMaxBytes,
+		EventSinks:              eventSinks,
+		SinkEvents:              sinkEvents,
+		SinkFormats:             sinkFormats,
+		EventSinkTimeout:        l.duration("EVENT_SINK_TIMEOUT", 10*time.Second),
+		WebhookURL:              l.str("WEBHOOK_URL", ""),
+		WebhookSecret:           l.str("WEBHOOK_SECRET", ""),
+		NatsURL:                 l.str("NATS_URL", ""),
+		NatsSubject:             l.str("NATS_SUBJECT", "ocpp.events"),
+		KafkaBrokers:            l.list("KAFKA_BROKERS"),
+		KafkaTopic:              l.str("KAFKA_TOPIC", "ocpp.events"),
+		MqttURL:                 l.str("MQTT_URL", ""),
+		MqttTopic:               l.str("MQTT_TOPIC", "ocpp/events"),
+		MqttQoS:                 mqttQoS,
+		MqttClientID:            mqttClientID,
+		EventValidation:         l.oneOf("EVENT_VALIDATION", "warn", []string{"off", "warn", "strict"}),
+		LiveEventsToken:         l.str("LIVE_EVENTS_TOKEN", ""),
+		LiveEventsBuffer:        liveEventsBuffer,
+		ProfilesFile:            profilesFile,
+		Profiles:                profiles,
 	}
 }
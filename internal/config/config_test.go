@@ -1,37 +1,44 @@
 package config
 
 import (
+	"errors"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestNewConfig(t *testing.T) {
+func TestLoad(t *testing.T) {
 	tests := []struct {
-		name      string
-		baseURL   string
-		addr      string
-		wantPanic bool
-		wantAddr  string
+		name     string
+		baseURL  string
+		addr     string
+		wantErr  bool
+		wantAddr string
 	}{
 		{
-			name:      "valid config with custom addr",
-			baseURL:   "http://localhost:8000",
-			addr:      ":8080",
-			wantPanic: false,
-			wantAddr:  ":8080",
+			name:     "valid config with custom addr",
+			baseURL:  "http://localhost:8000",
+			addr:     ":8080",
+			wantErr:  false,
+			wantAddr: ":8080",
 		},
 		{
-			name:      "valid config with default addr",
-			baseURL:   "http://localhost:8000",
-			addr:      "",
-			wantPanic: false,
-			wantAddr:  ":10800",
+			name:     "valid config with default addr",
+			baseURL:  "http://localhost:8000",
+			addr:     "",
+			wantErr:  false,
+			wantAddr: ":10800",
 		},
 		{
-			name:      "missing base url",
-			baseURL:   "",
-			addr:      ":8080",
-			wantPanic: true,
+			name:    "missing base url",
+			baseURL: "",
+			addr:    ":8080",
+			wantErr: true,
 		},
 	}
 
@@ -45,24 +52,463 @@ func TestNewConfig(t *testing.T) {
 				os.Unsetenv("ADDR")
 			}()
 
-			if tt.wantPanic {
-				defer func() {
-					if r := recover(); r == nil {
-						t.Errorf("NewConfig() should panic but didn't")
-					}
-				}()
+			cfg, err := Load("")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Load() should fail but didn't")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.BaseUrl != tt.baseURL {
+				t.Errorf("BaseUrl = %v, want %v", cfg.BaseUrl, tt.baseURL)
+			}
+			if cfg.Addr != tt.wantAddr {
+				t.Errorf("Addr = %v, want %v", cfg.Addr, tt.wantAddr)
 			}
+		})
+	}
+}
 
-			cfg := NewConfig()
+func TestLoader_Duration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 60 * time.Second},
+		{"90", 90 * time.Second},
+		{"2m", 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			os.Setenv("HEARTBEAT_GRACE", tt.value)
+			defer os.Unsetenv("HEARTBEAT_GRACE")
+			if got := (&loader{}).duration("HEARTBEAT_GRACE", 60*time.Second); got != tt.want {
+				t.Errorf("duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
 
-			if !tt.wantPanic {
-				if cfg.BaseUrl != tt.baseURL {
-					t.Errorf("BaseUrl = %v, want %v", cfg.BaseUrl, tt.baseURL)
-				}
-				if cfg.Addr != tt.wantAddr {
-					t.Errorf("Addr = %v, want %v", cfg.Addr, tt.wantAddr)
-				}
+func TestLoader_Int(t *testing.T) {
+	os.Setenv("MQTT_QOS", "42")
+	defer os.Unsetenv("MQTT_QOS")
+	l := &loader{}
+	if got := l.int("MQTT_QOS", 7); got != 42 {
+		t.Errorf("int() = %v, want 42", got)
+	}
+	if got := l.int("REDIS_DB", 7); got != 7 {
+		t.Errorf("int() = %v, want 7", got)
+	}
+	os.Setenv("MQTT_QOS", "high")
+	if l.int("MQTT_QOS", 7); len(l.errs) != 1 || !strings.Contains(l.errs[0], `sinks.mqtt.qos (MQTT_QOS): invalid value "high"`) {
+		t.Errorf("errs = %v", l.errs)
+	}
+}
+
+func TestLoad_EventsTransport(t *testing.T) {
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	defer os.Unsetenv("BASE_URL")
+
+	cfg, _ := Load("")
+	if cfg.EventsTransport != "list" || cfg.EventsStream != "events:stream" || cfg.EventsStreamGroup != "backend" {
+		t.Errorf("defaults = %q %q %q", cfg.EventsTransport, cfg.EventsStream, cfg.EventsStreamGroup)
+	}
+
+	os.Setenv("EVENTS_STREAM_GROUP", "")
+	defer os.Unsetenv("EVENTS_STREAM_GROUP")
+	if cfg, _ := Load(""); cfg.EventsStreamGroup != "" {
+		t.Errorf("EventsStreamGroup = %q, want empty when set empty", cfg.EventsStreamGroup)
+	}
+
+	os.Setenv("EVENTS_TRANSPORT", "kafka")
+	defer os.Unsetenv("EVENTS_TRANSPORT")
+	if _, err := Load(""); err == nil {
+		t.Error("Load() should fail on an unknown transport")
+	}
+}
+
+func TestLoad_EventSinks(t *testing.T) {
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	defer os.Unsetenv("BASE_URL")
+
+	if cfg, _ := Load(""); len(cfg.EventSinks) != 1 || cfg.EventSinks[0] != "redis" {
+		t.Errorf("EventSinks = %v, want [redis]", cfg.EventSinks)
+	}
+
+	os.Setenv("EVENT_SINKS", "redis, webhook")
+	os.Setenv("WEBHOOK_URL", "http://localhost:9000/events")
+	os.Setenv("WEBHOOK_EVENTS", "start_transaction,stop_transaction")
+	defer os.Unsetenv("EVENT_SINKS")
+	defer os.Unsetenv("WEBHOOK_URL")
+	defer os.Unsetenv("WEBHOOK_EVENTS")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.EventSinks) != 2 || cfg.EventSinks[1] != "webhook" {
+		t.Errorf("EventSinks = %v", cfg.EventSinks)
+	}
+	if len(cfg.SinkEvents["webhook"]) != 2 || len(cfg.SinkEvents["redis"]) != 0 {
+		t.Errorf("SinkEvents = %v", cfg.SinkEvents)
+	}
+	if cfg.SinkFormats["webhook"] != "native" {
+		t.Errorf("SinkFormats = %v, want native by default", cfg.SinkFormats)
+	}
+
+	for key, value := range map[string]string{
+		"EVENT_SINKS":    "redis,smtp",
+		"WEBHOOK_EVENTS": "unknown_event",
+		"WEBHOOK_URL":    "",
+		"WEBHOOK_FORMAT": "xml",
+	} {
+		t.Run(key, func(t *testing.T) {
+			old := os.Getenv(key)
+			os.Setenv(key, value)
+			defer os.Setenv(key, old)
+			if _, err := Load(""); err == nil {
+				t.Errorf("Load() should fail on %s=%q", key, value)
 			}
 		})
 	}
 }
+
+func TestLoad_EventValidation(t *testing.T) {
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	defer os.Unsetenv("BASE_URL")
+
+	if cfg, _ := Load(""); cfg.EventValidation != "warn" {
+		t.Errorf("EventValidation = %q, want warn by default", cfg.EventValidation)
+	}
+
+	os.Setenv("EVENT_VALIDATION", "loose")
+	defer os.Unsetenv("EVENT_VALIDATION")
+	if _, err := Load(""); err == nil {
+		t.Error("Load() should fail on an unknown mode")
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_File(t *testing.T) {
+	path := writeConfig(t, `
+listen:
+  addr: ":9000"
+backend:
+  base_url: http://backend:8000
+  timeout: 3s
+redis:
+  db: 2
+  password: secret
+  sentinel:
+    master: mymaster
+    addrs: [sentinel-1:26379, sentinel-2:26379]
+heartbeat:
+  interval: 300
+instance_id:
+events:
+  sinks: [redis, webhook]
+  stream_group: ""
+sinks:
+  webhook:
+    url: http://hooks:9000
+    events: [start_transaction]
+    format: cloudevents
+`)
+	os.Setenv("HEARTBEAT_INTERVAL", "120")
+	os.Setenv("INSTANCE_ID", "")
+	defer os.Unsetenv("HEARTBEAT_INTERVAL")
+	defer os.Unsetenv("INSTANCE_ID")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Addr != ":9000" || cfg.BaseUrl != "http://backend:8000" || cfg.BackendTimeout != 3*time.Second {
+		t.Errorf("listen/backend = %q %q %s", cfg.Addr, cfg.BaseUrl, cfg.BackendTimeout)
+	}
+	if cfg.RedisDB != 2 || cfg.RedisPassword != "secret" || cfg.RedisSentinelMaster != "mymaster" ||
+		!slices.Equal(cfg.RedisSentinelAddrs, []string{"sentinel-1:26379", "sentinel-2:26379"}) {
+		t.Errorf("redis = %d %q %q %v", cfg.RedisDB, cfg.RedisPassword, cfg.RedisSentinelMaster, cfg.RedisSentinelAddrs)
+	}
+	if cfg.HeartbeatInterval != 120*time.Second {
+		t.Errorf("HeartbeatInterval = %s, want the environment to win", cfg.HeartbeatInterval)
+	}
+	if cfg.InstanceID == "" {
+		t.Error("InstanceID empty, want the hostname")
+	}
+	if cfg.EventsStreamGroup != "" {
+		t.Errorf("EventsStreamGroup = %q, want empty when set empty", cfg.EventsStreamGroup)
+	}
+	if cfg.SinkFormats["webhook"] != "cloudevents" || len(cfg.SinkEvents["webhook"]) != 1 {
+		t.Errorf("webhook = %v %v", cfg.SinkFormats, cfg.SinkEvents)
+	}
+}
+
+func TestLoad_Example(t *testing.T) {
+	if _, err := Load("../../config.example.yaml"); err != nil {
+		t.Errorf("Load(config.example.yaml) error = %v", err)
+	}
+}
+
+func TestLoad_Redis(t *testing.T) {
+	path := writeConfig(t, `
+backend:
+  base_url: http://backend:8000
+redis:
+  db: 1
+  sentinel:
+    master: mymaster
+  cluster:
+    addrs: [node-1:7000]
+  tls:
+    ca_file: ca.pem
+  pool:
+    size: -1
+`)
+	_, err := Load(path)
+	var invalid ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Load() error = %v, want a ValidationError", err)
+	}
+	want := []string{
+		"redis.sentinel.addrs (REDIS_SENTINEL_ADDRS): is required with redis.sentinel.master (REDIS_SENTINEL_MASTER)",
+		"redis.cluster.addrs (REDIS_CLUSTER_ADDRS): cannot be used with redis.sentinel.master (REDIS_SENTINEL_MASTER)",
+		"redis.db (REDIS_DB): must be 0 with Redis Cluster",
+		"redis.tls.enabled (REDIS_TLS): must be true to use a CA or client certificate",
+		`redis.pool.size (REDIS_POOL_SIZE): invalid value "-1"`,
+	}
+	if !slices.Equal(invalid, want) {
+		t.Errorf("errors = %q, want %q", invalid, want)
+	}
+
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	os.Setenv("REDIS_TLS", "true")
+	os.Setenv("REDIS_READ_TIMEOUT", "500ms")
+	defer os.Unsetenv("BASE_URL")
+	defer os.Unsetenv("REDIS_TLS")
+	defer os.Unsetenv("REDIS_READ_TIMEOUT")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.RedisTLS || cfg.RedisReadTimeout != 500*time.Millisecond || cfg.RedisDialTimeout != 5*time.Second {
+		t.Errorf("redis = %v %s %s", cfg.RedisTLS, cfg.RedisReadTimeout, cfg.RedisDialTimeout)
+	}
+}
+
+func TestLoad_FileErrors(t *testing.T) {
+	path := writeConfig(t, `
+backend:
+  base_url: localhost:8000
+heartbeat:
+  interval: soon
+listen:
+  tls_cert_file: cert.pem
+  port: 10800
+`)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "listen.port: unknown setting") {
+		t.Errorf("Load() error = %v, want the unknown key", err)
+	}
+
+	path = writeConfig(t, `
+backend:
+  base_url: localhost:8000
+heartbeat:
+  interval: soon
+listen:
+  tls_cert_file: cert.pem
+`)
+	_, err := Load(path)
+	var invalid ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Load() error = %v, want a ValidationError", err)
+	}
+	want := []string{
+		`backend.base_url (BASE_URL): invalid value "localhost:8000"`,
+		`listen.tls_key_file (TLS_KEY_FILE): must be set together with listen.tls_cert_file (TLS_CERT_FILE)`,
+		`heartbeat.interval (HEARTBEAT_INTERVAL): invalid value "soon"`,
+	}
+	if !slices.Equal(invalid, want) {
+		t.Errorf("errors = %q, want %q", invalid, want)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load() accepted a missing file")
+	}
+}
+
+func TestConfig_Reload(t *testing.T) {
+	current := &Config{Addr: ":10800", HeartbeatInterval: time.Minute, LogLevel: "info", LiveEventsToken: "a"}
+	next := &Config{Addr: ":9000", HeartbeatInterval: 2 * time.Minute, LogLevel: "debug", LiveEventsToken: "b", RedisDB: 3}
+
+	merged, restart := current.Reload(next)
+	if merged.HeartbeatInterval != 2*time.Minute || merged.LogLevel != "debug" || merged.LiveEventsToken != "b" {
+		t.Errorf("merged = %+v, want the reloadable settings of next", merged)
+	}
+	if merged.Addr != ":10800" || merged.RedisDB != 0 {
+		t.Errorf("merged = %+v, want the other settings kept", merged)
+	}
+	if !slices.Equal(restart, []string{"ADDR", "REDIS_DB"}) {
+		t.Errorf("restart = %v, want ADDR, REDIS_DB", restart)
+	}
+	if current.HeartbeatInterval != time.Minute {
+		t.Error("Reload() changed the current config")
+	}
+
+	next = &Config{Addr: ":10800", HeartbeatInterval: time.Minute, LogLevel: "info"}
+	if _, restart := current.Reload(next); !slices.Equal(restart, []string{"LIVE_EVENTS_TOKEN"}) {
+		t.Errorf("restart = %v, want LIVE_EVENTS_TOKEN when the feed is turned off", restart)
+	}
+
+	current = &Config{ProfilesFile: "a.yaml", Profiles: []Profile{{Name: "a"}}}
+	next = &Config{ProfilesFile: "b.yaml", Profiles: []Profile{{Name: "b"}}}
+	if merged, restart := current.Reload(next); len(restart) != 0 || merged.Profiles[0].Name != "b" {
+		t.Errorf("Reload() = %+v, %v, want the profiles taken over", merged.Profiles, restart)
+	}
+}
+
+func TestConfig_EnvTags(t *testing.T) {
+	seen := map[string]string{}
+	fields := reflect.TypeFor[Config]()
+	for i := range fields.NumField() {
+		field := fields.Field(i)
+		env := field.Tag.Get("env")
+		if env == "" {
+			t.Errorf("%s has no env tag", field.Name)
+		}
+		if other, ok := seen[env]; ok && env != "-" {
+			t.Errorf("%s and %s share the env tag %s", other, field.Name, env)
+		}
+		seen[env] = field.Name
+	}
+}
+
+func TestLoad_Profiles(t *testing.T) {
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	os.Setenv("PROFILES_FILE", "../../profiles.example.yaml")
+	defer os.Unsetenv("BASE_URL")
+	defer os.Unsetenv("PROFILES_FILE")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Profiles) != 3 {
+		t.Fatalf("profiles = %+v, want the three examples", cfg.Profiles)
+	}
+	if got := cfg.Profiles[0].Settings["MeterValuesSampledData"]; got != "Energy.Active.Import.Register,Power.Active.Import,SoC" {
+		t.Errorf("list setting = %q", got)
+	}
+	if got := cfg.Profiles[0].Settings["StopTransactionOnEVSideDisconnect"]; got != "true" {
+		t.Errorf("bool setting = %q", got)
+	}
+
+	settings, names := DesiredSettings(cfg.Profiles, "depot.example.com:CP-1", "abb", "terra ac")
+	if !slices.Equal(names, []string{"default", "abb-terra", "depot"}) {
+		t.Errorf("profiles = %v, want all three", names)
+	}
+	if settings["MeterValueSampleInterval"] != "30" || settings["LocalAuthorizeOffline"] != "false" {
+		t.Errorf("settings = %v, want the later profiles to win", settings)
+	}
+	if _, names := DesiredSettings(cfg.Profiles, "other.example.com:CP-1", "Simulator", "SIM-1"); !slices.Equal(names, []string{"default"}) {
+		t.Errorf("profiles = %v, want only default", names)
+	}
+
+	for content, want := range map[string]string{
+		"profiles:\n  - settings: {A: 1}\n": "profile 1: name is required",
+		"profiles:\n  - name: a\n":          "profile a: no settings",
+		"profiles:\n  - {name: a, settings: {A: 1}}\n  - {name: a, settings: {B: 1}}\n": "profile a: defined twice",
+		"profiles:\n  - {name: a, model: X, settings: {A: 1}}\n":                        "field model not found",
+		"profiles:\n  - {name: a, settings: {A: {b: 1}}}\n":                             "profile a: A: want a value or a list",
+	} {
+		os.Setenv("PROFILES_FILE", writeConfig(t, content))
+		if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "profiles.file (PROFILES_FILE): ") || !strings.Contains(err.Error(), want) {
+			t.Errorf("Load(%q) error = %v, want %q", content, err, want)
+		}
+	}
+}
+
+func TestLoad_OutboxMaxBytes(t *testing.T) {
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	os.Setenv("OUTBOX_MAX_BYTES", "0")
+	defer os.Unsetenv("BASE_URL")
+	defer os.Unsetenv("OUTBOX_MAX_BYTES")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "events.outbox_max_bytes (OUTBOX_MAX_BYTES): must be at least 65536") {
+		t.Errorf("Load() error = %v, want OUTBOX_MAX_BYTES rejected", err)
+	}
+	os.Setenv("OUTBOX_DIR", "")
+	defer os.Unsetenv("OUTBOX_DIR")
+	if _, err := Load(""); err != nil {
+		t.Errorf("Load() error = %v, want no limit without an outbox", err)
+	}
+}
+
+func TestLoad_TrustedProxies(t *testing.T) {
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.5")
+	defer os.Unsetenv("BASE_URL")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.5/32")}
+	if !slices.Equal(cfg.TrustedProxies, want) {
+		t.Errorf("TrustedProxies = %v, want %v", cfg.TrustedProxies, want)
+	}
+	os.Setenv("TRUSTED_PROXIES", "proxy.local")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "TRUSTED_PROXIES") {
+		t.Errorf("Load() error = %v, want TRUSTED_PROXIES rejected", err)
+	}
+}
+
+func TestLoad_LiveEventsBuffer(t *testing.T) {
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	os.Setenv("LIVE_EVENTS_BUFFER", "-1")
+	defer os.Unsetenv("BASE_URL")
+	defer os.Unsetenv("LIVE_EVENTS_BUFFER")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "LIVE_EVENTS_BUFFER") {
+		t.Errorf("Load() error = %v, want LIVE_EVENTS_BUFFER rejected", err)
+	}
+}
+
+func TestLoad_BackendRetries(t *testing.T) {
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	defer os.Unsetenv("BASE_URL")
+	defer os.Unsetenv("BACKEND_RETRIES")
+	defer os.Unsetenv("BACKEND_RETRY_BACKOFF")
+	for env, value := range map[string]string{"BACKEND_RETRIES": "11", "BACKEND_RETRY_BACKOFF": "-1s"} {
+		os.Setenv(env, value)
+		if _, err := Load(""); err == nil || !strings.Contains(err.Error(), env) {
+			t.Errorf("Load() error = %v, want %s=%s rejected", err, env, value)
+		}
+		os.Unsetenv(env)
+	}
+}
+
+func TestLoad_WebhookNeedsOutbox(t *testing.T) {
+	os.Setenv("BASE_URL", "http://localhost:8000")
+	os.Setenv("EVENT_SINKS", "redis,webhook")
+	os.Setenv("WEBHOOK_URL", "http://backend/events")
+	os.Setenv("OUTBOX_DIR", "")
+	defer os.Unsetenv("BASE_URL")
+	defer os.Unsetenv("EVENT_SINKS")
+	defer os.Unsetenv("WEBHOOK_URL")
+	defer os.Unsetenv("OUTBOX_DIR")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "OUTBOX_DIR): is required with the webhook sink") {
+		t.Errorf("Load() error = %v, want OUTBOX_DIR required", err)
+	}
+	os.Setenv("EVENT_SINKS", "redis")
+	if _, err := Load(""); err != nil {
+		t.Errorf("Load() error = %v, want no outbox needed without the webhook sink", err)
+	}
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileKeys maps each environment variable to its key in the configuration
+// file, where dots separate the sections.
+var fileKeys = map[string]string{
+	"ADDR":                      "listen.addr",
+	"TLS_CERT_FILE":             "listen.tls_cert_file",
+	"TLS_KEY_FILE":              "listen.tls_key_file",
+	"TRUSTED_PROXIES":           "listen.trusted_proxies",
+	"BASE_URL":                  "backend.base_url",
+	"BACKEND_TIMEOUT":           "backend.timeout",
+	"BACKEND_TOKEN":             "backend.token",
+	"BACKEND_AUTH_SCHEME":       "backend.auth_scheme",
+	"BACKEND_RETRIES":           "backend.retries",
+	"BACKEND_RETRY_BACKOFF":     "backend.retry_backoff",
+	"BACKEND_BREAKER_THRESHOLD": "backend.breaker.threshold",
+	"BACKEND_BREAKER_COOLDOWN":  "backend.breaker.cooldown",
+	"REDIS_ADDR":                "redis.addr",
+	"REDIS_DB":                  "redis.db",
+	"REDIS_USERNAME":            "redis.username",
+	"REDIS_PASSWORD":            "redis.password",
+	"REDIS_SENTINEL_MASTER":     "redis.sentinel.master",
+	"REDIS_SENTINEL_ADDRS":      "redis.sentinel.addrs",
+	"REDIS_SENTINEL_USERNAME":   "redis.sentinel.username",
+	"REDIS_SENTINEL_PASSWORD":   "redis.sentinel.password",
+	"REDIS_CLUSTER_ADDRS":       "redis.cluster.addrs",
+	"REDIS_TLS":                 "redis.tls.enabled",
+	"REDIS_TLS_CA_FILE":         "redis.tls.ca_file",
+	"REDIS_TLS_CERT_FILE":       "redis.tls.cert_file",
+	"REDIS_TLS_KEY_FILE":        "redis.tls.key_file",
+	"REDIS_TLS_SERVER_NAME":     "redis.tls.server_name",
+	"REDIS_POOL_SIZE":           "redis.pool.size",
+	"REDIS_MIN_IDLE_CONNS":      "redis.pool.min_idle_conns",
+	"REDIS_POOL_TIMEOUT":        "redis.pool.timeout",
+	"REDIS_DIAL_TIMEOUT":        "redis.dial_timeout",
+	"REDIS_READ_TIMEOUT":        "redis.read_timeout",
+	"REDIS_WRITE_TIMEOUT":       "redis.write_timeout",
+	"HEARTBEAT_INTERVAL":        "heartbeat.interval",
+	"HEARTBEAT_GRACE":           "heartbeat.grace",
+	"INSTANCE_ID":               "instance_id",
+	"LOG_LEVEL":                 "log_level",
+	"DRAIN_TIMEOUT":             "drain_timeout",
+	"READY_MAX_EVENT_BACKLOG":   "ready.max_event_backlog",
+	"API_TOKEN":                 "auth.api_token",
+	"LIVE_EVENTS_TOKEN":         "auth.live_events_token",
+	"TRACE_EXPORTER":            "tracing.exporter",
+	"TRACE_FILE":                "tracing.file",
+	"JOURNAL_DIR":               "journal.dir",
+	"JOURNAL_MAX_SIZE":          "journal.max_size",
+	"JOURNAL_MAX_FILES":         "journal.max_files",
+	"EVENTS_TRANSPORT":          "events.transport",
+	"EVENTS_STREAM":             "events.stream",
+	"EVENTS_STREAM_MAXLEN":      "events.stream_maxlen",
+	"EVENTS_STREAM_GROUP":       "events.stream_group",
+	"EVENT_SINKS":               "events.sinks",
+	"EVENT_SINK_TIMEOUT":        "events.sink_timeout",
+	"EVENT_VALIDATION":          "events.validation",
+	"OUTBOX_DIR":                "events.outbox_dir",
+	"OUTBOX_MAX_BYTES":          "events.outbox_max_bytes",
+	"LIVE_EVENTS_BUFFER":        "events.live_buffer",
+	"WEBHOOK_URL":               "sinks.webhook.url",
+	"WEBHOOK_SECRET":            "sinks.webhook.secret",
+	"NATS_URL":                  "sinks.nats.url",
+	"NATS_SUBJECT":              "sinks.nats.subject",
+	"KAFKA_BROKERS":             "sinks.kafka.brokers",
+	"KAFKA_TOPIC":               "sinks.kafka.topic",
+	"MQTT_URL":                  "sinks.mqtt.url",
+	"MQTT_TOPIC":                "sinks.mqtt.topic",
+	"MQTT_QOS":                  "sinks.mqtt.qos",
+	"MQTT_CLIENT_ID":            "sinks.mqtt.client_id",
+	"PROFILES_FILE":             "profiles.file",
+}
+
+func init() {
+	for _, sink := range EventSinkNames {
+		fileKeys[strings.ToUpper(sink)+"_EVENTS"] = "sinks." + sink + ".events"
+		fileKeys[strings.ToUpper(sink)+"_FORMAT"] = "sinks." + sink + ".format"
+	}
+}
+
+// keepEmpty are the settings where an empty value turns a feature off
+// instead of meaning the default.
+var keepEmpty = map[string]bool{
+	"EVENTS_STREAM_GROUP": true,
+	"OUTBOX_DIR":          true,
+}
+
+// ValidationError lists every problem found in the configuration.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e, "\n  ")
+}
+
+type loader struct {
+	// file holds the settings of the configuration file by their key.
+	file map[string]string
+	errs ValidationError
+}
+
+// readFile reads a YAML configuration file. Lists become comma-separated
+// values, as in the environment; keys Load does not know are errors.
+func (l *loader) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	var root map[string]any
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	known := make(map[string]bool, len(fileKeys))
+	for _, key := range fileKeys {
+		known[key] = true
+	}
+	l.file = map[string]string{}
+	var errs ValidationError
+	var walk func(prefix string, node map[string]any)
+	walk = func(prefix string, node map[string]any) {
+		for name, value := range node {
+			key := prefix + name
+			if section, ok := value.(map[string]any); ok {
+				walk(key+".", section)
+				continue
+			}
+			if !known[key] {
+				errs = append(errs, fmt.Sprintf("%s: unknown setting", key))
+				continue
+			}
+			switch value := value.(type) {
+			case nil:
+			case []any:
+				items := make([]string, len(value))
+				for i, item := range value {
+					items[i] = fmt.Sprint(item)
+				}
+				l.file[key] = strings.Join(items, ",")
+			default:
+				l.file[key] = fmt.Sprint(value)
+			}
+		}
+	}
+	walk("", root)
+	if len(errs) > 0 {
+		slices.Sort(errs)
+		return errs
+	}
+	return nil
+}
+
+// lookup finds a setting in the environment, then in the file. An empty
+// environment variable, as a blank line in .env leaves it, does not hide
+// the file unless the setting keeps empty values.
+func (l *loader) lookup(env string) (string, bool) {
+	if value, ok := os.LookupEnv(env); ok && (value != "" || keepEmpty[env]) {
+		return value, true
+	}
+	value, ok := l.file[fileKeys[env]]
+	return value, ok
+}
+
+func (l *loader) str(env, fallback string) string {
+	value, ok := l.lookup(env)
+	if !ok || value == "" && !keepEmpty[env] {
+		return fallback
+	}
+	return value
+}
+
+// duration reads a duration such as "90s" or a plain number of seconds.
+func (l *loader) duration(env string, fallback time.Duration) time.Duration {
+	value := l.str(env, "")
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		l.invalid(env, value)
+		return fallback
+	}
+	return duration
+}
+
+func (l *loader) int(env string, fallback int64) int64 {
+	value := l.str(env, "")
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		l.invalid(env, value)
+		return fallback
+	}
+	return number
+}
+
+func (l *loader) bool(env string, fallback bool) bool {
+	value := l.str(env, "")
+	if value == "" {
+		return fallback
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		l.invalid(env, value)
+		return fallback
+	}
+	return enabled
+}
+
+// list reads a comma-separated list, skipping empty items.
+func (l *loader) list(env string) []string {
+	var items []string
+	for _, item := range strings.Split(l.str(env, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// prefixes reads a list of IP addresses and CIDR ranges.
+func (l *loader) prefixes(env string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, item := range l.list(env) {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				l.invalid(env, item)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+func (l *loader) oneOf(env, fallback string, choices []string) string {
+	value := l.str(env, fallback)
+	if !slices.Contains(choices, value) {
+		l.fail(env, fmt.Sprintf("invalid value %q, want one of %s", value, strings.Join(choices, ", ")))
+	}
+	return value
+}
+
+// name is how errors refer to a setting: its file key and variable.
+func (l *loader) name(env string) string {
+	return fmt.Sprintf("%s (%s)", fileKeys[env], env)
+}
+
+func (l *loader) fail(env, problem string) {
+	l.errs = append(l.errs, l.name(env)+": "+problem)
+}
+
+func (l *loader) invalid(env, value string) {
+	l.fail(env, fmt.Sprintf("invalid value %q", value))
+}
+
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is the configuration a group of chargers should run with. Every
+// matching profile applies, a later one overriding the keys of an earlier
+// one.
+type Profile struct {
+	Name string `yaml:"name"`
+	// Match selects the chargers; a profile without one applies to all.
+	Match ProfileMatch `yaml:"match"`
+	// Settings are the desired values by configuration key.
+	Settings map[string]string `yaml:"settings"`
+}
+
+// ProfileMatch selects chargers by what they report in BootNotification and
+// where they connect; empty fields match every charger.
+type ProfileMatch struct {
+	Vendor string `yaml:"vendor"`
+	Model  string `yaml:"model"`
+	Domain string `yaml:"domain"`
+	// CpIDs are charger ids, with or without their domain.
+	CpIDs []string `yaml:"cp_ids"`
+}
+
+// Matches reports whether the charger cpID ("domain:id") of the given vendor
+// and model falls under the profile. Vendor and model ignore case.
+func (m ProfileMatch) Matches(cpID, vendor, model string) bool {
+	domain, id, _ := strings.Cut(cpID, ":")
+	if m.Vendor != "" && !strings.EqualFold(m.Vendor, vendor) ||
+		m.Model != "" && !strings.EqualFold(m.Model, model) ||
+		m.Domain != "" && m.Domain != domain {
+		return false
+	}
+	if len(m.CpIDs) == 0 {
+		return true
+	}
+	for _, want := range m.CpIDs {
+		if want == cpID || want == id {
+			return true
+		}
+	}
+	return false
+}
+
+// DesiredSettings merges the settings of the profiles matching a charger and
+// returns them with the names of those profiles.
+func DesiredSettings(profiles []Profile, cpID, vendor, model string) (map[string]string, []string) {
+	settings := map[string]string{}
+	var names []string
+	for _, profile := range profiles {
+		if !profile.Match.Matches(cpID, vendor, model) {
+			continue
+		}
+		names = append(names, profile.Name)
+		for key, value := range profile.Settings {
+			settings[key] = value
+		}
+	}
+	return settings, names
+}
+
+// rawProfile takes setting values as YAML writes them: numbers, booleans and
+// lists become the strings chargers use.
+type rawProfile struct {
+	Name     string         `yaml:"name"`
+	Match    ProfileMatch   `yaml:"match"`
+	Settings map[string]any `yaml:"settings"`
+}
+
+// loadProfiles reads the profiles file: a list of profiles under
+// "profiles".
+func loadProfiles(path string) ([]Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Profiles []rawProfile `yaml:"profiles"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	profiles := make([]Profile, 0, len(file.Profiles))
+	seen := map[string]bool{}
+	for i, raw := range file.Profiles {
+		switch {
+		case raw.Name == "":
+			return nil, fmt.Errorf("profile %d: name is required", i+1)
+		case seen[raw.Name]:
+			return nil, fmt.Errorf("profile %s: defined twice", raw.Name)
+		case len(raw.Settings) == 0:
+			return nil, fmt.Errorf("profile %s: no settings", raw.Name)
+		}
+		seen[raw.Name] = true
+		profile := Profile{Name: raw.Name, Match: raw.Match, Settings: make(map[string]string, len(raw.Settings))}
+		for key, value := range raw.Settings {
+			switch value := value.(type) {
+			case nil:
+				profile.Settings[key] = ""
+			case []any:
+				items := make([]string, len(value))
+				for i, item := range value {
+					items[i] = fmt.Sprint(item)
+				}
+				profile.Settings[key] = strings.Join(items, ",")
+			case map[string]any:
+				return nil, fmt.Errorf("profile %s: %s: want a value or a list", raw.Name, key)
+			default:
+				profile.Settings[key] = fmt.Sprint(value)
+			}
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+)
+
+// reloadable are the settings a running server can take from a new Config.
+// Anything else is read once at startup.
+var reloadable = []string{
+	"HeartbeatInterval",
+	"HeartbeatGrace",
+	"LogLevel",
+	"ReadyMaxEventBacklog",
+	"APIToken",
+	"LiveEventsToken",
+	"ProfilesFile",
+	"Profiles",
+}
+
+// Reload returns c with the reloadable settings of next, and the variables
+// of the settings that changed but need a restart.
+func (c *Config) Reload(next *Config) (*Config, []string) {
+	merged := *c
+	current := reflect.ValueOf(&merged).Elem()
+	incoming := reflect.ValueOf(next).Elem()
+	var restart []string
+	for i := range current.NumField() {
+		field := current.Type().Field(i)
+		if reflect.DeepEqual(current.Field(i).Interface(), incoming.Field(i).Interface()) {
+			continue
+		}
+		// The live feed only exists when it had a token at startup.
+		feedToggled := field.Name == "LiveEventsToken" && (c.LiveEventsToken == "") != (next.LiveEventsToken == "")
+		if slices.Contains(reloadable, field.Name) && !feedToggled {
+			current.Field(i).Set(incoming.Field(i))
+			continue
+		}
+		// Settings read from another variable are reported through it.
+		if env := field.Tag.Get("env"); env != "-" {
+			restart = append(restart, env)
+		}
+	}
+	return &merged, restart
+}
@@ -0,0 +1,444 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"github.com/JscorpTech/ocpp/internal/simulator"
+	"github.com/voltbras/go-ocpp/messages/v1x/cpreq"
+	"github.com/voltbras/go-ocpp/messages/v1x/cpresp"
+)
+
+// Cases is the OCPP 1.6 suite.
+var Cases = []Case{
+	{ID: "CORE-01", Group: "core", Name: "Cold boot is accepted with an interval and the current time", run: coldBoot},
+	{ID: "CORE-02", Group: "core", Name: "Heartbeat returns the current time", run: heartbeat},
+	{ID: "CORE-03", Group: "core", Name: "Authorize returns an idTagInfo status", run: authorize},
+	{ID: "CORE-04", Group: "core", Name: "StatusNotification for the charge point and a connector", run: statusNotification},
+	{ID: "CORE-05", Group: "core", Name: "StartTransaction returns a transaction id", run: startTransaction},
+	{ID: "CORE-06", Group: "core", Name: "MeterValues during a transaction", run: meterValues},
+	{ID: "CORE-07", Group: "core", Name: "StopTransaction ends the transaction", run: stopTransaction},
+	{ID: "CORE-08", Group: "core", Name: "DataTransfer returns a valid status", run: dataTransfer},
+	{ID: "CORE-09", Group: "core", Name: "Regular charging session", run: chargingSession},
+	{ID: "CORE-10", Group: "core", Name: "StartTransaction on connector 0 answers PropertyConstraintViolation", run: startOnConnectorZero},
+	{ID: "CORE-11", Group: "core", Name: "Authorize with an idTag over 20 characters answers PropertyConstraintViolation", run: idTagTooLong},
+	{ID: "REMOTE-01", Group: "remote", Name: "RemoteStartTransaction starts a transaction", run: remoteStart},
+	{ID: "REMOTE-02", Group: "remote", Name: "RemoteStopTransaction stops the transaction", run: remoteStop},
+	{ID: "REMOTE-03", Group: "remote", Name: "GetConfiguration returns the charger keys", run: getConfiguration},
+	{ID: "REMOTE-04", Group: "remote", Name: "ChangeConfiguration changes a key", run: changeConfiguration},
+	{ID: "REMOTE-05", Group: "remote", Name: "A CALLERROR from the charger fails the command", run: remoteCallError},
+	{ID: "REMOTE-06", Group: "remote", Name: "A command to an unconnected charger fails", run: remoteNotConnected},
+	{ID: "RPC-01", Group: "rpc", Name: "Subprotocol ocpp1.6 is negotiated", run: subprotocol},
+	{ID: "RPC-02", Group: "rpc", Name: "Unknown action answers NotImplemented", run: unknownAction},
+	{ID: "RPC-03", Group: "rpc", Name: "Central system action from a charger answers NotSupported", run: wrongDirection},
+	{ID: "RPC-04", Group: "rpc", Name: "Field of the wrong type answers FormationViolation", run: wrongFieldType},
+	{ID: "RPC-05", Group: "rpc", Name: "Payload that is not an object answers FormationViolation", run: payloadNotObject},
+	{ID: "RPC-06", Group: "rpc", Name: "CALL without a payload answers FormationViolation", run: missingPayload},
+	{ID: "RPC-07", Group: "rpc", Name: "Message that is not JSON is ignored", run: notJSON},
+	{ID: "RPC-08", Group: "rpc", Name: "Unknown message type is ignored", run: unknownMessageType},
+	{ID: "RPC-09", Group: "rpc", Name: "CALLRESULT without a CALL is ignored", run: orphanResult},
+	{ID: "RPC-10", Group: "rpc", Name: "Answers carry the message id of their CALL", run: messageIDs},
+	{ID: "RPC-11", Group: "rpc", Name: "Action of a profile the server lacks answers NotSupported", run: unsupportedProfile},
+}
+
+var authorizationStatuses = []string{"Accepted", "Blocked", "Expired", "Invalid", "ConcurrentTx"}
+
+// booted connects a simulated charger and boots it.
+func booted(ctx context.Context, s *session) (*simulator.ChargePoint, error) {
+	cp, err := s.charger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cp.Boot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != "Accepted" {
+		return nil, fmt.Errorf("boot %s", resp.Status)
+	}
+	return cp, cp.StatusNotification(ctx, 1, "Available")
+}
+
+func coldBoot(ctx context.Context, s *session) error {
+	cp, err := s.charger(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := cp.Boot(ctx)
+	if err != nil {
+		return err
+	}
+	if resp.Status != "Accepted" {
+		return fmt.Errorf("status %s, want Accepted", resp.Status)
+	}
+	if resp.Interval <= 0 {
+		return fmt.Errorf("interval %v, want a positive heartbeat interval", resp.Interval)
+	}
+	if skew := time.Since(resp.CurrentTime); skew > time.Minute || skew < -time.Minute {
+		return fmt.Errorf("currentTime %s is %s off", resp.CurrentTime, skew)
+	}
+	return nil
+}
+
+func heartbeat(ctx context.Context, s *session) error {
+	cp, err := booted(ctx, s)
+	if err != nil {
+		return err
+	}
+	var resp cpresp.Heartbeat
+	if err := cp.Call(ctx, "Heartbeat", &cpreq.Heartbeat{}, &resp); err != nil {
+		return err
+	}
+	if resp.CurrentTime.IsZero() {
+		return errors.New("no currentTime")
+	}
+	return nil
+}
+
+func authorize(ctx context.Context, s *session) error {
+	cp, err := booted(ctx, s)
+	if err != nil {
+		return err
+	}
+	status, err := cp.Authorize(ctx, "CONF-TAG")
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(authorizationStatuses, status) {
+		return fmt.Errorf("status %q is not an AuthorizationStatus", status)
+	}
+	return nil
+}
+
+func statusNotification(ctx context.Context, s *session) error {
+	cp, err := booted(ctx, s)
+	if err != nil {
+		return err
+	}
+	for _, status := range []string{"Preparing", "Available"} {
+		for _, connector := range []int{0, 1} {
+			if err := cp.StatusNotification(ctx, connector, status); err != nil {
+				return fmt.Errorf("connector %d %s: %w", connector, status, err)
+			}
+		}
+	}
+	return nil
+}
+
+func startTransaction(ctx context.Context, s *session) error {
+	cp, err := booted(ctx, s)
+	if err != nil {
+		return err
+	}
+	id, err := cp.StartTransaction(ctx, 1, "CONF-TAG")
+	if err != nil {
+		return err
+	}
+	if id == 0 {
+		return errors.New("transactionId 0")
+	}
+	return nil
+}
+
+func meterValues(ctx context.Context, s *session) error {
+	cp, err := booted(ctx, s)
+	if err != nil {
+		return err
+	}
+	if _, err := cp.StartTransaction(ctx, 1, "CONF-TAG"); err != nil {
+		return err
+	}
+	for range 3 {
+		if err := cp.MeterValues(ctx, 1, 100); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stopTransaction(ctx context.Context, s *session) error {
+	cp, err := booted(ctx, s)
+	if err != nil {
+		return err
+	}
+	id, err := cp.StartTransaction(ctx, 1, "CONF-TAG")
+	if err != nil {
+		return err
+	}
+	var resp cpresp.StopTransaction
+	err = cp.Call(ctx, "StopTransaction", &cpreq.StopTransaction{
+		TransactionId: int(id),
+		IdTag:         "CONF-TAG",
+		MeterStop:     1000,
+		Reason:        "Local",
+		Timestamp:     time.Now().UTC(),
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	if resp.IdTagInfo != nil && !slices.Contains(authorizationStatuses, resp.IdTagInfo.Status) {
+		return fmt.Errorf("status %q is not an AuthorizationStatus", resp.IdTagInfo.Status)
+	}
+	return nil
+}
+
+func dataTransfer(ctx context.Context, s *session) error {
+	cp, err := booted(ctx, s)
+	if err != nil {
+		return err
+	}
+	var resp cpresp.DataTransfer
+	if err := cp.Call(ctx, "DataTransfer", &cpreq.DataTransfer{VendorId: "conformance", MessageId: "ping", Data: "{}"}, &resp); err != nil {
+		return err
+	}
+	if !slices.Contains([]string{"Accepted", "Rejected", "UnknownMessageId", "UnknownVendorId"}, resp.Status) {
+		return fmt.Errorf("status %q is not a DataTransferStatus", resp.Status)
+	}
+	return nil
+}
+
+func chargingSession(ctx context.Context, s *session) error {
+	cp, err := s.charger(ctx)
+	if err != nil {
+		return err
+	}
+	return cp.Run(ctx, []simulator.Step{
+		{Action: "BootNotification"},
+		{Action: "StatusNotification", Status: "Available"},
+		{Action: "Authorize", Tag: "CONF-TAG"},
+		{Action: "StatusNotification", Status: "Preparing"},
+		{Action: "StartTransaction", Tag: "CONF-TAG"},
+		{Action: "StatusNotification", Status: "Charging"},
+		{Action: "MeterValues", Energy: 100, Count: 3, Interval: simulator.Duration(20 * time.Millisecond)},
+		{Action: "StatusNotification", Status: "Finishing"},
+		{Action: "StopTransaction", Reason: "Local"},
+		{Action: "StatusNotification", Status: "Available"},
+	})
+}
+
+func startOnConnectorZero(ctx context.Context, s *session) error {
+	return rejects(ctx, s, "s0", `[2,"s0","StartTransaction",{"connectorId":0,"idTag":"CONF-TAG","meterStart":0,"timestamp":"2024-01-01T00:00:00Z"}]`,
+		ocpp.PropertyConstraintViolation)
+}
+
+func idTagTooLong(ctx context.Context, s *session) error {
+	return rejects(ctx, s, "a1", `[2,"a1","Authorize",{"idTag":"CONF-TAG-0123456789-0123456789"}]`, ocpp.PropertyConstraintViolation)
+}
+
+func remoteStart(ctx context.Context, s *session) error {
+	if _, err := booted(ctx, s); err != nil {
+		return err
+	}
+	var res domain.RemoteStartTransactionRes
+	if err := s.command(ctx, domain.RemoteStartTransaction, domain.RemoteStartTransactionReq{Tag: "CONF-TAG", ConnectorID: 1}, &res); err != nil {
+		return err
+	}
+	if res.Status != "Accepted" {
+		return fmt.Errorf("status %s, want Accepted", res.Status)
+	}
+	return s.awaitCall(ctx, "StartTransaction")
+}
+
+func remoteStop(ctx context.Context, s *session) error {
+	cp, err := booted(ctx, s)
+	if err != nil {
+		return err
+	}
+	id, err := cp.StartTransaction(ctx, 1, "CONF-TAG")
+	if err != nil {
+		return err
+	}
+	var res domain.RemoteStopTransactionRes
+	if err := s.command(ctx, domain.RemoteStopTransaction, domain.RemoteStopTransactionReq{TransactionId: id}, &res); err != nil {
+		return err
+	}
+	if res.Status != "Accepted" {
+		return fmt.Errorf("status %s, want Accepted", res.Status)
+	}
+	return s.awaitCall(ctx, "StopTransaction")
+}
+
+func getConfiguration(ctx context.Context, s *session) error {
+	if _, err := booted(ctx, s); err != nil {
+		return err
+	}
+	var res domain.GetConfigurationRes
+	if err := s.command(ctx, domain.GetConfiguration, domain.GetConfigurationReq{Key: []string{"HeartbeatInterval", "NoSuchKey"}}, &res); err != nil {
+		return err
+	}
+	if len(res.ConfigurationKey) != 1 || res.ConfigurationKey[0].Key != "HeartbeatInterval" {
+		return fmt.Errorf("configurationKey %+v, want HeartbeatInterval", res.ConfigurationKey)
+	}
+	if !slices.Equal(res.UnknownKey, []string{"NoSuchKey"}) {
+		return fmt.Errorf("unknownKey %v, want NoSuchKey", res.UnknownKey)
+	}
+	return nil
+}
+
+func changeConfiguration(ctx context.Context, s *session) error {
+	if _, err := booted(ctx, s); err != nil {
+		return err
+	}
+	var res domain.ChangeConfigurationRes
+	if err := s.command(ctx, domain.ChangeConfiguration, domain.ChangeConfigurationReq{Key: "HeartbeatInterval", Value: "120"}, &res); err != nil {
+		return err
+	}
+	if res.Status != "Accepted" {
+		return fmt.Errorf("status %s, want Accepted", res.Status)
+	}
+	var config domain.GetConfigurationRes
+	if err := s.command(ctx, domain.GetConfiguration, domain.GetConfigurationReq{Key: []string{"HeartbeatInterval"}}, &config); err != nil {
+		return err
+	}
+	if len(config.ConfigurationKey) != 1 || config.ConfigurationKey[0].Value != "120" {
+		return fmt.Errorf("configurationKey %+v after the change, want 120", config.ConfigurationKey)
+	}
+	return nil
+}
+
+func remoteCallError(ctx context.Context, s *session) error {
+	w, err := s.wire(ctx)
+	if err != nil {
+		return err
+	}
+	if err := w.alive(ctx); err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		var res domain.RemoteStartTransactionRes
+		errc <- s.command(ctx, domain.RemoteStartTransaction, domain.RemoteStartTransactionReq{Tag: "CONF-TAG", ConnectorID: 1}, &res)
+	}()
+	call, err := w.next(ctx)
+	if err != nil {
+		return err
+	}
+	if call.Type != ocpp.Call || call.Action != "RemoteStartTransaction" {
+		return fmt.Errorf("expected a RemoteStartTransaction CALL, got %s %s", call.Type, call.Action)
+	}
+	if err := w.send(`[4,"` + call.ID + `","NotImplemented","Not here",{}]`); err != nil {
+		return err
+	}
+	var commandErr *commandError
+	if err := <-errc; !errors.As(err, &commandErr) {
+		return fmt.Errorf("command error %v, want a failed command", err)
+	}
+	return nil
+}
+
+func remoteNotConnected(ctx context.Context, s *session) error {
+	var res domain.RemoteStartTransactionRes
+	err := s.command(ctx, domain.RemoteStartTransaction, domain.RemoteStartTransactionReq{Tag: "CONF-TAG", ConnectorID: 1}, &res)
+	var commandErr *commandError
+	if !errors.As(err, &commandErr) {
+		return fmt.Errorf("command error %v, want a failed command", err)
+	}
+	return nil
+}
+
+func subprotocol(ctx context.Context, s *session) error {
+	w, err := s.wire(ctx, "ocpp1.6")
+	if err != nil {
+		return err
+	}
+	if w.protocol != "ocpp1.6" {
+		return fmt.Errorf("negotiated %q, want ocpp1.6", w.protocol)
+	}
+	return w.alive(ctx)
+}
+
+// rejects sends one CALL and expects a CALLERROR with one of codes, with the
+// connection still usable afterwards.
+func rejects(ctx context.Context, s *session, id, text string, codes ...ocpp.ErrorCode) error {
+	w, err := s.wire(ctx)
+	if err != nil {
+		return err
+	}
+	frame, err := w.call(ctx, id, text)
+	if err != nil {
+		return err
+	}
+	if err := expectError(frame, codes...); err != nil {
+		return err
+	}
+	return w.alive(ctx)
+}
+
+// ignores sends a frame the server must not answer and checks the
+// connection still works.
+func ignores(ctx context.Context, s *session, text string) error {
+	w, err := s.wire(ctx)
+	if err != nil {
+		return err
+	}
+	if err := w.send(text); err != nil {
+		return err
+	}
+	return w.alive(ctx)
+}
+
+func unknownAction(ctx context.Context, s *session) error {
+	return rejects(ctx, s, "u1", `[2,"u1","NoSuchAction",{}]`, ocpp.NotImplemented)
+}
+
+func wrongDirection(ctx context.Context, s *session) error {
+	return rejects(ctx, s, "d1", `[2,"d1","Reset",{"type":"Soft"}]`, ocpp.NotSupported)
+}
+
+func wrongFieldType(ctx context.Context, s *session) error {
+	return rejects(ctx, s, "t1", `[2,"t1","BootNotification",{"chargePointVendor":5,"chargePointModel":"SIM"}]`,
+		ocpp.FormationViolation, ocpp.TypeConstraintViolation)
+}
+
+func payloadNotObject(ctx context.Context, s *session) error {
+	return rejects(ctx, s, "p1", `[2,"p1","Heartbeat","now"]`, ocpp.FormationViolation)
+}
+
+func missingPayload(ctx context.Context, s *session) error {
+	return rejects(ctx, s, "m1", `[2,"m1","Heartbeat"]`, ocpp.FormationViolation, ocpp.ProtocolError)
+}
+
+func unsupportedProfile(ctx context.Context, s *session) error {
+	return rejects(ctx, s, "f1", `[2,"f1","FirmwareStatusNotification",{"status":"Idle"}]`, ocpp.NotSupported)
+}
+
+func notJSON(ctx context.Context, s *session) error {
+	return ignores(ctx, s, "not json")
+}
+
+func unknownMessageType(ctx context.Context, s *session) error {
+	return ignores(ctx, s, `[7,"x1",{}]`)
+}
+
+func orphanResult(ctx context.Context, s *session) error {
+	return ignores(ctx, s, `[3,"nobody-called",{}]`)
+}
+
+func messageIDs(ctx context.Context, s *session) error {
+	w, err := s.wire(ctx)
+	if err != nil {
+		return err
+	}
+	ids := []string{"first", "second", "third"}
+	for _, id := range ids {
+		if err := w.send(`[2,"` + id + `","Heartbeat",{}]`); err != nil {
+			return err
+		}
+	}
+	for i, id := range ids {
+		frame, err := w.next(ctx)
+		if err != nil {
+			return err
+		}
+		if frame.Type != ocpp.CallResult || frame.ID != id {
+			return fmt.Errorf("answer %s is %s %s, want CALLRESULT %s", strconv.Itoa(i+1), frame.Type, frame.ID, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+// Package conformance checks a central system against OCPP 1.6-J. Modeled on
+// the OCA test cases, it drives every Core profile message and the remote
+// commands through a simulated charge point, feeds the server broken frames,
+// and reports each case as pass or fail.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/simulator"
+	"go.uber.org/zap"
+)
+
+// Target is the central system under test.
+type Target struct {
+	// URL is where chargers connect, e.g. ws://localhost:10800.
+	URL string
+	// API is the HTTP base of /command/; empty derives it from URL.
+	API string
+	// Token is the API_TOKEN of the server, if it has one.
+	Token string
+	// Timeout bounds each case.
+	Timeout time.Duration
+	Log     *zap.Logger
+}
+
+// Case is one check. Group is "core" for the messages a charger sends,
+// "remote" for the commands the server sends and "rpc" for the OCPP-J framing
+// and CALLERROR handling.
+type Case struct {
+	ID    string
+	Group string
+	Name  string
+	run   func(ctx context.Context, s *session) error
+}
+
+// Select returns the cases whose ID matches pattern; empty selects all.
+func Select(cases []Case, pattern string) ([]Case, error) {
+	if pattern == "" {
+		return cases, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var selected []Case
+	for _, c := range cases {
+		if re.MatchString(c.ID) {
+			selected = append(selected, c)
+		}
+	}
+	return selected, nil
+}
+
+type Result struct {
+	ID       string        `json:"id"`
+	Group    string        `json:"group"`
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+type Report struct {
+	Results []Result `json:"results"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+}
+
+// Run plays the cases one after another, each with chargers of its own.
+func Run(ctx context.Context, target Target, cases []Case) *Report {
+	if target.Timeout <= 0 {
+		target.Timeout = 10 * time.Second
+	}
+	if target.Log == nil {
+		target.Log = zap.NewNop()
+	}
+	if target.API == "" {
+		target.API = strings.Replace(strings.TrimRight(target.URL, "/"), "ws", "http", 1)
+	}
+	report := &Report{Results: make([]Result, 0, len(cases))}
+	for _, c := range cases {
+		start := time.Now()
+		caseCtx, cancel := context.WithTimeout(ctx, target.Timeout)
+		s := &session{target: target, id: c.ID}
+		err := c.run(caseCtx, s)
+		s.close()
+		cancel()
+		result := Result{ID: c.ID, Group: c.Group, Name: c.Name, Passed: err == nil, Duration: time.Since(start)}
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+			target.Log.Warn("Case failed", zap.String("case", c.ID), zap.Error(err))
+		} else {
+			report.Passed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// WriteText prints the pass/fail matrix.
+func (r *Report) WriteText(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "case\tgroup\tname\tresult\tdetail")
+	for _, result := range r.Results {
+		verdict := "PASS"
+		if !result.Passed {
+			verdict = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", result.ID, result.Group, result.Name, verdict, result.Error)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d passed, %d failed\n", r.Passed, r.Failed)
+}
+
+// session is what a case runs in: its chargers, named after the case, and
+// the CALLs they made.
+type session struct {
+	target Target
+	id     string
+
+	mux      sync.Mutex
+	chargers []*simulator.ChargePoint
+	wires    []*wire
+	calls    chan string
+}
+
+func (s *session) close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, cp := range s.chargers {
+		cp.Close()
+	}
+	for _, w := range s.wires {
+		w.close()
+	}
+}
+
+// charger connects a simulated charge point. Its answered CALLs can be
+// awaited with awaitCall.
+func (s *session) charger(ctx context.Context) (*simulator.ChargePoint, error) {
+	s.mux.Lock()
+	if s.calls == nil {
+		s.calls = make(chan string, 100)
+	}
+	calls := s.calls
+	s.mux.Unlock()
+	cp, err := simulator.Dial(ctx, simulator.Options{
+		URL:           s.target.URL,
+		ID:            s.chargerID(),
+		Timeout:       s.target.Timeout,
+		MeterInterval: 50 * time.Millisecond,
+		MeterEnergy:   10,
+		Log:           s.target.Log,
+		OnCall: func(action string, _ time.Duration, err error) {
+			if err == nil {
+				select {
+				case calls <- action:
+				default:
+				}
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.mux.Lock()
+	s.chargers = append(s.chargers, cp)
+	s.mux.Unlock()
+	return cp, nil
+}
+
+// wire connects a charger that sends and reads raw frames.
+func (s *session) wire(ctx context.Context, protocols ...string) (*wire, error) {
+	if len(protocols) == 0 {
+		protocols = []string{"ocpp1.6"}
+	}
+	w, err := dialWire(ctx, s.target.URL, s.chargerID(), protocols)
+	if err != nil {
+		return nil, err
+	}
+	s.mux.Lock()
+	s.wires = append(s.wires, w)
+	s.mux.Unlock()
+	return w, nil
+}
+
+func (s *session) chargerID() string {
+	return "CONF-" + s.id
+}
+
+// cpID is how the server names our charger: the host it was reached on and
+// the charger ID.
+func (s *session) cpID() string {
+	u, err := url.Parse(s.target.URL)
+	if err != nil {
+		return s.chargerID()
+	}
+	return u.Hostname() + ":" + s.chargerID()
+}
+
+func (s *session) awaitCall(ctx context.Context, action string) error {
+	for {
+		select {
+		case got := <-s.calls:
+			if got == action {
+				return nil
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("charger never sent %s", action)
+		}
+	}
+}
+
+// command posts a remote command and decodes the answer into out. A non-200
+// answer is an error carrying the detail.
+func (s *session) command(ctx context.Context, command domain.RemoteCommand, data any, out any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(domain.RemoteCommandReq{CpID: s.cpID(), Command: command, Data: raw})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.target.API+"/command/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.target.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.target.Token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var detail domain.ErrorResponse
+		json.NewDecoder(res.Body).Decode(&detail)
+		return &commandError{Status: res.StatusCode, Detail: detail.Detail}
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+type commandError struct {
+	Status int
+	Detail string
+}
+
+func (e *commandError) Error() string {
+	return fmt.Sprintf("command answered %d: %s", e.Status, e.Detail)
+}
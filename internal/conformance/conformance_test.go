@@ -0,0 +1,63 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestConformance(t *testing.T) {
+	target, stop, err := StartServer(context.Background(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("StartServer() error = %v", err)
+	}
+	defer stop()
+
+	report := Run(context.Background(), target, Cases)
+	var matrix bytes.Buffer
+	report.WriteText(&matrix)
+	t.Log("\n" + matrix.String())
+	for _, result := range report.Results {
+		if !result.Passed {
+			t.Errorf("%s %s: %s", result.ID, result.Name, result.Error)
+		}
+	}
+	if report.Passed != len(Cases) {
+		t.Errorf("passed %d of %d", report.Passed, len(Cases))
+	}
+}
+
+func TestSelect(t *testing.T) {
+	cases, err := Select(Cases, "^RPC-0[12]$")
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if len(cases) != 2 || cases[0].ID != "RPC-01" || cases[1].ID != "RPC-02" {
+		t.Errorf("Select() = %v, want RPC-01, RPC-02", cases)
+	}
+	if _, err := Select(Cases, "("); err == nil {
+		t.Error("Select() accepted a broken pattern")
+	}
+}
+
+func TestReport_WriteText(t *testing.T) {
+	report := &Report{Passed: 1, Failed: 1, Results: []Result{
+		{ID: "CORE-01", Group: "core", Name: "Boot", Passed: true},
+		{ID: "RPC-02", Group: "rpc", Name: "Unknown action", Error: "expected CALLERROR NotImplemented"},
+	}}
+	var out bytes.Buffer
+	report.WriteText(&out)
+	lines := strings.Split(out.String(), "\n")
+	if !strings.Contains(lines[1], "CORE-01") || !strings.Contains(lines[1], "PASS") {
+		t.Errorf("line 1 = %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "FAIL") || !strings.Contains(lines[2], "NotImplemented") {
+		t.Errorf("line 2 = %q", lines[2])
+	}
+	if !strings.Contains(out.String(), "1 passed, 1 failed") {
+		t.Errorf("no summary in %q", out.String())
+	}
+}
@@ -0,0 +1,48 @@
+package conformance
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/client"
+	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// StartServer runs the server in-process on a loopback port, with an
+// in-memory Redis and the fake backend behind it. stop shuts it all down.
+func StartServer(ctx context.Context, log *zap.Logger) (target Target, stop func(), err error) {
+	mini, err := miniredis.Run()
+	if err != nil {
+		return Target{}, nil, err
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: mini.Addr()})
+	backend := httptest.NewServer(&client.FakeBackend{})
+	cfg := &config.Config{
+		BaseUrl:           backend.URL,
+		InstanceID:        "conformance",
+		HeartbeatInterval: time.Minute,
+		EventSinks:        []string{"redis"},
+		EventValidation:   "strict",
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := ocpp.NewServer(ctx, cfg, log, rdb, client.NewTransactionClient(cfg))
+	server := httptest.NewServer(s.Handler())
+	stop = func() {
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancelDrain()
+		s.Shutdown(drainCtx)
+		server.Close()
+		cancel()
+		s.Close()
+		backend.Close()
+		rdb.Close()
+		mini.Close()
+	}
+	return Target{URL: "ws" + strings.TrimPrefix(server.URL, "http"), API: server.URL, Log: log}, stop, nil
+}
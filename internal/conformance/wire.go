@@ -0,0 +1,123 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"github.com/gorilla/websocket"
+)
+
+// wire is a charger that speaks raw frames, for what the simulator would
+// never send.
+type wire struct {
+	socket   *websocket.Conn
+	protocol string
+	frames   chan *ocpp.Frame
+	nextID   atomic.Int64
+}
+
+func dialWire(ctx context.Context, url, id string, protocols []string) (*wire, error) {
+	dialer := websocket.Dialer{Subprotocols: protocols}
+	socket, res, err := dialer.DialContext(ctx, strings.TrimRight(url, "/")+"/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+	w := &wire{
+		socket:   socket,
+		protocol: res.Header.Get("Sec-WebSocket-Protocol"),
+		frames:   make(chan *ocpp.Frame, 16),
+	}
+	go w.read()
+	return w, nil
+}
+
+func (w *wire) read() {
+	defer close(w.frames)
+	for {
+		_, data, err := w.socket.ReadMessage()
+		if err != nil {
+			return
+		}
+		frame, err := ocpp.ParseFrame(data)
+		if err != nil {
+			continue
+		}
+		w.frames <- frame
+	}
+}
+
+func (w *wire) close() {
+	w.socket.Close()
+}
+
+func (w *wire) send(text string) error {
+	return w.socket.WriteMessage(websocket.TextMessage, []byte(text))
+}
+
+// next returns the next frame the server sends.
+func (w *wire) next(ctx context.Context) (*ocpp.Frame, error) {
+	select {
+	case frame, ok := <-w.frames:
+		if !ok {
+			return nil, errors.New("connection closed")
+		}
+		return frame, nil
+	case <-ctx.Done():
+		return nil, errors.New("no frame from the server")
+	}
+}
+
+// call sends a frame and returns the answer to message id, which has to be
+// the next frame the server sends: CALLs are answered in order, so anything
+// else is an answer to a frame that should have been ignored.
+func (w *wire) call(ctx context.Context, id, text string) (*ocpp.Frame, error) {
+	if err := w.send(text); err != nil {
+		return nil, err
+	}
+	frame, err := w.next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if frame.ID != id {
+		return nil, fmt.Errorf("expected the answer to %s, got %s %s", id, frame.Type, frame.ID)
+	}
+	return frame, nil
+}
+
+// alive checks the connection still works after what was sent before: a
+// Heartbeat gets its CALLRESULT and nothing else comes first.
+func (w *wire) alive(ctx context.Context) error {
+	id := fmt.Sprintf("alive-%d", w.nextID.Add(1))
+	frame, err := w.call(ctx, id, `[2,"`+id+`","Heartbeat",{}]`)
+	if err != nil {
+		return err
+	}
+	if frame.Type != ocpp.CallResult {
+		return fmt.Errorf("Heartbeat answered %s", describe(frame))
+	}
+	return nil
+}
+
+// expectError checks frame is a CALLERROR with one of codes.
+func expectError(frame *ocpp.Frame, codes ...ocpp.ErrorCode) error {
+	if frame.Type != ocpp.CallError {
+		return fmt.Errorf("expected CALLERROR %s, got %s", codes[0], describe(frame))
+	}
+	for _, code := range codes {
+		if frame.ErrorCode == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected CALLERROR %s, got %s", codes[0], describe(frame))
+}
+
+func describe(frame *ocpp.Frame) string {
+	if frame.Type == ocpp.CallError {
+		return fmt.Sprintf("CALLERROR %s %q", frame.ErrorCode, frame.ErrorDescription)
+	}
+	return fmt.Sprintf("%s %s", frame.Type, frame.Payload)
+}
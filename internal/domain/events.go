@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"slices"
+	"time"
+)
+
 type EventTypes string
 
 const (
@@ -11,18 +16,96 @@ const (
 	DataTransferEvent          EventTypes = "data_transfer"
 	DisconnectChargerEvent     EventTypes = "disconnect_charger"
 	ConnectChargerEvent        EventTypes = "connect_charger"
+	ConfigurationReportEvent   EventTypes = "configuration_report"
+	OfflineEvent               EventTypes = "offline"
+	OnlineEvent                EventTypes = "online"
 )
 
+// EventTypesAll lists every event type, for validating routing rules.
+var EventTypesAll = []EventTypes{
+	ChangeConnectorStatusEvent,
+	StartTransactionEvent,
+	StopTransactionEvent,
+	MeterValuesEvent,
+	HealthEvent,
+	DataTransferEvent,
+	DisconnectChargerEvent,
+	ConnectChargerEvent,
+	ConfigurationReportEvent,
+	OfflineEvent,
+	OnlineEvent,
+}
+
+// EventSchemaVersion is bumped when an event field changes meaning or goes
+// away; added fields keep the version.
+const EventSchemaVersion = 1
+
 type Event struct {
+	// ID is unique per event, so consumers can drop redeliveries.
+	ID     string     `json:"id"`
 	Event  EventTypes `json:"event"`
 	Domain string     `json:"domain"`
-	Data   any        `json:"data"`
+	// CpID is the charger the event is about.
+	CpID string `json:"cp_id,omitempty"`
+	// InstanceID is the replica the charger was connected to.
+	InstanceID string `json:"instance_id,omitempty"`
+	// Sequence increases strictly per charger, across reconnects and
+	// replicas, so consumers can order its events. It has gaps.
+	Sequence int64 `json:"sequence,omitempty"`
+	// OccurredAt is the charger's own timestamp where the message has one,
+	// ReceivedAt otherwise.
+	OccurredAt    time.Time `json:"occurred_at"`
+	ReceivedAt    time.Time `json:"received_at"`
+	SchemaVersion int       `json:"schema_version"`
+	Data          any       `json:"data"`
+	// TraceParent is the W3C trace context of the message that caused the
+	// event, so consumers can continue the trace.
+	TraceParent string `json:"traceparent,omitempty"`
+}
+
+// CloudEvent is the CloudEvents 1.0 structured JSON form of an Event. The
+// envelope fields without a CloudEvents attribute become extensions.
+type CloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            any       `json:"data"`
+	Domain          string    `json:"domain"`
+	Sequence        int64     `json:"sequence,omitempty"`
+	ReceivedAt      time.Time `json:"receivedat"`
+	SchemaVersion   int       `json:"schemaversion"`
+	TraceParent     string    `json:"traceparent,omitempty"`
+}
+
+func (e *Event) CloudEvent() *CloudEvent {
+	return &CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              e.ID,
+		Source:          "/ocpp/" + e.InstanceID,
+		Type:            "ocpp." + string(e.Event),
+		Subject:         e.CpID,
+		Time:            e.OccurredAt,
+		DataContentType: "application/json",
+		Data:            e.Data,
+		Domain:          e.Domain,
+		Sequence:        e.Sequence,
+		ReceivedAt:      e.ReceivedAt,
+		SchemaVersion:   e.SchemaVersion,
+		TraceParent:     e.TraceParent,
+	}
 }
 
 type ChangeConnectorStatus struct {
 	Charger string `json:"charger"`
 	Conn    int    `json:"conn"`
-	Status  string `json:"status"`
+	// Connector numbers the connector within the EVSE in Conn; only OCPP
+	// 2.0.1 chargers send it.
+	Connector int    `json:"connector,omitempty"`
+	Status    string `json:"status"`
 }
 
 type StartTransaction struct {
@@ -40,9 +123,28 @@ type StopTransaction struct {
 }
 
 type MeterValues struct {
-	Conn          int   `json:"conn"`
-	TransactionId int32 `json:"transaction_id"`
-	MeterValue    any   `json:"meter_value"`
+	Conn          int          `json:"conn"`
+	TransactionId int32        `json:"transaction_id"`
+	MeterValue    []MeterValue `json:"meter_value"`
+}
+
+// MeterValue is the samples a charger took at one time, in the OCPP 1.6
+// shape whichever version the charger speaks.
+type MeterValue struct {
+	SampledValue []SampledValue `json:"sampledValue"`
+	Timestamp    time.Time      `json:"timestamp"`
+}
+
+// SampledValue is one reading. The fields besides Value are empty when the
+// charger leaves them out.
+type SampledValue struct {
+	Context   string `json:"context"`
+	Format    string `json:"format"`
+	Location  string `json:"location"`
+	Measurand string `json:"measurand"`
+	Phase     string `json:"phase"`
+	Unit      string `json:"unit"`
+	Value     string `json:"value"`
 }
 
 type Healthcheck struct {
@@ -63,3 +165,38 @@ type DisconnectCharger struct {
 type ConnectCharger struct {
 	Charger string `json:"charger"`
 }
+
+type ChargerOffline struct {
+	Charger       string    `json:"charger"`
+	LastMessageAt time.Time `json:"last_message_at"`
+}
+
+type ChargerOnline struct {
+	Charger string `json:"charger"`
+}
+
+type ConfigurationKey struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Readonly bool   `json:"readonly"`
+}
+
+type ConfigurationReport struct {
+	Charger   string             `json:"charger"`
+	RequestId int                `json:"request_id"`
+	Keys      []ConfigurationKey `json:"keys"`
+}
+
+// EventFilter narrows a live event feed; an empty field matches everything,
+// otherwise any of its values.
+type EventFilter struct {
+	CpIDs   []string
+	Events  []EventTypes
+	Domains []string
+}
+
+func (f EventFilter) Match(e *Event) bool {
+	return (len(f.CpIDs) == 0 || slices.Contains(f.CpIDs, e.CpID)) &&
+		(len(f.Events) == 0 || slices.Contains(f.Events, e.Event)) &&
+		(len(f.Domains) == 0 || slices.Contains(f.Domains, e.Domain))
+}
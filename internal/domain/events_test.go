@@ -2,7 +2,9 @@ package domain
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestEventTypes(t *testing.T) {
@@ -136,7 +138,10 @@ func TestMeterValues(t *testing.T) {
 	meter := MeterValues{
 		Conn:          1,
 		TransactionId: 123,
-		MeterValue:    map[string]interface{}{"value": 1000},
+		MeterValue: []MeterValue{{
+			SampledValue: []SampledValue{{Measurand: "Energy.Active.Import.Register", Unit: "Wh", Value: "1000"}},
+			Timestamp:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}},
 	}
 
 	data, err := json.Marshal(meter)
@@ -155,6 +160,9 @@ func TestMeterValues(t *testing.T) {
 	if unmarshaled.TransactionId != meter.TransactionId {
 		t.Errorf("TransactionId = %v, want %v", unmarshaled.TransactionId, meter.TransactionId)
 	}
+	if !reflect.DeepEqual(unmarshaled.MeterValue, meter.MeterValue) {
+		t.Errorf("MeterValue = %+v, want %+v", unmarshaled.MeterValue, meter.MeterValue)
+	}
 }
 
 func TestHealthcheck(t *testing.T) {
@@ -176,3 +184,25 @@ func TestHealthcheck(t *testing.T) {
 		t.Errorf("Charger = %v, want %v", unmarshaled.Charger, health.Charger)
 	}
 }
+
+func TestEventFilter_Match(t *testing.T) {
+	event := &Event{Event: StartTransactionEvent, Domain: "a.com", CpID: "cp-1"}
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   bool
+	}{
+		{"empty", EventFilter{}, true},
+		{"charger", EventFilter{CpIDs: []string{"cp-2", "cp-1"}}, true},
+		{"other charger", EventFilter{CpIDs: []string{"cp-2"}}, false},
+		{"event and domain", EventFilter{Events: []EventTypes{StartTransactionEvent}, Domains: []string{"a.com"}}, true},
+		{"other domain", EventFilter{Events: []EventTypes{StartTransactionEvent}, Domains: []string{"b.com"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(event); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package domain
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+type HealthCheck struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Value     *int64 `json:"value,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
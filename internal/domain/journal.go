@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// JournalEntry is one raw OCPP-J frame as it went over a charger's socket.
+type JournalEntry struct {
+	Time      time.Time `json:"time"`
+	CpID      string    `json:"cp_id"`
+	Version   string    `json:"version"`
+	Direction string    `json:"direction"`
+	// Type is CALL, CALLRESULT, CALLERROR or INVALID.
+	Type      string `json:"type"`
+	MessageID string `json:"message_id,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Raw       string `json:"raw"`
+}
+
+type JournalList struct {
+	Count    int            `json:"count"`
+	Messages []JournalEntry `json:"messages"`
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// ChargerPresence describes a charger connected to one of the server instances.
+type ChargerPresence struct {
+	CpID          string    `json:"cp_id"`
+	Domain        string    `json:"domain"`
+	RemoteAddr    string    `json:"remote_addr"`
+	Version       string    `json:"version"`
+	ConnectedAt   time.Time `json:"connected_at"`
+	LastMessageAt time.Time `json:"last_message_at"`
+	InstanceID    string    `json:"instance_id"`
+	SessionID     string    `json:"session_id"`
+	RefreshedAt   time.Time `json:"refreshed_at"`
+}
+
+// ChargerFilter narrows a charger listing; empty fields match everything.
+type ChargerFilter struct {
+	CpID       string
+	Domain     string
+	Version    string
+	InstanceID string
+}
+
+func (f ChargerFilter) Match(p *ChargerPresence) bool {
+	return (f.CpID == "" || p.CpID == f.CpID) &&
+		(f.Domain == "" || p.Domain == f.Domain) &&
+		(f.Version == "" || p.Version == f.Version) &&
+		(f.InstanceID == "" || p.InstanceID == f.InstanceID)
+}
+
+type ChargerList struct {
+	Count    int               `json:"count"`
+	Chargers []ChargerPresence `json:"chargers"`
+}
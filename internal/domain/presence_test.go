@@ -0,0 +1,30 @@
+package domain
+
+import "testing"
+
+func TestChargerFilter_Match(t *testing.T) {
+	presence := &ChargerPresence{
+		CpID:       "example.com:charger-001",
+		Domain:     "example.com",
+		Version:    "ocpp1.6",
+		InstanceID: "ocpp-1",
+	}
+	tests := []struct {
+		name   string
+		filter ChargerFilter
+		want   bool
+	}{
+		{"empty", ChargerFilter{}, true},
+		{"domain", ChargerFilter{Domain: "example.com"}, true},
+		{"version mismatch", ChargerFilter{Version: "ocpp2.0.1"}, false},
+		{"all fields", ChargerFilter{CpID: "example.com:charger-001", Domain: "example.com", Version: "ocpp1.6", InstanceID: "ocpp-1"}, true},
+		{"instance mismatch", ChargerFilter{InstanceID: "ocpp-2"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(presence); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
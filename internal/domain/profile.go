@@ -0,0 +1,61 @@
+package domain
+
+import "time"
+
+// KeyStatus is the outcome of checking one configuration key against the
+// profiles of a charger.
+type KeyStatus string
+
+const (
+	// KeyInSync had the desired value already.
+	KeyInSync KeyStatus = "InSync"
+	// KeyAccepted was changed to the desired value.
+	KeyAccepted       KeyStatus = "Accepted"
+	KeyRebootRequired KeyStatus = "RebootRequired"
+	KeyRejected       KeyStatus = "Rejected"
+	KeyNotSupported   KeyStatus = "NotSupported"
+	// KeyUnknown is not a key the charger knows.
+	KeyUnknown KeyStatus = "Unknown"
+	// KeyReadonly differs but cannot be changed.
+	KeyReadonly KeyStatus = "Readonly"
+	// KeyFailed could not be read or changed, see Error.
+	KeyFailed KeyStatus = "Failed"
+)
+
+// ConfigurationDrift is how the configuration of a charger compared to its
+// profiles when it last booted.
+type ConfigurationDrift struct {
+	CpID      string    `json:"cp_id"`
+	Vendor    string    `json:"vendor"`
+	Model     string    `json:"model"`
+	Profiles  []string  `json:"profiles"`
+	CheckedAt time.Time `json:"checked_at"`
+	// InSync is set once every key has its desired value in effect.
+	InSync bool       `json:"in_sync"`
+	Keys   []KeyDrift `json:"keys"`
+}
+
+type KeyDrift struct {
+	Key     string `json:"key"`
+	Desired string `json:"desired"`
+	// Actual is the value the charger reported before any change.
+	Actual string    `json:"actual"`
+	Status KeyStatus `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// DriftFilter narrows a drift listing; Drifted keeps the chargers that are
+// not in sync.
+type DriftFilter struct {
+	CpID    string
+	Drifted bool
+}
+
+func (f DriftFilter) Match(d *ConfigurationDrift) bool {
+	return (f.CpID == "" || d.CpID == f.CpID) && (!f.Drifted || !d.InSync)
+}
+
+type DriftList struct {
+	Count    int                  `json:"count"`
+	Chargers []ConfigurationDrift `json:"chargers"`
+}
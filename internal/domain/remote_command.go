@@ -2,6 +2,7 @@ package domain
 
 import (
 	"encoding/json"
+	"time"
 )
 
 type RemoteCommand string
@@ -13,27 +14,35 @@ var (
 	ChangeConfiguration    RemoteCommand = "change_configuration"
 )
 
+// RemoteCommands lists every command /command/ accepts.
+var RemoteCommands = []RemoteCommand{
+	RemoteStartTransaction,
+	RemoteStopTransaction,
+	GetConfiguration,
+	ChangeConfiguration,
+}
+
 type RemoteCommandRes struct {
 	Detail string `json:"detail"`
 	Data   any    `json:"data"`
 }
 
 type RemoteCommandReq struct {
-	CpID    string          `json:"cp_id"`
-	Command RemoteCommand   `json:"command"`
-	Data    json.RawMessage `json:"data"`
+	CpID    string          `json:"cp_id" jsonschema:"required,minLength=1"`
+	Command RemoteCommand   `json:"command" jsonschema:"required"`
+	Data    json.RawMessage `json:"data" jsonschema:"required"`
 }
 
 type RemoteStartTransactionReq struct {
-	Tag         string `json:"tag"`
-	ConnectorID int32  `json:"connector_id"`
+	Tag         string `json:"tag" jsonschema:"required,minLength=1"`
+	ConnectorID int32  `json:"connector_id" jsonschema:"minimum=0"`
 }
 
 type RemoteStartTransactionRes struct {
 	Status string `json:"status"`
 }
 type RemoteStopTransactionReq struct {
-	TransactionId int32 `json:"transaction_id"`
+	TransactionId int32 `json:"transaction_id" jsonschema:"required"`
 }
 type RemoteStopTransactionRes struct {
 	Status string `json:"status"`
@@ -41,6 +50,15 @@ type RemoteStopTransactionRes struct {
 
 type ErrorResponse struct {
 	Detail string `json:"detail"`
+	// Errors lists what is wrong with each field of a rejected body.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is a schema violation. Field is the dotted path, such as
+// "data.tag"; it is empty for the body as a whole.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 type GetConfigurationReq struct {
@@ -48,6 +66,45 @@ type GetConfigurationReq struct {
 }
 
 type ChangeConfigurationReq struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key   string `json:"key" jsonschema:"required,minLength=1"`
+	Value string `json:"value" jsonschema:"required"`
+}
+
+type GetConfigurationRes struct {
+	ConfigurationKey []ConfigurationKey `json:"configurationKey"`
+	UnknownKey       []string           `json:"unknownKey"`
+	// ReportRequestId is set when the charger reports asynchronously; the
+	// keys then arrive as a configuration_report event with this request id.
+	ReportRequestId int `json:"reportRequestId,omitempty"`
+}
+
+type ChangeConfigurationRes struct {
+	Status string `json:"status"`
+}
+
+// SchemaList indexes the JSON Schemas served under /schemas/.
+type SchemaList struct {
+	Count   int      `json:"count"`
+	Schemas []string `json:"schemas"`
+}
+
+// ConfigurationSnapshot is the last known configuration of a charger, built
+// from what it answered to GetConfiguration and accepted in
+// ChangeConfiguration. It is kept while the charger is offline.
+type ConfigurationSnapshot struct {
+	CpID string `json:"cp_id"`
+	// UpdatedAt is when the charger last reported or accepted a value.
+	UpdatedAt        time.Time     `json:"updated_at"`
+	ConfigurationKey []SnapshotKey `json:"configurationKey"`
+	UnknownKey       []string      `json:"unknownKey"`
+}
+
+type SnapshotKey struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Readonly bool   `json:"readonly"`
+	// RebootRequired is set when the value was accepted but only takes
+	// effect once the charger reboots; the next read clears it.
+	RebootRequired bool      `json:"reboot_required,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
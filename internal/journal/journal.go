@@ -0,0 +1,354 @@
+// Package journal records every raw OCPP frame per charger as JSON lines,
+// one file per charger, rotated by size.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	fileExt = ".jsonl"
+	// queueSize is how many entries may wait for the disk before new ones
+	// are dropped.
+	queueSize = 4096
+	// tailBlock is how much of a file Last reads at a time, from the end.
+	tailBlock = 64 << 10
+)
+
+var ErrQueueFull = errors.New("journal queue full")
+
+// Journal writes entries from its own goroutine, so a slow disk does not
+// hold up the chargers.
+type Journal struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	log      *zap.Logger
+	queue    chan queued
+	stopped  chan struct{}
+
+	// queueMux guards closing the queue against the senders, which may
+	// outlive Stop at shutdown.
+	queueMux sync.RWMutex
+	closed   bool
+
+	mux   sync.Mutex
+	files map[string]*file
+}
+
+// queued is an entry to write, a charger whose file to close, or a marker
+// closed once everything before it is written.
+type queued struct {
+	entry   *domain.JournalEntry
+	closeID string
+	done    chan struct{}
+}
+
+type file struct {
+	mux  sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+// New keeps at most maxFiles files of maxSize bytes per charger in dir.
+// Call Stop to write out what is queued.
+func New(dir string, maxSize int64, maxFiles int, logger *zap.Logger) (*Journal, error) {
+	if maxSize <= 0 || maxFiles <= 0 {
+		return nil, fmt.Errorf("journal: invalid rotation %d bytes x %d files", maxSize, maxFiles)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	j := &Journal{
+		dir:      dir,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		log:      logger,
+		queue:    make(chan queued, queueSize),
+		stopped:  make(chan struct{}),
+		files:    make(map[string]*file),
+	}
+	go j.run()
+	return j, nil
+}
+
+func (j *Journal) run() {
+	defer close(j.stopped)
+	for item := range j.queue {
+		switch {
+		case item.done != nil:
+			close(item.done)
+		case item.closeID != "":
+			if err := j.close(item.closeID); err != nil {
+				j.log.Error("journal error", zap.String("cp_id", item.closeID), zap.Error(err))
+			}
+		default:
+			if err := j.write(item.entry); err != nil {
+				j.log.Error("journal error", zap.String("cp_id", item.entry.CpID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// Path is the current journal file of a charger. Rotated files get a .1,
+// .2, ... suffix, .1 being the newest.
+func (j *Journal) Path(cpID string) string {
+	return filepath.Join(j.dir, url.PathEscape(cpID)+fileExt)
+}
+
+func (j *Journal) file(cpID string) *file {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	f, ok := j.files[cpID]
+	if !ok {
+		f = &file{path: j.Path(cpID)}
+		j.files[cpID] = f
+	}
+	return f
+}
+
+// Write queues an entry; it is dropped when the queue is full, and
+// silently after Stop. The entry must not be changed afterwards.
+func (j *Journal) Write(entry *domain.JournalEntry) error {
+	j.queueMux.RLock()
+	defer j.queueMux.RUnlock()
+	if j.closed {
+		return nil
+	}
+	select {
+	case j.queue <- queued{entry: entry}:
+		return nil
+	default:
+		metrics.JournalDropped.Inc()
+		return ErrQueueFull
+	}
+}
+
+// enqueue waits for room in the queue; it reports false after Stop.
+func (j *Journal) enqueue(item queued) bool {
+	j.queueMux.RLock()
+	defer j.queueMux.RUnlock()
+	if j.closed {
+		return false
+	}
+	j.queue <- item
+	return true
+}
+
+// Flush waits until the entries queued so far are written.
+func (j *Journal) Flush() {
+	done := make(chan struct{})
+	if j.enqueue(queued{done: done}) {
+		<-done
+	}
+}
+
+// Stop writes out the queued entries and closes the files. Later calls
+// are no-ops.
+func (j *Journal) Stop() error {
+	j.queueMux.Lock()
+	if j.closed {
+		j.queueMux.Unlock()
+		return nil
+	}
+	j.closed = true
+	close(j.queue)
+	j.queueMux.Unlock()
+	<-j.stopped
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	var errs []error
+	for _, f := range j.files {
+		f.mux.Lock()
+		if f.f != nil {
+			errs = append(errs, f.f.Close())
+			f.f, f.size = nil, 0
+		}
+		f.mux.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+func (j *Journal) write(entry *domain.JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f := j.file(entry.CpID)
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.f != nil && f.size > 0 && f.size+int64(len(line)) > j.maxSize {
+		if err := j.rotate(f); err != nil {
+			return err
+		}
+	}
+	if f.f == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	n, err := f.f.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+func (f *file) open() error {
+	handle, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := handle.Stat()
+	if err != nil {
+		handle.Close()
+		return err
+	}
+	f.f, f.size = handle, info.Size()
+	return nil
+}
+
+// rotate shifts path.N to path.N+1, dropping the oldest, and moves the
+// current file to path.1. The caller holds f.mux.
+func (j *Journal) rotate(f *file) error {
+	if err := f.f.Close(); err != nil {
+		return err
+	}
+	f.f, f.size = nil, 0
+	if j.maxFiles == 1 {
+		return os.Remove(f.path)
+	}
+	for i := j.maxFiles - 1; i > 0; i-- {
+		from := f.path
+		if i > 1 {
+			from = fmt.Sprintf("%s.%d", f.path, i-1)
+		}
+		err := os.Rename(from, fmt.Sprintf("%s.%d", f.path, i))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the file of a charger that disconnected, once the entries
+// queued before are written. After Stop the files are closed already.
+func (j *Journal) Close(cpID string) {
+	j.enqueue(queued{closeID: cpID})
+}
+
+func (j *Journal) close(cpID string) error {
+	f := j.file(cpID)
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f, f.size = nil, 0
+	return err
+}
+
+// Last returns up to n of the newest written entries of a charger, oldest
+// first, going back through the rotated files only as far as it needs to.
+func (j *Journal) Last(cpID string, n int) ([]domain.JournalEntry, error) {
+	f := j.file(cpID)
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	var entries []domain.JournalEntry
+	for i := 0; i < j.maxFiles && len(entries) < n; i++ {
+		path := f.path
+		if i > 0 {
+			path = fmt.Sprintf("%s.%d", f.path, i)
+		}
+		older, err := tail(path, n-len(entries))
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(older, entries...)
+	}
+	return entries, nil
+}
+
+// tail returns up to n of the last entries of a file, oldest first. It reads
+// the file backwards in blocks, so only the lines it returns are decoded.
+func tail(path string, n int) ([]domain.JournalEntry, error) {
+	handle, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+	info, err := handle.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// newest first until the end
+	var entries []domain.JournalEntry
+	add := func(line []byte) {
+		var entry domain.JournalEntry
+		if json.Unmarshal(line, &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	// rest is the start of the file's first line seen so far, which may
+	// begin in an earlier block.
+	var rest []byte
+	offset := info.Size()
+	for offset > 0 && len(entries) < n {
+		size := min(tailBlock, offset)
+		offset -= size
+		block := make([]byte, size, size+int64(len(rest)))
+		if _, err := handle.ReadAt(block, offset); err != nil {
+			return nil, err
+		}
+		lines := bytes.Split(append(block, rest...), []byte{'\n'})
+		rest = lines[0]
+		for i := len(lines) - 1; i > 0 && len(entries) < n; i-- {
+			add(lines[i])
+		}
+	}
+	if offset == 0 && len(entries) < n {
+		add(rest)
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// ReadFile reads a journal file, skipping lines that do not decode, such as
+// one cut short by a crash.
+func ReadFile(path string) ([]domain.JournalEntry, error) {
+	handle, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+	var entries []domain.JournalEntry
+	scanner := bufio.NewScanner(handle)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry domain.JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
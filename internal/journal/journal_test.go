@@ -0,0 +1,140 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"go.uber.org/zap"
+)
+
+func entry(cpID string, n int) *domain.JournalEntry {
+	return &domain.JournalEntry{
+		Time:      time.Now(),
+		CpID:      cpID,
+		Direction: domain.DirectionIn,
+		Type:      "CALL",
+		MessageID: fmt.Sprint(n),
+		Action:    "Heartbeat",
+		Raw:       fmt.Sprintf(`[2,"%d","Heartbeat",{}]`, n),
+	}
+}
+
+func TestJournal_Rotation(t *testing.T) {
+	j, err := New(t.TempDir(), 400, 3, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for n := 0; n < 20; n++ {
+		if err := j.Write(entry("example.com:CP/1", n)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	j.Flush()
+	if _, err := os.Stat(j.Path("example.com:CP/1") + ".3"); !os.IsNotExist(err) {
+		t.Errorf("found a fourth file, want at most 3")
+	}
+
+	entries, err := j.Last("example.com:CP/1", 5)
+	if err != nil {
+		t.Fatalf("Last() error = %v", err)
+	}
+	if len(entries) != 5 || entries[0].MessageID != "15" || entries[4].MessageID != "19" {
+		t.Errorf("Last() = %v, want messages 15..19", entries)
+	}
+
+	all, _ := j.Last("example.com:CP/1", 100)
+	if len(all) == 0 || len(all) >= 20 || all[len(all)-1].MessageID != "19" {
+		t.Errorf("Last(100) returned %d entries, want the newest ones only", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Time.Before(all[i-1].Time) {
+			t.Fatal("Last() not in order")
+		}
+	}
+}
+
+func TestJournal_CloseAndReopen(t *testing.T) {
+	j, _ := New(t.TempDir(), 1<<20, 2, zap.NewNop())
+	j.Write(entry("a:CP", 1))
+	j.Close("a:CP")
+	j.Write(entry("a:CP", 2))
+	j.Flush()
+	entries, _ := j.Last("a:CP", 10)
+	if len(entries) != 2 {
+		t.Errorf("Last() = %d entries, want 2", len(entries))
+	}
+	if entries, _ := j.Last("a:unknown", 10); len(entries) != 0 {
+		t.Errorf("Last() for an unknown charger = %v", entries)
+	}
+}
+
+func TestReadFile_SkipsBrokenLines(t *testing.T) {
+	j, _ := New(t.TempDir(), 1<<20, 1, zap.NewNop())
+	j.Write(entry("a:CP", 1))
+	j.Stop()
+	f, _ := os.OpenFile(j.Path("a:CP"), os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"time":"2024-`)
+	f.Close()
+
+	entries, err := ReadFile(j.Path("a:CP"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("ReadFile() = %d entries, want 1", len(entries))
+	}
+}
+
+func TestJournal_LastReadsBackwards(t *testing.T) {
+	j, _ := New(t.TempDir(), 1<<20, 1, zap.NewNop())
+	// Lines long enough to span several blocks.
+	for n := 0; n < 50; n++ {
+		e := entry("a:CP", n)
+		e.Raw = strings.Repeat("x", tailBlock/10)
+		j.Write(e)
+	}
+	j.Stop()
+	f, _ := os.OpenFile(j.Path("a:CP"), os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"time":"2024-`)
+	f.Close()
+
+	entries, err := j.Last("a:CP", 25)
+	if err != nil {
+		t.Fatalf("Last() error = %v", err)
+	}
+	if len(entries) != 25 || entries[0].MessageID != "25" || entries[24].MessageID != "49" {
+		t.Errorf("Last() = %d entries from %v, want 25..49", len(entries), entries[0].MessageID)
+	}
+	if all, _ := j.Last("a:CP", 100); len(all) != 50 || all[0].MessageID != "0" {
+		t.Errorf("Last(100) = %d entries, want all 50", len(all))
+	}
+}
+
+func TestJournal_QueueFull(t *testing.T) {
+	// Nothing drains the queue.
+	j := &Journal{queue: make(chan queued, 1)}
+	j.Write(entry("a:CP", 1))
+	if err := j.Write(entry("a:CP", 2)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Write() error = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestJournal_AfterStop(t *testing.T) {
+	j, _ := New(t.TempDir(), 1<<20, 1, zap.NewNop())
+	if err := j.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := j.Write(entry("a:CP", 1)); err != nil {
+		t.Errorf("Write() error = %v, want the entry dropped", err)
+	}
+	j.Close("a:CP")
+	j.Flush()
+	if err := j.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+// Package loadtest opens many simulated charge points against a central
+// system and measures how it copes: answer latency per action and how fast
+// events reach Redis.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/simulator"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// URL is the central system, e.g. ws://localhost:10800.
+	URL      string
+	Chargers int
+	// Prefix names the chargers <prefix>-<n>.
+	Prefix string
+	// Ramp is how many chargers connect per second; 0 connects all at once.
+	Ramp float64
+	// Duration is how long to keep going once every charger is connected.
+	Duration time.Duration
+	// Heartbeat overrides the interval the server asks for; 0 keeps it.
+	Heartbeat time.Duration
+	// MeterInterval is how often a charger sends meter values, during a
+	// transaction or, without transactions, all the time. 0 sends none.
+	MeterInterval time.Duration
+	// TxDuration is how long each transaction lasts and TxIdle the pause
+	// between them; TxDuration 0 runs no transactions.
+	TxDuration time.Duration
+	TxIdle     time.Duration
+	Timeout    time.Duration
+	// Redis, if set, is where the events land, in the EventsKey list or
+	// stream. Stop its consumers during the run or the count comes out low.
+	Redis     redis.UniversalClient
+	EventsKey string
+	// Progress gets a status line every ProgressInterval.
+	Progress         io.Writer
+	ProgressInterval time.Duration
+	Log              *zap.Logger
+}
+
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Log == nil {
+		opts.Log = zap.NewNop()
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = 10 * time.Second
+	}
+	eventsStart, eventsErr := eventCount(ctx, opts)
+	if opts.Redis != nil && eventsErr != nil {
+		return nil, fmt.Errorf("events: %w", eventsErr)
+	}
+
+	rampTime := time.Duration(0)
+	if opts.Ramp > 0 {
+		rampTime = time.Duration(float64(opts.Chargers) / opts.Ramp * float64(time.Second))
+	}
+	runCtx, cancel := context.WithTimeout(ctx, rampTime+opts.Duration)
+	defer cancel()
+
+	recorder := NewRecorder()
+	r := &run{opts: opts, ctx: runCtx, recorder: recorder}
+	start := time.Now()
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		r.progress(start)
+	}()
+
+	var wg sync.WaitGroup
+	for i := range opts.Chargers {
+		if opts.Ramp > 0 && i > 0 {
+			next := start.Add(time.Duration(float64(i) / opts.Ramp * float64(time.Second)))
+			select {
+			case <-time.After(time.Until(next)):
+			case <-runCtx.Done():
+			}
+		}
+		if runCtx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.charger(fmt.Sprintf("%s-%05d", opts.Prefix, i+1))
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	<-progressDone
+
+	report := &Report{
+		Chargers:      opts.Chargers,
+		Connected:     int(r.connected.Load()),
+		ConnectFailed: int(r.connectFailed.Load()),
+		Disconnected:  int(r.disconnected.Load()),
+		Elapsed:       elapsed,
+		Transactions:  int(r.transactions.Load()),
+		Actions:       recorder.Stats(elapsed),
+	}
+	if opts.Redis != nil {
+		// A fresh context: the caller's may be what ended the run.
+		countCtx, cancelCount := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelCount()
+		eventsEnd, err := eventCount(countCtx, opts)
+		if err != nil {
+			return report, fmt.Errorf("events: %w", err)
+		}
+		report.EventsMeasured = true
+		report.Events = eventsEnd - eventsStart
+		report.EventRate = float64(report.Events) / elapsed.Seconds()
+	}
+	return report, nil
+}
+
+type run struct {
+	opts     Options
+	ctx      context.Context
+	recorder *Recorder
+
+	connected     atomic.Int64
+	connectFailed atomic.Int64
+	disconnected  atomic.Int64
+	transactions  atomic.Int64
+}
+
+// record drops the errors of CALLs cut short by the end of the run.
+func (r *run) record(action string, took time.Duration, err error) {
+	if err != nil && r.ctx.Err() != nil {
+		return
+	}
+	r.recorder.Record(action, took, err)
+}
+
+func (r *run) charger(id string) {
+	start := time.Now()
+	cp, err := simulator.Dial(r.ctx, simulator.Options{
+		URL:       r.opts.URL,
+		ID:        id,
+		Timeout:   r.opts.Timeout,
+		Heartbeat: r.opts.Heartbeat,
+		OnCall:    r.record,
+	})
+	r.record("Connect", time.Since(start), err)
+	if err != nil {
+		if r.ctx.Err() == nil {
+			r.connectFailed.Add(1)
+			r.opts.Log.Warn("Connect failed", zap.String("cp_id", id), zap.Error(err))
+		}
+		return
+	}
+	r.connected.Add(1)
+	defer cp.Close()
+
+	if err := r.session(cp); err != nil && r.ctx.Err() == nil {
+		r.opts.Log.Warn("Charger stopped", zap.String("cp_id", id), zap.Error(err))
+	}
+	select {
+	case <-cp.Done():
+		if r.ctx.Err() == nil {
+			r.disconnected.Add(1)
+		}
+	default:
+	}
+}
+
+func (r *run) session(cp *simulator.ChargePoint) error {
+	if _, err := cp.Boot(r.ctx); err != nil {
+		return err
+	}
+	if err := cp.StatusNotification(r.ctx, 1, "Available"); err != nil {
+		return err
+	}
+	if r.opts.TxDuration <= 0 {
+		return r.meter(cp, 0)
+	}
+	// Spread the chargers out so they do not start transactions in step.
+	idle := r.jitter(r.opts.TxIdle)
+	for {
+		if err := r.wait(cp, idle); err != nil {
+			return err
+		}
+		idle = r.opts.TxIdle
+		if _, err := cp.StartTransaction(r.ctx, 1, "LOAD-"+cp.ID()); err != nil {
+			return err
+		}
+		r.transactions.Add(1)
+		if err := cp.StatusNotification(r.ctx, 1, "Charging"); err != nil {
+			return err
+		}
+		if err := r.meter(cp, r.opts.TxDuration); err != nil {
+			return err
+		}
+		if err := cp.StopTransaction(r.ctx, 1, "Local"); err != nil {
+			return err
+		}
+		if err := cp.StatusNotification(r.ctx, 1, "Available"); err != nil {
+			return err
+		}
+	}
+}
+
+// meter sends meter values for d, or until the run ends when d is 0.
+func (r *run) meter(cp *simulator.ChargePoint, d time.Duration) error {
+	if r.opts.MeterInterval <= 0 {
+		if d == 0 {
+			return r.wait(cp, -1)
+		}
+		return r.wait(cp, d)
+	}
+	deadline := time.Now().Add(d)
+	for d == 0 || time.Until(deadline) > 0 {
+		step := r.opts.MeterInterval
+		if d > 0 {
+			step = min(step, time.Until(deadline))
+		}
+		if err := r.wait(cp, step); err != nil {
+			return err
+		}
+		if err := cp.MeterValues(r.ctx, 1, 10); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait sleeps for d, forever when d is negative, unless the run ends or the
+// connection drops first.
+func (r *run) wait(cp *simulator.ChargePoint, d time.Duration) error {
+	var timer <-chan time.Time
+	if d >= 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timer = t.C
+	}
+	select {
+	case <-timer:
+		return nil
+	case <-cp.Done():
+		return simulator.ErrClosed
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+}
+
+func (r *run) jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+func (r *run) progress(start time.Time) {
+	if r.opts.Progress == nil {
+		return
+	}
+	ticker := time.NewTicker(r.opts.ProgressInterval)
+	defer ticker.Stop()
+	lastCalls, lastTime := 0, start
+	lastEvents, _ := eventCount(r.ctx, r.opts)
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case now := <-ticker.C:
+			calls := r.recorder.Count()
+			seconds := now.Sub(lastTime).Seconds()
+			line := fmt.Sprintf("%s  connected %d  calls %.1f/s", now.Sub(start).Round(time.Second), r.connected.Load(), float64(calls-lastCalls)/seconds)
+			if r.opts.Redis != nil {
+				if events, err := eventCount(r.ctx, r.opts); err == nil {
+					line += fmt.Sprintf("  events %.1f/s", float64(events-lastEvents)/seconds)
+					lastEvents = events
+				}
+			}
+			fmt.Fprintln(r.opts.Progress, line)
+			lastCalls, lastTime = calls, now
+		}
+	}
+}
+
+// eventCount is the length of the events list, or how many entries were ever
+// added to the events stream: its length stops growing once it is trimmed to
+// EVENTS_STREAM_MAXLEN.
+func eventCount(ctx context.Context, opts Options) (int64, error) {
+	if opts.Redis == nil {
+		return 0, nil
+	}
+	kind, err := opts.Redis.Type(ctx, opts.EventsKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	switch kind {
+	case "list":
+		return opts.Redis.LLen(ctx, opts.EventsKey).Result()
+	case "stream":
+		info, err := opts.Redis.XInfoStream(ctx, opts.EventsKey).Result()
+		if err != nil {
+			return 0, err
+		}
+		// Redis before 7.0 does not report entries-added.
+		if info.EntriesAdded > 0 {
+			return info.EntriesAdded, nil
+		}
+		return info.Length, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,77 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestRun(t *testing.T) {
+	csys := ocpp.NewCentralSystem(zap.NewNop(), func(ctx context.Context, conn *ocpp.Conn, action string, payload json.RawMessage) (any, error) {
+		switch action {
+		case "BootNotification":
+			return map[string]any{"status": "Accepted", "currentTime": time.Now().UTC(), "interval": 300}, nil
+		case "StartTransaction":
+			return map[string]any{"transactionId": 1, "idTagInfo": map[string]string{"status": "Accepted"}}, nil
+		}
+		return map[string]any{}, nil
+	})
+	server := httptest.NewServer(csys)
+	defer server.Close()
+
+	report, err := Run(context.Background(), Options{
+		URL:           "ws" + strings.TrimPrefix(server.URL, "http"),
+		Chargers:      20,
+		Prefix:        "LOAD",
+		Ramp:          200,
+		Duration:      300 * time.Millisecond,
+		MeterInterval: 20 * time.Millisecond,
+		TxDuration:    50 * time.Millisecond,
+		TxIdle:        20 * time.Millisecond,
+		Timeout:       time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Connected != 20 || report.ConnectFailed != 0 || report.Transactions == 0 {
+		t.Errorf("report = %+v", report)
+	}
+	counts := map[string]int{}
+	for _, s := range report.Actions {
+		counts[s.Action] = s.Count
+		if s.Errors != 0 {
+			t.Errorf("%s errors = %d", s.Action, s.Errors)
+		}
+	}
+	for _, action := range []string{"Connect", "BootNotification", "StatusNotification", "StartTransaction", "MeterValues"} {
+		if counts[action] == 0 {
+			t.Errorf("no %s in %v", action, counts)
+		}
+	}
+	if report.EventsMeasured {
+		t.Error("events measured without Redis")
+	}
+}
+
+func TestEventCount(t *testing.T) {
+	ctx := context.Background()
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer rdb.Close()
+	for i := range 3 {
+		rdb.RPush(ctx, "events", i)
+		rdb.XAdd(ctx, &redis.XAddArgs{Stream: "stream", Values: []any{"n", i}})
+	}
+	for key, want := range map[string]int64{"events": 3, "stream": 3, "missing": 0} {
+		if got, err := eventCount(ctx, Options{Redis: rdb, EventsKey: key}); err != nil || got != want {
+			t.Errorf("eventCount(%s) = %d, %v, want %d", key, got, err, want)
+		}
+	}
+}
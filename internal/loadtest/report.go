@@ -0,0 +1,132 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Recorder collects the latency of every CALL, per action.
+type Recorder struct {
+	mux     sync.Mutex
+	actions map[string]*samples
+}
+
+type samples struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{actions: make(map[string]*samples)}
+}
+
+func (r *Recorder) Record(action string, took time.Duration, err error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s, ok := r.actions[action]
+	if !ok {
+		s = &samples{}
+		r.actions[action] = s
+	}
+	if err != nil {
+		s.errors++
+		return
+	}
+	s.latencies = append(s.latencies, took)
+}
+
+// Count is the number of CALLs recorded so far, failed ones included.
+func (r *Recorder) Count() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	total := 0
+	for _, s := range r.actions {
+		total += len(s.latencies) + s.errors
+	}
+	return total
+}
+
+// ActionStats summarises the answered CALLs of one action; Errors counts
+// CALLERRORs and timeouts.
+type ActionStats struct {
+	Action string        `json:"action"`
+	Count  int           `json:"count"`
+	Errors int           `json:"errors"`
+	Rate   float64       `json:"rate"`
+	P50    time.Duration `json:"p50"`
+	P90    time.Duration `json:"p90"`
+	P99    time.Duration `json:"p99"`
+	Max    time.Duration `json:"max"`
+}
+
+func (r *Recorder) Stats(elapsed time.Duration) []ActionStats {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	stats := make([]ActionStats, 0, len(r.actions))
+	for action, s := range r.actions {
+		latencies := slices.Clone(s.latencies)
+		slices.Sort(latencies)
+		count := len(latencies) + s.errors
+		stat := ActionStats{Action: action, Count: count, Errors: s.errors}
+		if elapsed > 0 {
+			stat.Rate = float64(count) / elapsed.Seconds()
+		}
+		if len(latencies) > 0 {
+			stat.P50 = percentile(latencies, 50)
+			stat.P90 = percentile(latencies, 90)
+			stat.P99 = percentile(latencies, 99)
+			stat.Max = latencies[len(latencies)-1]
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Action < stats[j].Action })
+	return stats
+}
+
+// percentile of sorted latencies, nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+type Report struct {
+	Chargers       int           `json:"chargers"`
+	Connected      int           `json:"connected"`
+	ConnectFailed  int           `json:"connect_failed"`
+	Disconnected   int           `json:"disconnected"`
+	Elapsed        time.Duration `json:"elapsed"`
+	Transactions   int           `json:"transactions"`
+	Actions        []ActionStats `json:"actions"`
+	Events         int64         `json:"events"`
+	EventRate      float64       `json:"event_rate"`
+	EventsMeasured bool          `json:"events_measured"`
+}
+
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "chargers      %d connected, %d failed to connect, %d dropped\n", r.Connected, r.ConnectFailed, r.Disconnected)
+	fmt.Fprintf(w, "elapsed       %s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "transactions  %d\n", r.Transactions)
+	if r.EventsMeasured {
+		fmt.Fprintf(w, "events        %d into Redis (%.1f/s)\n", r.Events, r.EventRate)
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "action\tcount\terrors\trate/s\tp50\tp90\tp99\tmax\t")
+	for _, s := range r.Actions {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t\n", s.Action, s.Count, s.Errors, s.Rate,
+			round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+	}
+	tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	if d > time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
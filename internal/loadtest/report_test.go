@@ -0,0 +1,46 @@
+package loadtest
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder_Stats(t *testing.T) {
+	r := NewRecorder()
+	for i := 1; i <= 100; i++ {
+		r.Record("Heartbeat", time.Duration(i)*time.Millisecond, nil)
+	}
+	r.Record("Heartbeat", time.Second, errors.New("timeout"))
+	r.Record("Authorize", time.Millisecond, nil)
+
+	stats := r.Stats(10 * time.Second)
+	if len(stats) != 2 || stats[0].Action != "Authorize" {
+		t.Fatalf("Stats() = %+v, want sorted by action", stats)
+	}
+	heartbeat := stats[1]
+	if heartbeat.Count != 101 || heartbeat.Errors != 1 || heartbeat.Rate != 10.1 {
+		t.Errorf("counts = %+v", heartbeat)
+	}
+	if heartbeat.P50 != 50*time.Millisecond || heartbeat.P99 != 99*time.Millisecond || heartbeat.Max != 100*time.Millisecond {
+		t.Errorf("latencies = %+v, failed CALLs should not count", heartbeat)
+	}
+	if r.Count() != 102 {
+		t.Errorf("Count() = %d, want 102", r.Count())
+	}
+}
+
+func TestReport_WriteText(t *testing.T) {
+	r := NewRecorder()
+	r.Record("Heartbeat", 2*time.Millisecond, nil)
+	report := &Report{Chargers: 1, Connected: 1, Elapsed: time.Second, Actions: r.Stats(time.Second), EventsMeasured: true, Events: 5, EventRate: 5}
+	var out bytes.Buffer
+	report.WriteText(&out)
+	for _, want := range []string{"1 connected", "5 into Redis (5.0/s)", "Heartbeat", "2ms"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, out.String())
+		}
+	}
+}
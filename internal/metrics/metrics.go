@@ -0,0 +1,128 @@
+// Package metrics holds the Prometheus collectors of the central system. They
+// live on the default registry and are served by Handler.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ocpp"
+
+var (
+	ConnectedChargers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "connected_chargers",
+		Help:      "Chargers with an open WebSocket on this instance.",
+	}, []string{"version"})
+
+	// MessagesReceived counts charger CALLs; result is "ok" or the CALLERROR
+	// code we answered with.
+	MessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_received_total",
+		Help:      "OCPP CALLs received from chargers.",
+	}, []string{"version", "action", "result"})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent answering a charger CALL.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"version", "action"})
+
+	Commands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "remote_commands_total",
+		Help:      "Remote commands received on /command/.",
+	}, []string{"command", "result"})
+
+	// ProfileKeys counts configuration keys checked against a profile after
+	// boot; status is a domain.KeyStatus.
+	ProfileKeys = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "profile_keys_total",
+		Help:      "Configuration keys checked against the charger profiles.",
+	}, []string{"status"})
+
+	BackendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "backend_request_duration_seconds",
+		Help:      "Latency of backend API calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	BackendErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backend_errors_total",
+		Help:      "Backend API calls that failed.",
+	}, []string{"operation"})
+
+	BackendRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backend_retries_total",
+		Help:      "Backend API calls sent again after a failure.",
+	}, []string{"operation"})
+
+	// BackendCircuitOpen is 1 while backend calls are failed fast.
+	BackendCircuitOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backend_circuit_open",
+		Help:      "Whether the backend circuit breaker is open.",
+	})
+
+	EventPushFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_push_failures_total",
+		Help:      "Events an event sink failed to publish.",
+	}, []string{"sink", "event"})
+
+	EventSchemaViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_schema_violations_total",
+		Help:      "Events that did not match their JSON Schema.",
+	}, []string{"event"})
+
+	LiveEventClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "live_event_clients",
+		Help:      "Clients following /events/stream on this instance.",
+	})
+
+	OutboxEvents = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outbox_events",
+		Help:      "Events waiting in the disk outbox for their sink.",
+	}, []string{"sink"})
+
+	OutboxBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outbox_bytes",
+		Help:      "Size of the disk outbox segments.",
+	}, []string{"sink"})
+
+	OutboxDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_dropped_total",
+		Help:      "Events dropped because the disk outbox was full.",
+	}, []string{"sink"})
+
+	JournalDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "journal_dropped_total",
+		Help:      "Frames left out of the journal because its queue was full.",
+	})
+
+	ActiveTransactions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_transactions",
+		Help:      "Transactions in progress on chargers connected to this instance.",
+	}, []string{"version"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
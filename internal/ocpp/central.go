@@ -0,0 +1,242 @@
+package ocpp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// SupportedVersions lists the subprotocols we accept, most preferred first.
+var SupportedVersions = []Version{V201, V16}
+
+type ConnectionListener func(conn *Conn)
+
+// FrameListener sees every raw frame read from or written to a charger.
+// frame is nil when raw could not be parsed.
+type FrameListener func(conn *Conn, incoming bool, frame *Frame, raw []byte)
+
+// CentralSystem accepts charger WebSockets, negotiates the OCPP version and
+// keeps track of the live connection of every charger.
+type CentralSystem struct {
+	log      *zap.Logger
+	handler  RequestHandler
+	upgrader websocket.Upgrader
+
+	mux      sync.Mutex
+	conns    map[string]*Conn
+	draining bool
+	// active covers connection loops and the listeners they start, so a
+	// shutdown can wait for disconnect events to be sent.
+	active sync.WaitGroup
+
+	connListener    ConnectionListener
+	disconnListener ConnectionListener
+	messageListener ConnectionListener
+	frameListener   FrameListener
+	trustedProxies  []netip.Prefix
+}
+
+func NewCentralSystem(logger *zap.Logger, handler RequestHandler) *CentralSystem {
+	return &CentralSystem{
+		log:     logger,
+		handler: handler,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+		conns:           make(map[string]*Conn),
+		connListener:    func(*Conn) {},
+		disconnListener: func(*Conn) {},
+		messageListener: func(*Conn) {},
+		frameListener:   func(*Conn, bool, *Frame, []byte) {},
+	}
+}
+
+func (c *CentralSystem) SetConnectionListener(f ConnectionListener) {
+	c.connListener = f
+}
+
+func (c *CentralSystem) SetDisconnectionListener(f ConnectionListener) {
+	c.disconnListener = f
+}
+
+// SetMessageListener is called synchronously for every frame a charger
+// sends, so it must be cheap.
+func (c *CentralSystem) SetMessageListener(f ConnectionListener) {
+	c.messageListener = f
+}
+
+// SetFrameListener is called synchronously on the read loop and under the
+// write lock, so it must be cheap.
+func (c *CentralSystem) SetFrameListener(f FrameListener) {
+	c.frameListener = f
+}
+
+// SetTrustedProxies names the proxies whose X-Forwarded-For gives the
+// address of a charger.
+func (c *CentralSystem) SetTrustedProxies(proxies []netip.Prefix) {
+	c.trustedProxies = proxies
+}
+
+// Conn returns the live connection of a charger.
+func (c *CentralSystem) Conn(cpID string) (*Conn, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	conn, ok := c.conns[cpID]
+	return conn, ok
+}
+
+// Conns returns a snapshot of all live connections.
+func (c *CentralSystem) Conns() []*Conn {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	conns := make([]*Conn, 0, len(c.conns))
+	for _, conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+func (c *CentralSystem) Count() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.conns)
+}
+
+// Draining reports whether Shutdown has started.
+func (c *CentralSystem) Draining() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.draining
+}
+
+// NegotiateVersion picks the subprotocol for a handshake. Chargers that offer
+// nothing we know are treated as OCPP 1.6, which is what we always did.
+func NegotiateVersion(offered []string) Version {
+	for _, version := range SupportedVersions {
+		for _, protocol := range offered {
+			if protocol == string(version) {
+				return version
+			}
+		}
+	}
+	return V16
+}
+
+// ChargePointID builds the charger identity from the request host and path,
+// so the same charger ID under two domains stays two chargers.
+func ChargePointID(r *http.Request) (id string, host string) {
+	host, _, _ = net.SplitHostPort(r.Host)
+	if host == "" {
+		host = r.Host
+	}
+	return host + ":" + strings.Trim(r.URL.Path, "/"), host
+}
+
+func (c *CentralSystem) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		w.Write([]byte(fmt.Sprintf("<h1>OCPP Central System</h1><p>currently connected with %d stations</p>", c.Count())))
+		return
+	}
+	cpID, host := ChargePointID(r)
+	c.mux.Lock()
+	draining := c.draining
+	if !draining {
+		c.active.Add(1)
+	}
+	c.mux.Unlock()
+	if draining {
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer c.active.Done()
+	version := NegotiateVersion(websocket.Subprotocols(r))
+	header := http.Header{}
+	header.Set("Sec-WebSocket-Protocol", string(version))
+	socket, err := c.upgrader.Upgrade(w, r, header)
+	if err != nil {
+		c.log.Error("Couldn't handshake request", zap.String("cp_id", cpID), zap.Error(err))
+		return
+	}
+	conn := newConn(socket, r, cpID, host, version, c.trustedProxies, c.handler, c.log)
+	conn.onMessage = c.messageListener
+	conn.onFrame = c.frameListener
+
+	c.mux.Lock()
+	previous := c.conns[cpID]
+	c.conns[cpID] = conn
+	c.mux.Unlock()
+	if previous != nil {
+		c.log.Info("Replacing previous connection", zap.String("cp_id", cpID))
+		previous.Close()
+	}
+
+	c.log.Info("Charger connected", zap.String("cp_id", cpID), zap.String("version", string(version)))
+	metrics.ConnectedChargers.WithLabelValues(string(version)).Inc()
+	c.active.Add(1)
+	go func() {
+		defer c.active.Done()
+		c.connListener(conn)
+	}()
+
+	conn.run()
+	metrics.ConnectedChargers.WithLabelValues(string(version)).Dec()
+	conn.clearTransactions()
+
+	c.mux.Lock()
+	switch c.conns[cpID] {
+	case conn:
+		delete(c.conns, cpID)
+	case nil:
+	default:
+		conn.replaced.Store(true)
+	}
+	c.mux.Unlock()
+	c.log.Info("Charger disconnected", zap.String("cp_id", cpID), zap.Bool("replaced", conn.Replaced()))
+	c.active.Add(1)
+	go func() {
+		defer c.active.Done()
+		c.disconnListener(conn)
+	}()
+}
+
+// Shutdown stops accepting chargers, lets every connection finish its
+// in-flight CALLs, closes them, and waits for the disconnect listeners.
+func (c *CentralSystem) Shutdown(ctx context.Context) error {
+	c.mux.Lock()
+	c.draining = true
+	c.mux.Unlock()
+
+	conns := c.Conns()
+	c.log.Info("Draining chargers", zap.Int("count", len(conns)))
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn.Shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		c.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
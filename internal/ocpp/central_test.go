@@ -0,0 +1,328 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func dialCharger(t *testing.T, url, id string, protocols ...string) *websocket.Conn {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: protocols}
+	socket, _, err := dialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/"+id, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { socket.Close() })
+	return socket
+}
+
+func waitConn(t *testing.T, csys *CentralSystem, cpID string) *Conn {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if conn, ok := csys.Conn(cpID); ok {
+			return conn
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("charger %s never connected", cpID)
+	return nil
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		offered []string
+		want    Version
+	}{
+		{"none", nil, V16},
+		{"1.6", []string{"ocpp1.6"}, V16},
+		{"2.0.1", []string{"ocpp2.0.1"}, V201},
+		{"both prefers 2.0.1", []string{"ocpp1.6", "ocpp2.0.1"}, V201},
+		{"unknown", []string{"ocpp1.5"}, V16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NegotiateVersion(tt.offered); got != tt.want {
+				t.Errorf("NegotiateVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCentralSystem_HandlesCalls(t *testing.T) {
+	csys := NewCentralSystem(zap.NewNop(), func(ctx context.Context, conn *Conn, action string, payload json.RawMessage) (any, error) {
+		if action != "Heartbeat" {
+			return nil, &CallErr{Code: NotImplemented}
+		}
+		return map[string]string{"version": string(conn.Version)}, nil
+	})
+	server := httptest.NewServer(csys)
+	defer server.Close()
+
+	socket := dialCharger(t, server.URL, "CP-1", "ocpp2.0.1")
+	if socket.Subprotocol() != "ocpp2.0.1" {
+		t.Fatalf("Subprotocol() = %q, want ocpp2.0.1", socket.Subprotocol())
+	}
+
+	socket.WriteMessage(websocket.TextMessage, []byte(`[2,"1","Heartbeat",{}]`))
+	_, data, err := socket.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if string(data) != `[3,"1",{"version":"ocpp2.0.1"}]` {
+		t.Errorf("response = %s", data)
+	}
+
+	socket.WriteMessage(websocket.TextMessage, []byte(`[2,"2","Reset",{}]`))
+	_, data, _ = socket.ReadMessage()
+	if string(data) != `[4,"2","NotImplemented","",{}]` {
+		t.Errorf("response = %s", data)
+	}
+}
+
+func TestConn_Call(t *testing.T) {
+	csys := NewCentralSystem(zap.NewNop(), func(context.Context, *Conn, string, json.RawMessage) (any, error) { return struct{}{}, nil })
+	server := httptest.NewServer(csys)
+	defer server.Close()
+
+	socket := dialCharger(t, server.URL, "CP-2", "ocpp1.6")
+	conn := waitConn(t, csys, "127.0.0.1:CP-2")
+	if conn.Version != V16 {
+		t.Fatalf("Version = %v, want ocpp1.6", conn.Version)
+	}
+
+	go func() {
+		_, data, err := socket.ReadMessage()
+		if err != nil {
+			return
+		}
+		frame, _ := ParseFrame(data)
+		socket.WriteMessage(websocket.TextMessage, []byte(`[3,"`+frame.ID+`",{"status":"Accepted"}]`))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	payload, err := conn.Call(ctx, "RemoteStartTransaction", map[string]any{"idTag": "RFID"})
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if string(payload) != `{"status":"Accepted"}` {
+		t.Errorf("Call() = %s", payload)
+	}
+}
+
+func TestConn_CallError(t *testing.T) {
+	csys := NewCentralSystem(zap.NewNop(), func(context.Context, *Conn, string, json.RawMessage) (any, error) { return struct{}{}, nil })
+	server := httptest.NewServer(csys)
+	defer server.Close()
+
+	socket := dialCharger(t, server.URL, "CP-3")
+	conn := waitConn(t, csys, "127.0.0.1:CP-3")
+
+	go func() {
+		_, data, err := socket.ReadMessage()
+		if err != nil {
+			return
+		}
+		frame, _ := ParseFrame(data)
+		socket.WriteMessage(websocket.TextMessage, []byte(`[4,"`+frame.ID+`","NotSupported","nope",{}]`))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := conn.Call(ctx, "Reset", map[string]any{"type": "Soft"})
+	callErr, ok := err.(*CallErr)
+	if !ok {
+		t.Fatalf("Call() error = %v, want *CallErr", err)
+	}
+	if callErr.Code != NotSupported || callErr.Description != "nope" {
+		t.Errorf("CallErr = %+v", callErr)
+	}
+}
+
+func TestCentralSystem_Shutdown(t *testing.T) {
+	csys := NewCentralSystem(zap.NewNop(), func(context.Context, *Conn, string, json.RawMessage) (any, error) { return struct{}{}, nil })
+	disconnected := make(chan string, 1)
+	csys.SetDisconnectionListener(func(conn *Conn) {
+		time.Sleep(50 * time.Millisecond)
+		disconnected <- conn.ID
+	})
+	server := httptest.NewServer(csys)
+	defer server.Close()
+
+	socket := dialCharger(t, server.URL, "CP-1")
+	conn := waitConn(t, csys, "127.0.0.1:CP-1")
+
+	// The charger answers our CALL only after the shutdown has started.
+	callDone := make(chan error, 1)
+	go func() {
+		_, err := conn.Call(context.Background(), "Reset", map[string]any{"type": "Soft"})
+		callDone <- err
+	}()
+	_, data, err := socket.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	frame, _ := ParseFrame(data)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	shutdownDone := make(chan error, 1)
+	go func() { shutdownDone <- csys.Shutdown(ctx) }()
+
+	time.Sleep(100 * time.Millisecond)
+	dialer := websocket.Dialer{}
+	if _, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/CP-2", nil); err == nil || resp == nil || resp.StatusCode != 503 {
+		t.Errorf("new charger accepted while draining")
+	}
+
+	socket.WriteMessage(websocket.TextMessage, []byte(`[3,"`+frame.ID+`",{"status":"Accepted"}]`))
+	if err := <-callDone; err != nil {
+		t.Errorf("in-flight Call() error = %v", err)
+	}
+	if err := <-shutdownDone; err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	select {
+	case <-disconnected:
+	default:
+		t.Error("Shutdown() returned before the disconnect listener finished")
+	}
+
+	_, _, err = socket.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("ReadMessage() error = %v, want going-away close", err)
+	}
+}
+
+func TestConn_Metrics(t *testing.T) {
+	csys := NewCentralSystem(zap.NewNop(), func(context.Context, *Conn, string, json.RawMessage) (any, error) {
+		return nil, &CallErr{Code: NotImplemented}
+	})
+	server := httptest.NewServer(csys)
+	defer server.Close()
+
+	received := func(action, result string) float64 {
+		return testutil.ToFloat64(metrics.MessagesReceived.WithLabelValues("ocpp2.0.1", action, result))
+	}
+	before, unknownBefore := received("Heartbeat", "NotImplemented"), received("unknown", "NotImplemented")
+	connectedBefore := testutil.ToFloat64(metrics.ConnectedChargers.WithLabelValues("ocpp2.0.1"))
+	activeBefore := testutil.ToFloat64(metrics.ActiveTransactions.WithLabelValues("ocpp2.0.1"))
+
+	socket := dialCharger(t, server.URL, "CP-M", "ocpp2.0.1")
+	conn := waitConn(t, csys, "127.0.0.1:CP-M")
+	if got := testutil.ToFloat64(metrics.ConnectedChargers.WithLabelValues("ocpp2.0.1")); got != connectedBefore+1 {
+		t.Errorf("connected_chargers = %v, want %v", got, connectedBefore+1)
+	}
+	for _, frame := range []string{`[2,"1","Heartbeat",{}]`, `[2,"2","Garbage1",{}]`} {
+		socket.WriteMessage(websocket.TextMessage, []byte(frame))
+		socket.ReadMessage()
+	}
+	if got := received("Heartbeat", "NotImplemented"); got != before+1 {
+		t.Errorf("Heartbeat count = %v, want %v", got, before+1)
+	}
+	if got := received("unknown", "NotImplemented"); got != unknownBefore+1 {
+		t.Errorf("unknown action count = %v, want %v", got, unknownBefore+1)
+	}
+
+	conn.TrackTransaction("tx-1", true)
+	conn.TrackTransaction("tx-1", true)
+	conn.TrackTransaction("tx-2", true)
+	conn.TrackTransaction("tx-2", false)
+	if got := testutil.ToFloat64(metrics.ActiveTransactions.WithLabelValues("ocpp2.0.1")); got != activeBefore+1 {
+		t.Errorf("active_transactions = %v, want %v", got, activeBefore+1)
+	}
+	socket.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for csys.Count() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(metrics.ActiveTransactions.WithLabelValues("ocpp2.0.1")); got != activeBefore {
+		t.Errorf("active_transactions after disconnect = %v, want %v", got, activeBefore)
+	}
+}
+
+func TestConn_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	handled := make(chan trace.SpanContext, 1)
+	csys := NewCentralSystem(zap.NewNop(), func(ctx context.Context, conn *Conn, action string, payload json.RawMessage) (any, error) {
+		handled <- trace.SpanContextFromContext(ctx)
+		return struct{}{}, nil
+	})
+	server := httptest.NewServer(csys)
+	defer server.Close()
+
+	socket := dialCharger(t, server.URL, "CP-T", "ocpp1.6")
+	socket.WriteMessage(websocket.TextMessage, []byte(`[2,"msg-1","Heartbeat",{}]`))
+	socket.ReadMessage()
+
+	// The span ends after the response is written.
+	deadline := time.Now().Add(2 * time.Second)
+	for len(recorder.Ended()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "ocpp Heartbeat" {
+		t.Fatalf("spans = %v, want one ocpp Heartbeat span", spans)
+	}
+	if got := <-handled; got.SpanID() != spans[0].SpanContext().SpanID() {
+		t.Error("handler context does not carry the CALL span")
+	}
+	attrs := map[attribute.Key]string{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	if attrs["ocpp.charger_id"] != "127.0.0.1:CP-T" || attrs["ocpp.action"] != "Heartbeat" || attrs["ocpp.message_id"] != "msg-1" {
+		t.Errorf("attributes = %v", attrs)
+	}
+}
+
+func TestRemoteAddr(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded string
+		trusted   []netip.Prefix
+		want      string
+	}{
+		{"no proxy", "203.0.113.7:5000", "", trusted, "203.0.113.7"},
+		{"untrusted peer", "203.0.113.7:5000", "1.2.3.4", trusted, "203.0.113.7"},
+		{"no trusted proxies", "10.0.0.1:5000", "1.2.3.4", nil, "10.0.0.1"},
+		{"trusted peer", "10.0.0.1:5000", "1.2.3.4", trusted, "1.2.3.4"},
+		{"spoofed hop", "10.0.0.1:5000", "6.6.6.6, 1.2.3.4, 10.0.0.2", trusted, "1.2.3.4"},
+		{"all trusted", "10.0.0.1:5000", "10.0.0.3, 10.0.0.2", trusted, "10.0.0.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/charger", nil)
+			r.RemoteAddr = tt.peer
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := remoteAddr(r, tt.trusted); got != tt.want {
+				t.Errorf("remoteAddr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
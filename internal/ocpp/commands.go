@@ -0,0 +1,340 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"github.com/JscorpTech/ocpp/internal/ocpp/v201"
+	"github.com/JscorpTech/ocpp/internal/services"
+	"github.com/redis/go-redis/v9"
+	"github.com/voltbras/go-ocpp/messages/v1x/csreq"
+	"github.com/voltbras/go-ocpp/messages/v1x/csresp"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrChargerNotConnected = errors.New("Charger not connected")
+	ErrInvalidCommand      = errors.New("Invalid command")
+	ErrUnknownTransaction  = errors.New("Unknown transaction")
+)
+
+// Commands sends domain.RemoteCommand requests to chargers, speaking whatever
+// version each charger negotiated, and returns the same response shape for
+// both.
+type Commands struct {
+	csys      *CentralSystem
+	redis     redis.UniversalClient
+	snapshots services.SnapshotService
+	log       *zap.Logger
+	requestId atomic.Int64
+}
+
+func NewCommands(csys *CentralSystem, rdb redis.UniversalClient, logger *zap.Logger) *Commands {
+	c := &Commands{csys: csys, redis: rdb, snapshots: services.NewSnapshotService(rdb), log: logger}
+	c.requestId.Store(time.Now().Unix() % 1_000_000)
+	return c
+}
+
+func (c *Commands) Execute(ctx context.Context, req domain.RemoteCommandReq) (any, error) {
+	res, err := c.execute(ctx, req)
+	metrics.Commands.WithLabelValues(commandLabel(req.Command), commandResult(err)).Inc()
+	return res, err
+}
+
+func (c *Commands) execute(ctx context.Context, req domain.RemoteCommandReq) (any, error) {
+	conn, ok := c.csys.Conn(req.CpID)
+	if !ok {
+		return nil, ErrChargerNotConnected
+	}
+	switch req.Command {
+	case domain.RemoteStartTransaction:
+		var data domain.RemoteStartTransactionReq
+		if err := decodeCommand(req, &data); err != nil {
+			return nil, err
+		}
+		if conn.Version == V201 {
+			return result(c.remoteStartV201(ctx, conn, data))
+		}
+		return result(c.remoteStartV16(ctx, conn, data))
+	case domain.RemoteStopTransaction:
+		var data domain.RemoteStopTransactionReq
+		if err := decodeCommand(req, &data); err != nil {
+			return nil, err
+		}
+		if conn.Version == V201 {
+			return result(c.remoteStopV201(ctx, conn, data))
+		}
+		return result(c.remoteStopV16(ctx, conn, data))
+	case domain.GetConfiguration:
+		var data domain.GetConfigurationReq
+		if err := decodeCommand(req, &data); err != nil {
+			return nil, err
+		}
+		return result(c.getConfiguration(ctx, conn, data))
+	case domain.ChangeConfiguration:
+		var data domain.ChangeConfigurationReq
+		if err := decodeCommand(req, &data); err != nil {
+			return nil, err
+		}
+		return result(c.changeConfiguration(ctx, conn, data))
+	}
+	return nil, ErrInvalidCommand
+}
+
+// result leaves the response out when the command failed.
+func result[T any](res T, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func decodeCommand(req domain.RemoteCommandReq, data any) error {
+	if err := json.Unmarshal(req.Data, data); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCommand, err)
+	}
+	return nil
+}
+
+func (c *Commands) remoteStartV16(ctx context.Context, conn *Conn, data domain.RemoteStartTransactionReq) (domain.RemoteStartTransactionRes, error) {
+	resp, err := conn.SendV16(ctx, &csreq.RemoteStartTransaction{
+		IdTag:       data.Tag,
+		ConnectorId: data.ConnectorID,
+	})
+	if err != nil {
+		return domain.RemoteStartTransactionRes{}, err
+	}
+	return domain.RemoteStartTransactionRes{Status: resp.(*csresp.RemoteStartTransaction).Status}, nil
+}
+
+func (c *Commands) remoteStartV201(ctx context.Context, conn *Conn, data domain.RemoteStartTransactionReq) (domain.RemoteStartTransactionRes, error) {
+	var resp v201.RequestStartTransactionResponse
+	err := callV201(ctx, conn, &v201.RequestStartTransactionRequest{
+		EvseId:        int(data.ConnectorID),
+		RemoteStartId: int(c.requestId.Add(1)),
+		IdToken:       v201.IdToken{IdToken: data.Tag, Type: "Central"},
+	}, &resp)
+	if err != nil {
+		return domain.RemoteStartTransactionRes{}, err
+	}
+	return domain.RemoteStartTransactionRes{Status: resp.Status}, nil
+}
+
+func (c *Commands) remoteStopV16(ctx context.Context, conn *Conn, data domain.RemoteStopTransactionReq) (domain.RemoteStopTransactionRes, error) {
+	resp, err := conn.SendV16(ctx, &csreq.RemoteStopTransaction{TransactionId: data.TransactionId})
+	if err != nil {
+		return domain.RemoteStopTransactionRes{}, err
+	}
+	return domain.RemoteStopTransactionRes{Status: resp.(*csresp.RemoteStopTransaction).Status}, nil
+}
+
+func (c *Commands) remoteStopV201(ctx context.Context, conn *Conn, data domain.RemoteStopTransactionReq) (domain.RemoteStopTransactionRes, error) {
+	transactionId, err := c.chargerTransactionId(ctx, conn.ID, int(data.TransactionId))
+	if err != nil {
+		return domain.RemoteStopTransactionRes{}, err
+	}
+	var resp v201.RequestStopTransactionResponse
+	if err := callV201(ctx, conn, &v201.RequestStopTransactionRequest{TransactionId: transactionId}, &resp); err != nil {
+		return domain.RemoteStopTransactionRes{}, err
+	}
+	return domain.RemoteStopTransactionRes{Status: resp.Status}, nil
+}
+
+// chargerTransactionId finds the charger's own 2.0.1 transaction id for a
+// backend transaction id in the index TransactionEvent keeps.
+func (c *Commands) chargerTransactionId(ctx context.Context, cpID string, transactionId int) (string, error) {
+	id, err := c.redis.HGet(ctx, v201ChargerTransactionsKey, backendTransactionField(cpID, transactionId)).Result()
+	if err == redis.Nil {
+		return "", ErrUnknownTransaction
+	}
+	return id, err
+}
+
+func (c *Commands) getConfigurationV16(ctx context.Context, conn *Conn, data domain.GetConfigurationReq) (domain.GetConfigurationRes, error) {
+	resp, err := conn.SendV16(ctx, &csreq.GetConfiguration{Key: data.Key})
+	if err != nil {
+		return domain.GetConfigurationRes{}, err
+	}
+	res := resp.(*csresp.GetConfiguration)
+	out := domain.GetConfigurationRes{
+		ConfigurationKey: make([]domain.ConfigurationKey, 0, len(res.ConfigurationKey)),
+		UnknownKey:       res.UnknownKey,
+	}
+	for _, key := range res.ConfigurationKey {
+		out.ConfigurationKey = append(out.ConfigurationKey, domain.ConfigurationKey{
+			Key:      key.Key,
+			Value:    key.Value,
+			Readonly: key.Readonly,
+		})
+	}
+	if out.UnknownKey == nil {
+		out.UnknownKey = []string{}
+	}
+	return out, nil
+}
+
+// getConfigurationV201 reads the requested keys with GetVariables. Without
+// keys it asks for a full base report, which the charger delivers through
+// NotifyReport.
+func (c *Commands) getConfigurationV201(ctx context.Context, conn *Conn, data domain.GetConfigurationReq) (domain.GetConfigurationRes, error) {
+	out := domain.GetConfigurationRes{ConfigurationKey: []domain.ConfigurationKey{}, UnknownKey: []string{}}
+	if len(data.Key) == 0 {
+		requestId := int(c.requestId.Add(1))
+		var resp v201.GetBaseReportResponse
+		if err := callV201(ctx, conn, &v201.GetBaseReportRequest{RequestId: requestId, ReportBase: "ConfigurationInventory"}, &resp); err != nil {
+			return domain.GetConfigurationRes{}, err
+		}
+		if resp.Status != "Accepted" {
+			return domain.GetConfigurationRes{}, fmt.Errorf("GetBaseReport %s", resp.Status)
+		}
+		out.ReportRequestId = requestId
+		return out, nil
+	}
+
+	request := &v201.GetVariablesRequest{}
+	keys := map[string]string{}
+	for _, key := range data.Key {
+		component, variable, ok := v201.ParseVariableKey(key)
+		if !ok {
+			out.UnknownKey = append(out.UnknownKey, key)
+			continue
+		}
+		keys[v201.VariableKey(component, variable)] = key
+		request.GetVariableData = append(request.GetVariableData, v201.GetVariableData{Component: component, Variable: variable})
+	}
+	if len(request.GetVariableData) == 0 {
+		return out, nil
+	}
+	var resp v201.GetVariablesResponse
+	if err := callV201(ctx, conn, request, &resp); err != nil {
+		return domain.GetConfigurationRes{}, err
+	}
+	for _, result := range resp.GetVariableResult {
+		key, ok := keys[v201.VariableKey(result.Component, result.Variable)]
+		if !ok {
+			key = v201.VariableKey(result.Component, result.Variable)
+		}
+		if result.AttributeStatus != "Accepted" {
+			out.UnknownKey = append(out.UnknownKey, key)
+			continue
+		}
+		out.ConfigurationKey = append(out.ConfigurationKey, domain.ConfigurationKey{Key: key, Value: result.AttributeValue})
+	}
+	return out, nil
+}
+
+func (c *Commands) changeConfigurationV16(ctx context.Context, conn *Conn, data domain.ChangeConfigurationReq) (domain.ChangeConfigurationRes, error) {
+	resp, err := conn.SendV16(ctx, &csreq.ChangeConfiguration{Key: data.Key, Value: data.Value})
+	if err != nil {
+		return domain.ChangeConfigurationRes{}, err
+	}
+	return domain.ChangeConfigurationRes{Status: resp.(*csresp.ChangeConfiguration).Status}, nil
+}
+
+func (c *Commands) changeConfigurationV201(ctx context.Context, conn *Conn, data domain.ChangeConfigurationReq) (domain.ChangeConfigurationRes, error) {
+	component, variable, ok := v201.ParseVariableKey(data.Key)
+	if !ok {
+		return domain.ChangeConfigurationRes{Status: "NotSupported"}, nil
+	}
+	var resp v201.SetVariablesResponse
+	err := callV201(ctx, conn, &v201.SetVariablesRequest{SetVariableData: []v201.SetVariableData{{
+		AttributeValue: data.Value,
+		Component:      component,
+		Variable:       variable,
+	}}}, &resp)
+	if err != nil {
+		return domain.ChangeConfigurationRes{}, err
+	}
+	if len(resp.SetVariableResult) == 0 {
+		return domain.ChangeConfigurationRes{}, errors.New("SetVariables returned no result")
+	}
+	return domain.ChangeConfigurationRes{Status: setVariableStatusV16(resp.SetVariableResult[0].AttributeStatus)}, nil
+}
+
+// getConfiguration reads keys from a charger of either version and records
+// the answer in its snapshot.
+func (c *Commands) getConfiguration(ctx context.Context, conn *Conn, data domain.GetConfigurationReq) (domain.GetConfigurationRes, error) {
+	get := c.getConfigurationV16
+	if conn.Version == V201 {
+		get = c.getConfigurationV201
+	}
+	out, err := get(ctx, conn, data)
+	if err != nil {
+		return domain.GetConfigurationRes{}, err
+	}
+	// A 2.0.1 full read only starts a report, which NotifyReport records.
+	full := len(data.Key) == 0 && out.ReportRequestId == 0
+	if err := c.snapshots.Record(ctx, conn.ID, out.ConfigurationKey, out.UnknownKey, full); err != nil {
+		c.log.Error("Configuration snapshot error", zap.String("cp_id", conn.ID), zap.Error(err))
+	}
+	return out, nil
+}
+
+// changeConfiguration changes a key on a charger of either version and
+// records an accepted value in its snapshot.
+func (c *Commands) changeConfiguration(ctx context.Context, conn *Conn, data domain.ChangeConfigurationReq) (domain.ChangeConfigurationRes, error) {
+	change := c.changeConfigurationV16
+	if conn.Version == V201 {
+		change = c.changeConfigurationV201
+	}
+	out, err := change(ctx, conn, data)
+	if err != nil {
+		return domain.ChangeConfigurationRes{}, err
+	}
+	if out.Status == "Accepted" || out.Status == "RebootRequired" {
+		if err := c.snapshots.Changed(ctx, conn.ID, data.Key, data.Value, out.Status == "RebootRequired"); err != nil {
+			c.log.Error("Configuration snapshot error", zap.String("cp_id", conn.ID), zap.Error(err))
+		}
+	}
+	return out, nil
+}
+
+// setVariableStatusV16 maps SetVariableStatus onto the 1.6 ConfigurationStatus values.
+func setVariableStatusV16(status string) string {
+	switch status {
+	case "Accepted", "Rejected", "RebootRequired":
+		return status
+	}
+	return "NotSupported"
+}
+
+func callV201(ctx context.Context, conn *Conn, req v201.Request, resp any) error {
+	payload, err := conn.Call(ctx, req.Action(), req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(payload, resp); err != nil {
+		return fmt.Errorf("%s response: %w", req.Action(), err)
+	}
+	return nil
+}
+
+func commandLabel(command domain.RemoteCommand) string {
+	switch command {
+	case domain.RemoteStartTransaction, domain.RemoteStopTransaction, domain.GetConfiguration, domain.ChangeConfiguration:
+		return string(command)
+	}
+	return "unknown"
+}
+
+// commandResult classifies the outcome of a command for metrics.
+func commandResult(err error) string {
+	var callErr *CallErr
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrChargerNotConnected), errors.Is(err, ErrConnClosed):
+		return "not_connected"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &callErr):
+		return "call_error"
+	}
+	return "error"
+}
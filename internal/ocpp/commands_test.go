@@ -0,0 +1,230 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// fakeCharger answers every CALL it receives with the payload registered for
+// the action and records the request payloads.
+type fakeCharger struct {
+	socket   *websocket.Conn
+	answers  map[string]string
+	received chan *Frame
+}
+
+func newFakeCharger(t *testing.T, url, id string, answers map[string]string, protocols ...string) *fakeCharger {
+	charger := &fakeCharger{
+		socket:   dialCharger(t, url, id, protocols...),
+		answers:  answers,
+		received: make(chan *Frame, 8),
+	}
+	go func() {
+		for {
+			_, data, err := charger.socket.ReadMessage()
+			if err != nil {
+				return
+			}
+			frame, err := ParseFrame(data)
+			if err != nil || frame.Type != Call {
+				continue
+			}
+			charger.received <- frame
+			charger.socket.WriteMessage(websocket.TextMessage, []byte(`[3,"`+frame.ID+`",`+charger.answers[frame.Action]+`]`))
+		}
+	}()
+	return charger
+}
+
+func setupCommands(t *testing.T) (*Commands, string) {
+	csys := NewCentralSystem(zap.NewNop(), func(context.Context, *Conn, string, json.RawMessage) (any, error) { return struct{}{}, nil })
+	server := httptest.NewServer(csys)
+	t.Cleanup(server.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	return NewCommands(csys, rdb, zap.NewNop()), server.URL
+}
+
+func executeCommand(t *testing.T, commands *Commands, cpID string, command domain.RemoteCommand, data string) (any, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return commands.Execute(ctx, domain.RemoteCommandReq{CpID: cpID, Command: command, Data: json.RawMessage(data)})
+}
+
+func TestCommands_NotConnected(t *testing.T) {
+	commands, _ := setupCommands(t)
+	_, err := executeCommand(t, commands, "127.0.0.1:missing", domain.RemoteStartTransaction, `{}`)
+	if !errors.Is(err, ErrChargerNotConnected) {
+		t.Errorf("Execute() error = %v, want ErrChargerNotConnected", err)
+	}
+}
+
+func TestCommands_RemoteStartTransaction(t *testing.T) {
+	commands, url := setupCommands(t)
+	v16 := newFakeCharger(t, url, "CP-16", map[string]string{"RemoteStartTransaction": `{"status":"Accepted"}`}, "ocpp1.6")
+	v201 := newFakeCharger(t, url, "CP-201", map[string]string{"RequestStartTransaction": `{"status":"Rejected"}`}, "ocpp2.0.1")
+	waitConn(t, commands.csys, "127.0.0.1:CP-16")
+	waitConn(t, commands.csys, "127.0.0.1:CP-201")
+
+	res, err := executeCommand(t, commands, "127.0.0.1:CP-16", domain.RemoteStartTransaction, `{"tag":"RFID","connector_id":1}`)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res != (domain.RemoteStartTransactionRes{Status: "Accepted"}) {
+		t.Errorf("1.6 response = %+v", res)
+	}
+	if frame := <-v16.received; frame.Action != "RemoteStartTransaction" {
+		t.Errorf("1.6 action = %v", frame.Action)
+	}
+
+	res, err = executeCommand(t, commands, "127.0.0.1:CP-201", domain.RemoteStartTransaction, `{"tag":"RFID","connector_id":2}`)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res != (domain.RemoteStartTransactionRes{Status: "Rejected"}) {
+		t.Errorf("2.0.1 response = %+v", res)
+	}
+	frame := <-v201.received
+	if frame.Action != "RequestStartTransaction" {
+		t.Fatalf("2.0.1 action = %v", frame.Action)
+	}
+	var payload struct {
+		EvseId  int `json:"evseId"`
+		IdToken struct {
+			IdToken string `json:"idToken"`
+		} `json:"idToken"`
+	}
+	json.Unmarshal(frame.Payload, &payload)
+	if payload.EvseId != 2 || payload.IdToken.IdToken != "RFID" {
+		t.Errorf("2.0.1 payload = %s", frame.Payload)
+	}
+}
+
+func TestCommands_RemoteStopTransactionV201(t *testing.T) {
+	commands, url := setupCommands(t)
+	charger := newFakeCharger(t, url, "CP-201", map[string]string{"RequestStopTransaction": `{"status":"Accepted"}`}, "ocpp2.0.1")
+	waitConn(t, commands.csys, "127.0.0.1:CP-201")
+	commands.redis.HSet(context.Background(), v201ChargerTransactionsKey, "127.0.0.1:CP-201/7", "tx-7")
+
+	res, err := executeCommand(t, commands, "127.0.0.1:CP-201", domain.RemoteStopTransaction, `{"transaction_id":7}`)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res != (domain.RemoteStopTransactionRes{Status: "Accepted"}) {
+		t.Errorf("response = %+v", res)
+	}
+	var payload struct {
+		TransactionId string `json:"transactionId"`
+	}
+	json.Unmarshal((<-charger.received).Payload, &payload)
+	if payload.TransactionId != "tx-7" {
+		t.Errorf("transactionId = %q, want the charger's tx-7", payload.TransactionId)
+	}
+
+	res, err = executeCommand(t, commands, "127.0.0.1:CP-201", domain.RemoteStopTransaction, `{"transaction_id":8}`)
+	if !errors.Is(err, ErrUnknownTransaction) || res != nil {
+		t.Errorf("Execute() = %v, %v, want ErrUnknownTransaction", res, err)
+	}
+}
+
+func TestCommands_ConfigurationV201(t *testing.T) {
+	commands, url := setupCommands(t)
+	charger := newFakeCharger(t, url, "CP-201", map[string]string{
+		"GetVariables": `{"getVariableResult":[
+			{"attributeStatus":"Accepted","attributeValue":"300","component":{"name":"OCPPCommCtrlr"},"variable":{"name":"HeartbeatInterval"}},
+			{"attributeStatus":"UnknownVariable","component":{"name":"Foo"},"variable":{"name":"Bar"}}
+		]}`,
+		"SetVariables": `{"setVariableResult":[{"attributeStatus":"UnknownComponent","component":{"name":"Foo"},"variable":{"name":"Bar"}}]}`,
+	}, "ocpp2.0.1")
+	waitConn(t, commands.csys, "127.0.0.1:CP-201")
+
+	res, err := executeCommand(t, commands, "127.0.0.1:CP-201", domain.GetConfiguration, `{"key":["HeartbeatInterval","Foo.Bar","nokey"]}`)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	config := res.(domain.GetConfigurationRes)
+	if len(config.ConfigurationKey) != 1 || config.ConfigurationKey[0] != (domain.ConfigurationKey{Key: "HeartbeatInterval", Value: "300"}) {
+		t.Errorf("ConfigurationKey = %+v", config.ConfigurationKey)
+	}
+	if len(config.UnknownKey) != 2 {
+		t.Errorf("UnknownKey = %v, want nokey and Foo.Bar", config.UnknownKey)
+	}
+	<-charger.received
+
+	res, err = executeCommand(t, commands, "127.0.0.1:CP-201", domain.ChangeConfiguration, `{"key":"Foo.Bar","value":"1"}`)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res != (domain.ChangeConfigurationRes{Status: "NotSupported"}) {
+		t.Errorf("ChangeConfiguration = %+v", res)
+	}
+}
+
+func TestCommands_InvalidCommand(t *testing.T) {
+	commands, url := setupCommands(t)
+	newFakeCharger(t, url, "CP-1", nil)
+	waitConn(t, commands.csys, "127.0.0.1:CP-1")
+
+	_, err := executeCommand(t, commands, "127.0.0.1:CP-1", "reboot", `{}`)
+	if !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("Execute() error = %v, want ErrInvalidCommand", err)
+	}
+	_, err = executeCommand(t, commands, "127.0.0.1:CP-1", domain.RemoteStartTransaction, `[]`)
+	if !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("Execute() error = %v, want ErrInvalidCommand", err)
+	}
+}
+
+func TestCommandResult(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "ok"},
+		{ErrChargerNotConnected, "not_connected"},
+		{fmt.Errorf("Reset: %w", context.DeadlineExceeded), "timeout"},
+		{&CallErr{Code: NotSupported}, "call_error"},
+		{errors.New("boom"), "error"},
+	}
+	for _, tt := range tests {
+		if got := commandResult(tt.err); got != tt.want {
+			t.Errorf("commandResult(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCommands_ConfigurationSnapshot(t *testing.T) {
+	commands, url := setupCommands(t)
+	charger := newFakeCharger(t, url, "CP-1", map[string]string{
+		"GetConfiguration":    `{"configurationKey":[{"key":"HeartbeatInterval","readonly":false,"value":"60"}],"unknownKey":["Foo"]}`,
+		"ChangeConfiguration": `{"status":"Accepted"}`,
+	})
+	waitConn(t, commands.csys, "127.0.0.1:CP-1")
+
+	if _, err := executeCommand(t, commands, "127.0.0.1:CP-1", domain.GetConfiguration, `{"key":["HeartbeatInterval","Foo"]}`); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if _, err := executeCommand(t, commands, "127.0.0.1:CP-1", domain.ChangeConfiguration, `{"key":"HeartbeatInterval","value":"300"}`); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	charger.socket.Close()
+
+	snapshot, err := commands.snapshots.Get(context.Background(), "127.0.0.1:CP-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(snapshot.ConfigurationKey) != 1 || snapshot.ConfigurationKey[0].Value != "300" || len(snapshot.UnknownKey) != 1 {
+		t.Errorf("snapshot = %+v, want HeartbeatInterval changed to 300 and Foo unknown", snapshot)
+	}
+}
@@ -0,0 +1,452 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"github.com/JscorpTech/ocpp/internal/ocpp/v201"
+	"github.com/JscorpTech/ocpp/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/voltbras/go-ocpp/messages"
+	actions "github.com/voltbras/go-ocpp/messages/req"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const DefaultCallTimeout = 60 * time.Second
+
+var ErrConnClosed = errors.New("charger connection closed")
+
+// RequestHandler answers a CALL sent by a charger. The returned value is
+// encoded as the CALLRESULT payload; an error becomes a CALLERROR. ctx carries
+// the span of the CALL.
+type RequestHandler func(ctx context.Context, conn *Conn, action string, payload json.RawMessage) (any, error)
+
+// Conn is a single charger WebSocket session.
+type Conn struct {
+	ID      string
+	Host    string
+	Version Version
+	Request *http.Request
+	// RemoteAddr is the charger's address, as seen through any trusted proxy.
+	RemoteAddr string
+	// ConnectedAt is when the WebSocket handshake completed.
+	ConnectedAt time.Time
+	// SessionID tells this connection apart from earlier and later ones of
+	// the same charger.
+	SessionID string
+
+	lastMessageAt     atomic.Int64
+	heartbeatInterval atomic.Int64
+	onMessage         func(*Conn)
+	onFrame           FrameListener
+	// inflight counts CALLs in either direction that still wait for an answer.
+	inflight atomic.Int32
+	replaced atomic.Bool
+	// afterResponse is set by the handler of the CALL being served; only the
+	// serveCalls goroutine touches it.
+	afterResponse func()
+
+	socket   *websocket.Conn
+	log      *zap.Logger
+	handler  RequestHandler
+	writeMux sync.Mutex
+
+	pendingMux sync.Mutex
+	pending    map[string]chan *Frame
+
+	calls     chan *Frame
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	txMux        sync.Mutex
+	transactions map[string]struct{}
+}
+
+func newConn(socket *websocket.Conn, r *http.Request, id, host string, version Version, trusted []netip.Prefix, handler RequestHandler, logger *zap.Logger) *Conn {
+	conn := &Conn{
+		ID:           id,
+		Host:         host,
+		Version:      version,
+		Request:      r,
+		RemoteAddr:   remoteAddr(r, trusted),
+		ConnectedAt:  time.Now(),
+		SessionID:    uuid.New().String(),
+		onMessage:    func(*Conn) {},
+		onFrame:      func(*Conn, bool, *Frame, []byte) {},
+		socket:       socket,
+		log:          logger.With(zap.String("cp_id", id), zap.String("version", string(version))),
+		handler:      handler,
+		pending:      make(map[string]chan *Frame),
+		calls:        make(chan *Frame, 16),
+		closed:       make(chan struct{}),
+		transactions: make(map[string]struct{}),
+	}
+	conn.lastMessageAt.Store(conn.ConnectedAt.UnixNano())
+	return conn
+}
+
+// remoteAddr believes X-Forwarded-For only from a trusted peer, and walks it
+// from the right past the trusted hops: what is left of them the charger
+// could have written itself.
+func remoteAddr(r *http.Request, trusted []netip.Prefix) string {
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if !isTrusted(addr, trusted) {
+		return addr
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		addr = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+	return addr
+}
+
+func isTrusted(addr string, trusted []netip.Prefix) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replaced reports whether a newer connection of the same charger took over,
+// in which case closing this one does not mean the charger left.
+func (c *Conn) Replaced() bool {
+	return c.replaced.Load()
+}
+
+// LastMessageAt is when the charger last sent anything, or the connect time.
+func (c *Conn) LastMessageAt() time.Time {
+	return time.Unix(0, c.lastMessageAt.Load())
+}
+
+// HeartbeatInterval is the interval agreed in BootNotification, or zero if
+// the charger has not booted on this connection.
+func (c *Conn) HeartbeatInterval() time.Duration {
+	return time.Duration(c.heartbeatInterval.Load())
+}
+
+func (c *Conn) SetHeartbeatInterval(interval time.Duration) {
+	c.heartbeatInterval.Store(int64(interval))
+}
+
+// TrackTransaction marks a transaction of this charger as running or
+// finished, for the active transactions gauge.
+func (c *Conn) TrackTransaction(id string, active bool) {
+	c.txMux.Lock()
+	defer c.txMux.Unlock()
+	_, known := c.transactions[id]
+	switch {
+	case active && !known:
+		c.transactions[id] = struct{}{}
+		metrics.ActiveTransactions.WithLabelValues(string(c.Version)).Inc()
+	case !active && known:
+		delete(c.transactions, id)
+		metrics.ActiveTransactions.WithLabelValues(string(c.Version)).Dec()
+	}
+}
+
+// clearTransactions drops the charger's transactions from the gauge once it
+// disconnects; the instance it reconnects to picks them up again.
+func (c *Conn) clearTransactions() {
+	c.txMux.Lock()
+	defer c.txMux.Unlock()
+	metrics.ActiveTransactions.WithLabelValues(string(c.Version)).Sub(float64(len(c.transactions)))
+	clear(c.transactions)
+}
+
+// AfterResponse runs f on its own goroutine once the answer to the CALL
+// being handled is sent, so f may send CALLs the charger only expects after
+// that answer. Call it from a RequestHandler only.
+func (c *Conn) AfterResponse(f func()) {
+	c.afterResponse = f
+}
+
+// Call sends a CALL to the charger and waits for its CALLRESULT payload.
+// A CALLERROR is returned as *CallErr.
+func (c *Conn) Call(ctx context.Context, action string, payload any) (json.RawMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	c.inflight.Add(1)
+	defer c.inflight.Add(-1)
+	id := uuid.New().String()
+	ctx, span := tracing.Tracer().Start(ctx, "ocpp.call "+action, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		tracing.ChargerID.String(c.ID),
+		tracing.Action.String(action),
+		tracing.MessageID.String(id),
+		tracing.Version.String(string(c.Version)),
+	))
+	defer span.End()
+	result, err := c.call(ctx, id, action, data)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return result, err
+}
+
+func (c *Conn) call(ctx context.Context, id, action string, data json.RawMessage) (json.RawMessage, error) {
+	result := make(chan *Frame, 1)
+	c.pendingMux.Lock()
+	c.pending[id] = result
+	c.pendingMux.Unlock()
+	defer func() {
+		c.pendingMux.Lock()
+		delete(c.pending, id)
+		c.pendingMux.Unlock()
+	}()
+
+	if err := c.write(&Frame{Type: Call, ID: id, Action: action, Payload: data}); err != nil {
+		return nil, err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultCallTimeout)
+		defer cancel()
+	}
+	select {
+	case frame := <-result:
+		if frame.Type == CallError {
+			return nil, frame.callErr()
+		}
+		return frame.Payload, nil
+	case <-c.closed:
+		return nil, ErrConnClosed
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", action, ctx.Err())
+	}
+}
+
+// SendV16 sends an OCPP 1.6 central system request and decodes the matching
+// response type.
+func (c *Conn) SendV16(ctx context.Context, req messages.Request) (messages.Response, error) {
+	payload, err := c.Call(ctx, req.Action(), req)
+	if err != nil {
+		return nil, err
+	}
+	resp := req.GetResponse()
+	if err := json.Unmarshal(payload, resp); err != nil {
+		return nil, fmt.Errorf("%s response: %w", req.Action(), err)
+	}
+	return resp, nil
+}
+
+// Close closes the socket; the read loop then returns.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.socket.Close()
+}
+
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+// Shutdown waits until no CALL is in flight in either direction and then
+// closes the socket with a going-away close frame. When ctx expires first the
+// socket is closed anyway.
+func (c *Conn) Shutdown(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	var err error
+	for c.inflight.Load() > 0 && err == nil {
+		select {
+		case <-ticker.C:
+		case <-c.closed:
+			return nil
+		case <-ctx.Done():
+			err = ctx.Err()
+			c.log.Warn("Closing connection with calls in flight", zap.Int32("inflight", c.inflight.Load()))
+		}
+	}
+	c.writeMux.Lock()
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	_ = c.socket.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	c.writeMux.Unlock()
+	c.Close()
+	return err
+}
+
+func (c *Conn) write(frame *Frame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+	c.log.Debug("Sending message", zap.ByteString("raw", data))
+	if err := c.socket.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
+	}
+	c.onFrame(c, false, frame, data)
+	return nil
+}
+
+// run reads frames until the socket fails. Incoming CALLs are handled one by
+// one on a separate goroutine so a slow handler never blocks the responses to
+// our own CALLs.
+func (c *Conn) run() {
+	go c.serveCalls()
+	defer c.Close()
+	for {
+		_, data, err := c.socket.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.log.Warn("Read error", zap.Error(err))
+			}
+			return
+		}
+		c.lastMessageAt.Store(time.Now().UnixNano())
+		c.onMessage(c)
+		c.log.Debug("Received message", zap.ByteString("raw", data))
+		frame, err := ParseFrame(data)
+		if err != nil {
+			c.onFrame(c, true, nil, data)
+			c.log.Warn("Invalid frame", zap.Error(err))
+			if frame != nil && frame.Type == Call {
+				_ = c.write(newCallErrorFrame(frame.ID, &CallErr{Code: FormationViolation, Description: err.Error()}))
+			}
+			continue
+		}
+		c.onFrame(c, true, frame, data)
+		switch frame.Type {
+		case Call:
+			c.inflight.Add(1)
+			select {
+			case c.calls <- frame:
+			case <-c.closed:
+				c.inflight.Add(-1)
+				return
+			}
+		case CallResult, CallError:
+			c.pendingMux.Lock()
+			result, ok := c.pending[frame.ID]
+			c.pendingMux.Unlock()
+			if !ok {
+				c.log.Warn("Response without pending call", zap.String("message_id", frame.ID))
+				continue
+			}
+			select {
+			case result <- frame:
+			default:
+			}
+		}
+	}
+}
+
+func (c *Conn) serveCalls() {
+	for {
+		select {
+		case frame := <-c.calls:
+			c.serveCall(frame)
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *Conn) serveCall(frame *Frame) {
+	defer c.inflight.Add(-1)
+	start := time.Now()
+	action := actionLabel(c.Version, frame.Action)
+	ctx, span := tracing.Tracer().Start(context.Background(), "ocpp "+action, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		tracing.ChargerID.String(c.ID),
+		tracing.Action.String(frame.Action),
+		tracing.MessageID.String(frame.ID),
+		tracing.Version.String(string(c.Version)),
+	))
+	defer span.End()
+	resp, err := c.handle(ctx, frame)
+	var out *Frame
+	if err != nil {
+		out = newCallErrorFrame(frame.ID, err)
+	} else {
+		payload, merr := json.Marshal(resp)
+		if merr != nil {
+			out = newCallErrorFrame(frame.ID, merr)
+		} else {
+			out = &Frame{Type: CallResult, ID: frame.ID, Payload: payload}
+		}
+	}
+	result := "ok"
+	if out.Type == CallError {
+		result = string(out.ErrorCode)
+		span.SetStatus(codes.Error, out.ErrorDescription)
+		span.SetAttributes(attribute.String("ocpp.error_code", result))
+		c.log.Warn("Call rejected",
+			zap.String("action", frame.Action),
+			zap.String("message_id", frame.ID),
+			zap.String("error_code", result),
+			zap.String("description", out.ErrorDescription),
+			zap.ByteString("details", out.ErrorDetails),
+		)
+	}
+	metrics.HandlerDuration.WithLabelValues(string(c.Version), action).Observe(time.Since(start).Seconds())
+	metrics.MessagesReceived.WithLabelValues(string(c.Version), action, result).Inc()
+	after := c.afterResponse
+	c.afterResponse = nil
+	if err := c.write(out); err != nil {
+		c.log.Error("Write error", zap.Error(err))
+		return
+	}
+	if after != nil {
+		go after()
+	}
+}
+
+// actionLabel keeps metric labels bounded: actions from the charger that are
+// not part of its OCPP version are counted as "unknown".
+func actionLabel(version Version, action string) string {
+	if version == V201 {
+		if v201.NewRequest(action) == nil {
+			return "unknown"
+		}
+		return action
+	}
+	if actions.FromActionName(action) == nil {
+		return "unknown"
+	}
+	return action
+}
+
+// handle runs the handler, turning a panic into an InternalError so one bad
+// message cannot take the whole server down.
+func (c *Conn) handle(ctx context.Context, frame *Frame) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.log.Error("Handler panic", zap.String("action", frame.Action), zap.Any("panic", r))
+			resp, err = nil, &CallErr{Code: InternalError, Description: fmt.Sprint(r)}
+		}
+	}()
+	return c.handler(ctx, c, frame.Action, frame.Payload)
+}
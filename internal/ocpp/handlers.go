@@ -2,6 +2,7 @@ package ocpp
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/JscorpTech/ocpp/internal/client"
@@ -17,46 +18,97 @@ import (
 
 type Handlers struct {
 	Logger            *zap.Logger
-	redis             *redis.Client
+	redis             redis.UniversalClient
 	ctx               context.Context
 	metadata          cs.ChargePointRequestMetadata
 	event             services.EventService
 	transactionClient client.TransactionClient
+	cfg               *config.Config
 }
 
-func NewHandler(ctx context.Context, logger *zap.Logger, rdb *redis.Client, metadata cs.ChargePointRequestMetadata, cfg *config.Config, event services.EventService) *Handlers {
+func NewHandler(ctx context.Context, logger *zap.Logger, rdb redis.UniversalClient, backend client.TransactionClient, metadata cs.ChargePointRequestMetadata, cfg *config.Config, event services.EventService) *Handlers {
 	return &Handlers{
 		Logger:            logger,
 		redis:             rdb,
 		ctx:               ctx,
 		metadata:          metadata,
 		event:             event,
-		transactionClient: client.NewTransactionClient(cfg),
+		transactionClient: backend,
+		cfg:               cfg,
 	}
 }
 
+// maxIdTagLength is the length of the CiString20Type of idTag.
+const maxIdTagLength = 20
+
+// backendError is what the charger gets when the backend fails: an
+// InternalError. The cause is only logged, it may name internal hosts.
+func (h *Handlers) backendError(err error) *CallErr {
+	h.Logger.Error("Backend error", zap.String("cp_id", h.metadata.ChargePointID), zap.Error(err))
+	return &CallErr{Code: InternalError, Description: "Backend unavailable"}
+}
+
+// propertyViolation rejects a field whose value OCPP does not allow.
+func propertyViolation(field string, value any, description string) *CallErr {
+	return &CallErr{
+		Code:        PropertyConstraintViolation,
+		Description: description,
+		Details:     map[string]any{"field": field, "value": value},
+	}
+}
+
+func checkIdTag(tag string) *CallErr {
+	if tag == "" {
+		return &CallErr{Code: OccurrenceConstraintViolation, Description: "idTag is required", Details: map[string]any{"field": "idTag"}}
+	}
+	if len(tag) > maxIdTagLength {
+		return propertyViolation("idTag", tag, fmt.Sprintf("idTag is longer than %d characters", maxIdTagLength))
+	}
+	return nil
+}
+
+// heartbeatInterval is the interval handed out in BootNotification, in seconds.
+func (h *Handlers) heartbeatInterval() int {
+	if h.cfg.HeartbeatInterval <= 0 {
+		return 60
+	}
+	return int(h.cfg.HeartbeatInterval.Seconds())
+}
+
 func (h *Handlers) MeterValues(req *cpreq.MeterValues) (cpresp.ChargePointResponse, error) {
+	if req.ConnectorId < 0 {
+		return nil, propertyViolation("connectorId", req.ConnectorId, "connectorId must not be negative")
+	}
 	event := domain.Event{
 		Domain: h.metadata.Host,
+		CpID:   h.metadata.ChargePointID,
 		Event:  domain.MeterValuesEvent,
 		Data: domain.MeterValues{
 			Conn:          req.ConnectorId,
 			TransactionId: req.TransactionId,
-			MeterValue:    req.MeterValue,
+			MeterValue:    meterValues(req.MeterValue),
 		},
 	}
-	h.event.SendEvent(h.ctx, h.redis, &event, h.Logger)
+	h.event.SendEvent(h.ctx, &event, h.Logger)
 	return &cpresp.MeterValues{}, nil
 }
 
 func (h *Handlers) StartTransaction(req *cpreq.StartTransaction) (cpresp.ChargePointResponse, error) {
-	transaction, err := h.transactionClient.GetTransactionFromTag(req.IdTag)
+	if req.ConnectorId <= 0 {
+		return nil, propertyViolation("connectorId", req.ConnectorId, "connectorId must be greater than 0")
+	}
+	if err := checkIdTag(req.IdTag); err != nil {
+		return nil, err
+	}
+	transaction, err := h.transactionClient.GetTransactionFromTag(h.ctx, req.IdTag)
 	if err != nil {
-		panic(err)
+		return nil, h.backendError(err)
 	}
 	event := domain.Event{
-		Domain: h.metadata.Host,
-		Event:  domain.StartTransactionEvent,
+		Domain:     h.metadata.Host,
+		CpID:       h.metadata.ChargePointID,
+		OccurredAt: req.Timestamp,
+		Event:      domain.StartTransactionEvent,
 		Data: domain.StartTransaction{
 			Charger:    h.metadata.ChargePointID,
 			Conn:       req.ConnectorId,
@@ -64,7 +116,7 @@ func (h *Handlers) StartTransaction(req *cpreq.StartTransaction) (cpresp.ChargeP
 			MeterStart: req.MeterStart,
 		},
 	}
-	h.event.SendEvent(h.ctx, h.redis, &event, h.Logger)
+	h.event.SendEvent(h.ctx, &event, h.Logger)
 	return &cpresp.StartTransaction{
 		IdTagInfo: &cpresp.IdTagInfo{
 			Status: "Accepted",
@@ -75,8 +127,10 @@ func (h *Handlers) StartTransaction(req *cpreq.StartTransaction) (cpresp.ChargeP
 
 func (h *Handlers) StopTransaction(req *cpreq.StopTransaction) (cpresp.ChargePointResponse, error) {
 	event := domain.Event{
-		Domain: h.metadata.Host,
-		Event:  domain.StopTransactionEvent,
+		Domain:     h.metadata.Host,
+		CpID:       h.metadata.ChargePointID,
+		OccurredAt: req.Timestamp,
+		Event:      domain.StopTransactionEvent,
 		Data: domain.StopTransaction{
 			Charger:       h.metadata.ChargePointID,
 			TransactionId: req.TransactionId,
@@ -84,7 +138,7 @@ func (h *Handlers) StopTransaction(req *cpreq.StopTransaction) (cpresp.ChargePoi
 			MeterStop:     req.MeterStop,
 		},
 	}
-	h.event.SendEvent(h.ctx, h.redis, &event, h.Logger)
+	h.event.SendEvent(h.ctx, &event, h.Logger)
 	return &cpresp.StopTransaction{
 		IdTagInfo: &cpresp.IdTagInfo{
 			Status: "Accepted",
@@ -95,18 +149,23 @@ func (h *Handlers) StopTransaction(req *cpreq.StopTransaction) (cpresp.ChargePoi
 func (h *Handlers) Heartbeart(req *cpreq.Heartbeat) (cpresp.ChargePointResponse, error) {
 	event := domain.Event{
 		Domain: h.metadata.Host,
+		CpID:   h.metadata.ChargePointID,
 		Event:  domain.HealthEvent,
 		Data: domain.Healthcheck{
 			Charger: h.metadata.ChargePointID,
 		},
 	}
-	h.event.SendEvent(h.ctx, h.redis, &event, h.Logger)
+	h.event.SendEvent(h.ctx, &event, h.Logger)
 	return &cpresp.Heartbeat{CurrentTime: time.Now()}, nil
 }
 
 func (h *Handlers) StatusNotification(req *cpreq.StatusNotification) (cpresp.ChargePointResponse, error) {
+	if req.ConnectorId < 0 {
+		return nil, propertyViolation("connectorId", req.ConnectorId, "connectorId must not be negative")
+	}
 	event := domain.Event{
 		Domain: h.metadata.Host,
+		CpID:   h.metadata.ChargePointID,
 		Event:  domain.ChangeConnectorStatusEvent,
 		Data: domain.ChangeConnectorStatus{
 			Charger: h.metadata.ChargePointID,
@@ -114,11 +173,17 @@ func (h *Handlers) StatusNotification(req *cpreq.StatusNotification) (cpresp.Cha
 			Status:  req.Status,
 		},
 	}
-	h.event.SendEvent(h.ctx, h.redis, &event, h.Logger)
+	if req.Timestamp != nil {
+		event.OccurredAt = *req.Timestamp
+	}
+	h.event.SendEvent(h.ctx, &event, h.Logger)
 	return &cpresp.StatusNotification{}, nil
 }
 
 func (h *Handlers) Authorize(req *cpreq.Authorize) (cpresp.ChargePointResponse, error) {
+	if err := checkIdTag(req.IdTag); err != nil {
+		return nil, err
+	}
 	return &cpresp.Authorize{IdTagInfo: &cpresp.IdTagInfo{
 		Status: "Accepted",
 	}}, nil
@@ -128,13 +193,14 @@ func (h *Handlers) BootNotification(req *cpreq.BootNotification) (cpresp.ChargeP
 	return &cpresp.BootNotification{
 		Status:      "Accepted",
 		CurrentTime: time.Now(),
-		Interval:    60,
+		Interval:    float64(h.heartbeatInterval()),
 	}, nil
 }
 
 func (h *Handlers) DataTransfer(req *cpreq.DataTransfer) (cpresp.ChargePointResponse, error) {
 	event := domain.Event{
 		Domain: h.metadata.Host,
+		CpID:   h.metadata.ChargePointID,
 		Event:  domain.DataTransferEvent,
 		Data: &domain.DataTransfer{
 			Charger:   h.metadata.ChargePointID,
@@ -143,8 +209,35 @@ func (h *Handlers) DataTransfer(req *cpreq.DataTransfer) (cpresp.ChargePointResp
 			Data:      req.Data,
 		},
 	}
-	h.event.SendEvent(h.ctx, h.redis, &event, h.Logger)
+	h.event.SendEvent(h.ctx, &event, h.Logger)
 	return &cpresp.DataTransfer{
 		Status: "Accepted",
 	}, nil
 }
+
+// meterValues copies the meter values of a 1.6 request into the event.
+func meterValues(items []*cpreq.MeterValueItems) []domain.MeterValue {
+	values := make([]domain.MeterValue, 0, len(items))
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		value := domain.MeterValue{Timestamp: item.Timestamp}
+		for _, sv := range item.SampledValues {
+			if sv == nil {
+				continue
+			}
+			value.SampledValue = append(value.SampledValue, domain.SampledValue{
+				Context:   sv.Context,
+				Format:    sv.Format,
+				Location:  sv.Location,
+				Measurand: sv.Measurand,
+				Phase:     sv.Phase,
+				Unit:      sv.Unit,
+				Value:     sv.Value,
+			})
+		}
+		values = append(values, value)
+	}
+	return values
+}
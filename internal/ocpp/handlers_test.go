@@ -2,11 +2,17 @@ package ocpp
 
 import (
 	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/JscorpTech/ocpp/internal/client"
 	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/JscorpTech/ocpp/internal/domain"
 	"github.com/JscorpTech/ocpp/internal/services"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/voltbras/go-ocpp/cs"
 	"github.com/voltbras/go-ocpp/messages/v1x/cpreq"
@@ -14,26 +20,33 @@ import (
 	"go.uber.org/zap"
 )
 
-func setupTestHandler() *Handlers {
+// setupTestHandler gives a handler backed by an in-memory Redis, the fake
+// backend and a sink that keeps the events it sends.
+func setupTestHandler(t *testing.T) (*Handlers, *services.MemorySink) {
+	t.Helper()
 	logger, _ := zap.NewDevelopment()
 	rdb := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
+		Addr: miniredis.RunT(t).Addr(),
 	})
+	t.Cleanup(func() { rdb.Close() })
+	backend := httptest.NewServer(&client.FakeBackend{})
+	t.Cleanup(backend.Close)
 	ctx := context.Background()
 	metadata := cs.ChargePointRequestMetadata{
 		ChargePointID: "test-charger-001",
 	}
 	cfg := &config.Config{
-		BaseUrl: "http://localhost:8000",
+		BaseUrl: backend.URL,
 		Addr:    ":8080",
 	}
 
-	event := services.NewEventService()
-	return NewHandler(ctx, logger, rdb, metadata, cfg, event)
+	sink := services.NewMemorySink()
+	event := services.NewEventService(services.Route{Sink: sink})
+	return NewHandler(ctx, logger, rdb, client.NewTransactionClient(cfg), metadata, cfg, event), sink
 }
 
 func TestNewHandler(t *testing.T) {
-	handler := setupTestHandler()
+	handler, _ := setupTestHandler(t)
 	if handler == nil {
 		t.Fatal("NewHandler() returned nil")
 	}
@@ -48,7 +61,7 @@ func TestNewHandler(t *testing.T) {
 }
 
 func TestHandlers_BootNotification(t *testing.T) {
-	handler := setupTestHandler()
+	handler, _ := setupTestHandler(t)
 
 	req := &cpreq.BootNotification{
 		ChargePointVendor:       "TestVendor",
@@ -80,7 +93,7 @@ func TestHandlers_BootNotification(t *testing.T) {
 }
 
 func TestHandlers_Authorize(t *testing.T) {
-	handler := setupTestHandler()
+	handler, _ := setupTestHandler(t)
 
 	req := &cpreq.Authorize{
 		IdTag: "RFID-12345",
@@ -106,7 +119,7 @@ func TestHandlers_Authorize(t *testing.T) {
 }
 
 func TestHandlers_Heartbeat(t *testing.T) {
-	handler := setupTestHandler()
+	handler, _ := setupTestHandler(t)
 
 	req := &cpreq.Heartbeat{}
 
@@ -130,7 +143,7 @@ func TestHandlers_Heartbeat(t *testing.T) {
 }
 
 func TestHandlers_StatusNotification(t *testing.T) {
-	handler := setupTestHandler()
+	handler, _ := setupTestHandler(t)
 
 	req := &cpreq.StatusNotification{
 		ConnectorId: 1,
@@ -150,7 +163,7 @@ func TestHandlers_StatusNotification(t *testing.T) {
 }
 
 func TestHandlers_MeterValues(t *testing.T) {
-	handler := setupTestHandler()
+	handler, _ := setupTestHandler(t)
 
 	req := &cpreq.MeterValues{
 		ConnectorId:   1,
@@ -169,7 +182,7 @@ func TestHandlers_MeterValues(t *testing.T) {
 }
 
 func TestHandlers_StopTransaction(t *testing.T) {
-	handler := setupTestHandler()
+	handler, _ := setupTestHandler(t)
 
 	req := &cpreq.StopTransaction{
 		TransactionId: 123,
@@ -196,3 +209,113 @@ func TestHandlers_StopTransaction(t *testing.T) {
 		t.Errorf("Status = %v, want Accepted", stopResp.IdTagInfo.Status)
 	}
 }
+
+func TestHandlers_StartTransaction(t *testing.T) {
+	handler, sink := setupTestHandler(t)
+
+	req := &cpreq.StartTransaction{
+		ConnectorId: 1,
+		IdTag:       "RFID-12345",
+		MeterStart:  100,
+		Timestamp:   time.Now(),
+	}
+
+	resp, err := handler.StartTransaction(req)
+	if err != nil {
+		t.Fatalf("StartTransaction() error = %v", err)
+	}
+
+	startResp, ok := resp.(*cpresp.StartTransaction)
+	if !ok {
+		t.Fatal("Response is not *cpresp.StartTransaction")
+	}
+
+	if startResp.TransactionId != 1 {
+		t.Errorf("TransactionId = %v, want 1 from the backend", startResp.TransactionId)
+	}
+
+	events := sink.Events(domain.StartTransactionEvent)
+	if len(events) != 1 {
+		t.Fatalf("start events = %d, want 1", len(events))
+	}
+	if data := events[0].Data.(domain.StartTransaction); data.Tag != "RFID-12345" || data.MeterStart != 100 {
+		t.Errorf("event data = %+v", data)
+	}
+}
+
+func TestHandlers_CallErrors(t *testing.T) {
+	handler, sink := setupTestHandler(t)
+
+	tests := []struct {
+		name  string
+		call  func() (cpresp.ChargePointResponse, error)
+		code  ErrorCode
+		field string
+	}{
+		{
+			name: "start on connector 0",
+			call: func() (cpresp.ChargePointResponse, error) {
+				return handler.StartTransaction(&cpreq.StartTransaction{ConnectorId: 0, IdTag: "RFID-12345"})
+			},
+			code:  PropertyConstraintViolation,
+			field: "connectorId",
+		},
+		{
+			name: "idTag too long",
+			call: func() (cpresp.ChargePointResponse, error) {
+				return handler.Authorize(&cpreq.Authorize{IdTag: "RFID-0123456789-0123456789"})
+			},
+			code:  PropertyConstraintViolation,
+			field: "idTag",
+		},
+		{
+			name: "idTag missing",
+			call: func() (cpresp.ChargePointResponse, error) {
+				return handler.StartTransaction(&cpreq.StartTransaction{ConnectorId: 1})
+			},
+			code:  OccurrenceConstraintViolation,
+			field: "idTag",
+		},
+		{
+			name: "negative connector",
+			call: func() (cpresp.ChargePointResponse, error) {
+				return handler.StatusNotification(&cpreq.StatusNotification{ConnectorId: -1, Status: "Available", ErrorCode: "NoError"})
+			},
+			code:  PropertyConstraintViolation,
+			field: "connectorId",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.call()
+			var callErr *CallErr
+			if !errors.As(err, &callErr) {
+				t.Fatalf("error = %v, want *CallErr", err)
+			}
+			if callErr.Code != tt.code || callErr.Details["field"] != tt.field {
+				t.Errorf("error = %+v, want %s on %s", callErr, tt.code, tt.field)
+			}
+		})
+	}
+	if events := sink.Events(); len(events) != 0 {
+		t.Errorf("events = %+v, want none for rejected calls", events)
+	}
+}
+
+func TestHandlers_StartTransaction_BackendDown(t *testing.T) {
+	handler, sink := setupTestHandler(t)
+	// Nothing listens on port 1.
+	handler.transactionClient = client.NewTransactionClient(&config.Config{BaseUrl: "http://127.0.0.1:1"})
+
+	_, err := handler.StartTransaction(&cpreq.StartTransaction{ConnectorId: 1, IdTag: "RFID-12345", Timestamp: time.Now()})
+	var callErr *CallErr
+	if !errors.As(err, &callErr) || callErr.Code != InternalError {
+		t.Fatalf("error = %v, want an InternalError", err)
+	}
+	if strings.Contains(callErr.Description, "127.0.0.1") {
+		t.Errorf("description %q names the backend", callErr.Description)
+	}
+	if events := sink.Events(domain.StartTransactionEvent); len(events) != 0 {
+		t.Errorf("start events = %d, want none", len(events))
+	}
+}
@@ -0,0 +1,303 @@
+package ocpp
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/ocpp/v201"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// v201TransactionsKey maps "<cp_id>/<2.0.1 transactionId>" to the backend
+// transaction id, since 2.0.1 chargers pick their own string ids.
+// v201ChargerTransactionsKey maps "<cp_id>/<backend id>" back for
+// RequestStopTransaction.
+const (
+	v201TransactionsKey        = "ocpp:v201:transactions"
+	v201ChargerTransactionsKey = "ocpp:v201:charger_transactions"
+)
+
+func backendTransactionField(cpID string, transactionId int) string {
+	return cpID + "/" + strconv.Itoa(transactionId)
+}
+
+// HandlersV201 answers OCPP 2.0.1 messages and emits the same domain events
+// as the 1.6 handlers.
+type HandlersV201 struct {
+	*Handlers
+}
+
+func (h *Handlers) V201() *HandlersV201 {
+	return &HandlersV201{h}
+}
+
+func (h *HandlersV201) BootNotification(req *v201.BootNotificationRequest) (*v201.BootNotificationResponse, error) {
+	return &v201.BootNotificationResponse{
+		Status:      "Accepted",
+		CurrentTime: time.Now(),
+		Interval:    h.heartbeatInterval(),
+	}, nil
+}
+
+func (h *HandlersV201) Heartbeat(req *v201.HeartbeatRequest) (*v201.HeartbeatResponse, error) {
+	event := domain.Event{
+		Domain: h.metadata.Host,
+		CpID:   h.metadata.ChargePointID,
+		Event:  domain.HealthEvent,
+		Data: domain.Healthcheck{
+			Charger: h.metadata.ChargePointID,
+		},
+	}
+	h.event.SendEvent(h.ctx, &event, h.Logger)
+	return &v201.HeartbeatResponse{CurrentTime: time.Now()}, nil
+}
+
+func (h *HandlersV201) Authorize(req *v201.AuthorizeRequest) (*v201.AuthorizeResponse, error) {
+	return &v201.AuthorizeResponse{IdTokenInfo: v201.IdTokenInfo{Status: "Accepted"}}, nil
+}
+
+func (h *HandlersV201) StatusNotification(req *v201.StatusNotificationRequest) (*v201.StatusNotificationResponse, error) {
+	h.sendConnectorStatus(req.EvseId, req.ConnectorId, connectorStatusV16(req.ConnectorStatus), req.Timestamp)
+	return &v201.StatusNotificationResponse{}, nil
+}
+
+func (h *HandlersV201) MeterValues(req *v201.MeterValuesRequest) (*v201.MeterValuesResponse, error) {
+	event := domain.Event{
+		Domain: h.metadata.Host,
+		CpID:   h.metadata.ChargePointID,
+		Event:  domain.MeterValuesEvent,
+		Data: domain.MeterValues{
+			Conn:       req.EvseId,
+			MeterValue: meterValuesV16(req.MeterValue),
+		},
+	}
+	h.event.SendEvent(h.ctx, &event, h.Logger)
+	return &v201.MeterValuesResponse{}, nil
+}
+
+// TransactionEvent covers what 1.6 splits over StartTransaction, MeterValues
+// and StopTransaction. The start event is sent, and the backend transaction
+// looked up, on the first event that carries an idToken.
+func (h *HandlersV201) TransactionEvent(req *v201.TransactionEventRequest) (*v201.TransactionEventResponse, error) {
+	resp := &v201.TransactionEventResponse{}
+	field := h.metadata.ChargePointID + "/" + req.TransactionInfo.TransactionId
+	conn, connector := 0, 0
+	if req.EVSE != nil {
+		conn, connector = req.EVSE.Id, req.EVSE.ConnectorId
+	}
+
+	transactionId, err := h.redis.HGet(h.ctx, v201TransactionsKey, field).Int()
+	known := err == nil
+	if err != nil && err != redis.Nil {
+		h.Logger.Error("redis error", zap.Error(err))
+	}
+	if !known && req.IdToken != nil {
+		transaction, err := h.transactionClient.GetTransactionFromTag(h.ctx, req.IdToken.IdToken)
+		if err != nil {
+			return nil, h.backendError(err)
+		}
+		transactionId, known = transaction.Data.Id, true
+		_, err = h.redis.Pipelined(h.ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(h.ctx, v201TransactionsKey, field, transactionId)
+			pipe.HSet(h.ctx, v201ChargerTransactionsKey, backendTransactionField(h.metadata.ChargePointID, transactionId), req.TransactionInfo.TransactionId)
+			return nil
+		})
+		if err != nil {
+			h.Logger.Error("redis error", zap.Error(err))
+		}
+		meterStart, _ := energyWh(req.MeterValue)
+		event := domain.Event{
+			Domain:     h.metadata.Host,
+			CpID:       h.metadata.ChargePointID,
+			OccurredAt: req.Timestamp,
+			Event:      domain.StartTransactionEvent,
+			Data: domain.StartTransaction{
+				Charger:    h.metadata.ChargePointID,
+				Conn:       conn,
+				Tag:        req.IdToken.IdToken,
+				MeterStart: meterStart,
+			},
+		}
+		h.event.SendEvent(h.ctx, &event, h.Logger)
+		resp.IdTokenInfo = &v201.IdTokenInfo{Status: "Accepted"}
+	}
+
+	if status := chargingStatusV16(req.TransactionInfo.ChargingState, req.EventType); status != "" && conn != 0 {
+		h.sendConnectorStatus(conn, connector, status, req.Timestamp)
+	}
+
+	if req.EventType != v201.TransactionEnded {
+		if len(req.MeterValue) > 0 {
+			event := domain.Event{
+				Domain:     h.metadata.Host,
+				CpID:       h.metadata.ChargePointID,
+				OccurredAt: req.Timestamp,
+				Event:      domain.MeterValuesEvent,
+				Data: domain.MeterValues{
+					Conn:          conn,
+					TransactionId: int32(transactionId),
+					MeterValue:    meterValuesV16(req.MeterValue),
+				},
+			}
+			h.event.SendEvent(h.ctx, &event, h.Logger)
+		}
+		return resp, nil
+	}
+
+	if !known {
+		h.Logger.Warn("Transaction ended without a backend transaction", zap.String("transaction_id", req.TransactionInfo.TransactionId))
+	}
+	meterStop, _ := energyWh(req.MeterValue)
+	event := domain.Event{
+		Domain:     h.metadata.Host,
+		CpID:       h.metadata.ChargePointID,
+		OccurredAt: req.Timestamp,
+		Event:      domain.StopTransactionEvent,
+		Data: domain.StopTransaction{
+			Charger:       h.metadata.ChargePointID,
+			TransactionId: transactionId,
+			Reason:        req.TransactionInfo.StoppedReason,
+			MeterStop:     meterStop,
+		},
+	}
+	h.event.SendEvent(h.ctx, &event, h.Logger)
+	_, err = h.redis.Pipelined(h.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(h.ctx, v201TransactionsKey, field)
+		if known {
+			pipe.HDel(h.ctx, v201ChargerTransactionsKey, backendTransactionField(h.metadata.ChargePointID, transactionId))
+		}
+		return nil
+	})
+	if err != nil {
+		h.Logger.Error("redis error", zap.Error(err))
+	}
+	return resp, nil
+}
+
+// NotifyReport sends the device model report as a configuration_report
+// event.
+func (h *HandlersV201) NotifyReport(req *v201.NotifyReportRequest) (*v201.NotifyReportResponse, error) {
+	event := domain.Event{
+		Domain: h.metadata.Host,
+		CpID:   h.metadata.ChargePointID,
+		Event:  domain.ConfigurationReportEvent,
+		Data: domain.ConfigurationReport{
+			Charger:   h.metadata.ChargePointID,
+			RequestId: req.RequestId,
+			Keys:      reportKeys(req),
+		},
+	}
+	h.event.SendEvent(h.ctx, &event, h.Logger)
+	return &v201.NotifyReportResponse{}, nil
+}
+
+// reportKeys flattens a device model report into configuration keys named
+// "<component>.<variable>", one per Actual attribute.
+func reportKeys(req *v201.NotifyReportRequest) []domain.ConfigurationKey {
+	keys := make([]domain.ConfigurationKey, 0, len(req.ReportData))
+	for _, data := range req.ReportData {
+		for _, attr := range data.VariableAttribute {
+			if attr.Type != "" && attr.Type != "Actual" {
+				continue
+			}
+			keys = append(keys, domain.ConfigurationKey{
+				Key:      v201.VariableKey(data.Component, data.Variable),
+				Value:    attr.Value,
+				Readonly: attr.Mutability == "ReadOnly",
+			})
+		}
+	}
+	return keys
+}
+
+func (h *HandlersV201) sendConnectorStatus(conn, connector int, status string, occurredAt time.Time) {
+	event := domain.Event{
+		Domain:     h.metadata.Host,
+		CpID:       h.metadata.ChargePointID,
+		OccurredAt: occurredAt,
+		Event:      domain.ChangeConnectorStatusEvent,
+		Data: domain.ChangeConnectorStatus{
+			Charger:   h.metadata.ChargePointID,
+			Conn:      conn,
+			Connector: connector,
+			Status:    status,
+		},
+	}
+	h.event.SendEvent(h.ctx, &event, h.Logger)
+}
+
+// connectorStatusV16 maps a 2.0.1 connector status onto the 1.6 vocabulary.
+// Occupied has no direct 1.6 counterpart; the finer charging states arrive
+// with TransactionEvent.
+func connectorStatusV16(status string) string {
+	if status == "Occupied" {
+		return "Preparing"
+	}
+	return status
+}
+
+func chargingStatusV16(state, eventType string) string {
+	switch state {
+	case "Charging", "SuspendedEV", "SuspendedEVSE":
+		return state
+	case "EVConnected":
+		if eventType == v201.TransactionEnded {
+			return "Finishing"
+		}
+		return "Preparing"
+	}
+	return ""
+}
+
+// meterValuesV16 converts 2.0.1 meter values into the 1.6 shape, so
+// meter_value events look the same whatever the charger speaks.
+func meterValuesV16(values []v201.MeterValue) []domain.MeterValue {
+	items := make([]domain.MeterValue, 0, len(values))
+	for _, mv := range values {
+		item := domain.MeterValue{Timestamp: mv.Timestamp}
+		for _, sv := range mv.SampledValue {
+			value, unit := scaledValue(sv)
+			item.SampledValue = append(item.SampledValue, domain.SampledValue{
+				Context:   sv.Context,
+				Measurand: sv.Measurand,
+				Phase:     sv.Phase,
+				Location:  sv.Location,
+				Unit:      unit,
+				Value:     strconv.FormatFloat(value, 'f', -1, 64),
+			})
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func scaledValue(sv v201.SampledValue) (float64, string) {
+	if sv.UnitOfMeasure == nil {
+		return sv.Value, ""
+	}
+	return sv.Value * math.Pow10(sv.UnitOfMeasure.Multiplier), sv.UnitOfMeasure.Unit
+}
+
+// energyWh returns the last Energy.Active.Import.Register reading in Wh.
+func energyWh(values []v201.MeterValue) (int, bool) {
+	reading, found := 0.0, false
+	for _, mv := range values {
+		for _, sv := range mv.SampledValue {
+			if sv.Measurand != "" && sv.Measurand != "Energy.Active.Import.Register" {
+				continue
+			}
+			if sv.Phase != "" {
+				continue
+			}
+			value, unit := scaledValue(sv)
+			if unit == "kWh" {
+				value *= 1000
+			}
+			reading, found = value, true
+		}
+	}
+	return int(math.Round(reading)), found
+}
@@ -0,0 +1,164 @@
+package ocpp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/ocpp/v201"
+)
+
+func TestHandlersV201_BootNotification(t *testing.T) {
+	h, _ := setupTestHandler(t)
+	handler := h.V201()
+
+	resp, err := handler.BootNotification(&v201.BootNotificationRequest{
+		ChargingStation: v201.ChargingStation{Model: "TestModel", VendorName: "TestVendor"},
+		Reason:          "PowerUp",
+	})
+	if err != nil {
+		t.Fatalf("BootNotification() error = %v", err)
+	}
+	if resp.Status != "Accepted" {
+		t.Errorf("Status = %v, want Accepted", resp.Status)
+	}
+	if resp.Interval != 60 {
+		t.Errorf("Interval = %v, want 60", resp.Interval)
+	}
+}
+
+func TestHandlersV201_StatusNotification(t *testing.T) {
+	h, sink := setupTestHandler(t)
+	handler := h.V201()
+
+	_, err := handler.StatusNotification(&v201.StatusNotificationRequest{
+		Timestamp:       time.Now(),
+		ConnectorStatus: "Occupied",
+		EvseId:          1,
+		ConnectorId:     2,
+	})
+	if err != nil {
+		t.Fatalf("StatusNotification() error = %v", err)
+	}
+	events := sink.Events(domain.ChangeConnectorStatusEvent)
+	if len(events) != 1 || events[0].Data != (domain.ChangeConnectorStatus{Charger: "test-charger-001", Conn: 1, Connector: 2, Status: "Preparing"}) {
+		t.Errorf("events = %+v, want EVSE 1 connector 2 Preparing", events)
+	}
+}
+
+func TestHandlersV201_TransactionEventWithoutToken(t *testing.T) {
+	h, _ := setupTestHandler(t)
+	handler := h.V201()
+
+	resp, err := handler.TransactionEvent(&v201.TransactionEventRequest{
+		EventType:       v201.TransactionUpdated,
+		Timestamp:       time.Now(),
+		TriggerReason:   "MeterValuePeriodic",
+		TransactionInfo: v201.Transaction{TransactionId: "tx-1", ChargingState: "Charging"},
+		EVSE:            &v201.EVSE{Id: 1},
+	})
+	if err != nil {
+		t.Fatalf("TransactionEvent() error = %v", err)
+	}
+	if resp.IdTokenInfo != nil {
+		t.Errorf("IdTokenInfo = %+v, want nil without idToken", resp.IdTokenInfo)
+	}
+}
+
+func TestHandlersV201_TransactionEvent(t *testing.T) {
+	h, sink := setupTestHandler(t)
+	handler := h.V201()
+
+	resp, err := handler.TransactionEvent(&v201.TransactionEventRequest{
+		EventType:       v201.TransactionStarted,
+		Timestamp:       time.Now(),
+		TriggerReason:   "Authorized",
+		TransactionInfo: v201.Transaction{TransactionId: "tx-1", ChargingState: "Charging"},
+		IdToken:         &v201.IdToken{IdToken: "RFID-12345", Type: "ISO14443"},
+		EVSE:            &v201.EVSE{Id: 1},
+	})
+	if err != nil {
+		t.Fatalf("TransactionEvent(Started) error = %v", err)
+	}
+	if resp.IdTokenInfo == nil || resp.IdTokenInfo.Status != "Accepted" {
+		t.Errorf("IdTokenInfo = %+v, want Accepted", resp.IdTokenInfo)
+	}
+	if id, _ := h.redis.HGet(h.ctx, v201ChargerTransactionsKey, "test-charger-001/1").Result(); id != "tx-1" {
+		t.Errorf("charger transaction of backend id 1 = %q, want tx-1", id)
+	}
+
+	_, err = handler.TransactionEvent(&v201.TransactionEventRequest{
+		EventType:       v201.TransactionEnded,
+		Timestamp:       time.Now(),
+		TriggerReason:   "StopAuthorized",
+		TransactionInfo: v201.Transaction{TransactionId: "tx-1", StoppedReason: "Local"},
+		EVSE:            &v201.EVSE{Id: 1},
+	})
+	if err != nil {
+		t.Fatalf("TransactionEvent(Ended) error = %v", err)
+	}
+
+	if events := sink.Events(domain.StartTransactionEvent); len(events) != 1 {
+		t.Errorf("start events = %d, want 1", len(events))
+	}
+	stops := sink.Events(domain.StopTransactionEvent)
+	if len(stops) != 1 {
+		t.Fatalf("stop events = %d, want 1", len(stops))
+	}
+	if data := stops[0].Data.(domain.StopTransaction); data.TransactionId != 1 {
+		t.Errorf("stop TransactionId = %d, want the backend id 1", data.TransactionId)
+	}
+	if n, _ := h.redis.HLen(h.ctx, v201ChargerTransactionsKey).Result(); n != 0 {
+		t.Errorf("%d charger transactions left after the end", n)
+	}
+}
+
+func TestHandlersV201_NotifyReport(t *testing.T) {
+	h, _ := setupTestHandler(t)
+	handler := h.V201()
+
+	_, err := handler.NotifyReport(&v201.NotifyReportRequest{
+		RequestId: 1,
+		ReportData: []v201.ReportData{{
+			Component:         v201.Component{Name: "OCPPCommCtrlr"},
+			Variable:          v201.Variable{Name: "HeartbeatInterval"},
+			VariableAttribute: []v201.VariableAttribute{{Value: "60", Mutability: "ReadWrite"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NotifyReport() error = %v", err)
+	}
+}
+
+func TestEnergyWh(t *testing.T) {
+	values := []v201.MeterValue{{
+		SampledValue: []v201.SampledValue{
+			{Value: 230, Measurand: "Voltage"},
+			{Value: 1.5, Measurand: "Energy.Active.Import.Register", UnitOfMeasure: &v201.UnitOfMeasure{Unit: "kWh"}},
+		},
+	}}
+	got, ok := energyWh(values)
+	if !ok || got != 1500 {
+		t.Errorf("energyWh() = %v, %v, want 1500, true", got, ok)
+	}
+
+	if _, ok := energyWh(nil); ok {
+		t.Error("energyWh(nil) should report no reading")
+	}
+}
+
+func TestMeterValuesV16(t *testing.T) {
+	items := meterValuesV16([]v201.MeterValue{{
+		Timestamp: time.Now(),
+		SampledValue: []v201.SampledValue{
+			{Value: 12, Measurand: "Power.Active.Import", UnitOfMeasure: &v201.UnitOfMeasure{Unit: "W", Multiplier: 3}},
+		},
+	}})
+	if len(items) != 1 || len(items[0].SampledValue) != 1 {
+		t.Fatalf("meterValuesV16() = %+v", items)
+	}
+	sv := items[0].SampledValue[0]
+	if sv.Value != "12000" || sv.Unit != "W" {
+		t.Errorf("SampledValue = %+v, want 12000 W", sv)
+	}
+}
@@ -0,0 +1,95 @@
+package ocpp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"go.uber.org/zap"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// handleHealthz is the liveness probe: the process is up and serving HTTP.
+// It never looks at Redis or the backend, so an outage there does not get
+// every replica restarted at once.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	writeJson(w, domain.HealthResponse{Status: domain.StatusOK}, http.StatusOK)
+}
+
+// handleReadyz is the readiness probe: the instance can accept chargers and
+// actually deliver what they send.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	res := s.readiness(r.Context())
+	status := http.StatusOK
+	if res.Status != domain.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJson(w, res, status)
+}
+
+func (s *Server) readiness(ctx context.Context) domain.HealthResponse {
+	if s.csys.Draining() {
+		return domain.HealthResponse{Status: domain.StatusUnavailable, Checks: map[string]domain.HealthCheck{
+			"draining": {Status: domain.StatusUnavailable, Error: "Server shutting down"},
+		}}
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) (*int64, error){
+		"redis": func(ctx context.Context) (*int64, error) {
+			return nil, s.redis.Ping(ctx).Err()
+		},
+		"backend": func(ctx context.Context) (*int64, error) {
+			return nil, s.backend.Ping(ctx)
+		},
+		"event_backlog": func(ctx context.Context) (*int64, error) {
+			// Counting one past the limit is enough to tell it is exceeded.
+			max := s.settings().ReadyMaxEventBacklog
+			var limit int64
+			if max > 0 {
+				limit = max + 1
+			}
+			backlog, err := s.event.Backlog(ctx, limit)
+			if err != nil {
+				return nil, err
+			}
+			if max > 0 && backlog > max {
+				return &backlog, fmt.Errorf("Backlog above %d", max)
+			}
+			return &backlog, nil
+		},
+	}
+
+	res := domain.HealthResponse{Status: domain.StatusOK, Checks: make(map[string]domain.HealthCheck, len(checks))}
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			value, err := check(ctx)
+			result := domain.HealthCheck{Status: domain.StatusOK, LatencyMs: time.Since(start).Milliseconds(), Value: value}
+			if err != nil {
+				result.Status = domain.StatusUnavailable
+				result.Error = err.Error()
+				s.log.Warn("Readiness check failed", zap.String("check", name), zap.Error(err))
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			res.Checks[name] = result
+			if err != nil {
+				res.Status = domain.StatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+	return res
+}
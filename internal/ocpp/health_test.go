@@ -0,0 +1,54 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JscorpTech/ocpp/internal/client"
+	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestServer_Health(t *testing.T) {
+	backend := httptest.NewServer(&client.FakeBackend{})
+	defer backend.Close()
+	// Nothing listens on port 1, so the Redis checks fail.
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+	cfg := &config.Config{BaseUrl: backend.URL, ReadyMaxEventBacklog: 10, EventSinks: []string{"redis"}}
+	s := NewServer(context.Background(), cfg, zap.NewNop(), rdb, client.NewTransactionClient(cfg))
+
+	w := httptest.NewRecorder()
+	s.handleHealthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("healthz status = %d, want 200", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz status = %d, want 503", w.Code)
+	}
+	var res domain.HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if res.Checks["redis"].Status != domain.StatusUnavailable || res.Checks["event_backlog"].Status != domain.StatusUnavailable {
+		t.Errorf("checks = %+v, want redis and event_backlog unavailable", res.Checks)
+	}
+	if res.Checks["backend"].Status != domain.StatusOK {
+		t.Errorf("backend check = %+v, want ok", res.Checks["backend"])
+	}
+
+	s.csys.Shutdown(context.Background())
+	w = httptest.NewRecorder()
+	s.handleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz while draining = %d, want 503", w.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package ocpp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultJournalLimit = 100
+	maxJournalLimit     = 1000
+)
+
+// recordFrame writes a frame to the journal of its charger.
+func (s *Server) recordFrame(conn *Conn, incoming bool, frame *Frame, raw []byte) {
+	entry := domain.JournalEntry{
+		Time:      time.Now(),
+		CpID:      conn.ID,
+		Version:   string(conn.Version),
+		Direction: domain.DirectionOut,
+		Type:      "INVALID",
+		Raw:       string(raw),
+	}
+	if incoming {
+		entry.Direction = domain.DirectionIn
+	}
+	if frame != nil {
+		entry.Type = frame.Type.String()
+		entry.MessageID = frame.ID
+		entry.Action = frame.Action
+	}
+	if err := s.journal.Write(&entry); err != nil {
+		s.log.Error("journal error", zap.String("cp_id", conn.ID), zap.Error(err))
+	}
+}
+
+// handleJournal returns the last messages of a charger. Every replica keeps
+// the journal of the chargers connected to it; /chargers tells which one.
+func (s *Server) handleJournal(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid Method " + r.Method}, http.StatusMethodNotAllowed)
+		return
+	}
+	if s.journal == nil {
+		writeJson(w, domain.ErrorResponse{Detail: "Journal disabled"}, http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	cpID := query.Get("cp_id")
+	if cpID == "" {
+		writeJson(w, domain.ErrorResponse{Detail: "CpId required"}, http.StatusBadRequest)
+		return
+	}
+	limit := defaultJournalLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxJournalLimit {
+			writeJson(w, domain.ErrorResponse{Detail: "Limit must be between 1 and " + strconv.Itoa(maxJournalLimit)}, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	entries, err := s.journal.Last(cpID, limit)
+	if err != nil {
+		s.log.Error("journal error", zap.String("cp_id", cpID), zap.Error(err))
+		writeJson(w, domain.ErrorResponse{Detail: "Journal unavailable"}, http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []domain.JournalEntry{}
+	}
+	writeJson(w, domain.JournalList{Count: len(entries), Messages: entries}, http.StatusOK)
+}
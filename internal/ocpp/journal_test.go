@@ -0,0 +1,75 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/journal"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func TestServer_Journal(t *testing.T) {
+	j, err := journal.New(t.TempDir(), 1<<20, 2, zap.NewNop())
+	if err != nil {
+		t.Fatalf("journal.New() error = %v", err)
+	}
+	s := &Server{log: zap.NewNop(), journal: j}
+	csys := NewCentralSystem(zap.NewNop(), func(context.Context, *Conn, string, json.RawMessage) (any, error) {
+		return map[string]string{"currentTime": "2024-01-01T00:00:00Z"}, nil
+	})
+	csys.SetFrameListener(s.recordFrame)
+	server := httptest.NewServer(csys)
+	defer server.Close()
+
+	socket := dialCharger(t, server.URL, "CP-J", "ocpp1.6")
+	socket.WriteMessage(websocket.TextMessage, []byte(`not json`))
+	socket.WriteMessage(websocket.TextMessage, []byte(`[2,"m1","Heartbeat",{}]`))
+	socket.ReadMessage()
+	// The answer is journaled right after it is written.
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		j.Flush()
+		if entries, _ := j.Last("127.0.0.1:CP-J", 10); len(entries) == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	s.handleJournal(w, httptest.NewRequest(http.MethodGet, "/journal?cp_id=127.0.0.1:CP-J&limit=10", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var list domain.JournalList
+	json.NewDecoder(w.Body).Decode(&list)
+	if list.Count != 3 {
+		t.Fatalf("messages = %+v, want 3", list.Messages)
+	}
+	invalid, call, result := list.Messages[0], list.Messages[1], list.Messages[2]
+	if invalid.Type != "INVALID" || invalid.Raw != "not json" {
+		t.Errorf("invalid frame = %+v", invalid)
+	}
+	if call.Direction != domain.DirectionIn || call.Type != "CALL" || call.Action != "Heartbeat" || call.MessageID != "m1" || call.Version != "ocpp1.6" {
+		t.Errorf("call = %+v", call)
+	}
+	if result.Direction != domain.DirectionOut || result.Type != "CALLRESULT" || result.MessageID != "m1" {
+		t.Errorf("result = %+v", result)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleJournal(w, httptest.NewRequest(http.MethodGet, "/journal?cp_id=127.0.0.1:CP-J&limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("limit=0 status = %d, want 400", w.Code)
+	}
+	w = httptest.NewRecorder()
+	(&Server{}).handleJournal(w, httptest.NewRequest(http.MethodGet, "/journal?cp_id=x", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("disabled journal status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package ocpp
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"github.com/JscorpTech/ocpp/internal/services"
+)
+
+// Profiles brings chargers to the configuration their profiles ask for and
+// records what could not be applied.
+type Profiles struct {
+	commands *Commands
+	drift    services.DriftService
+}
+
+func NewProfiles(commands *Commands, drift services.DriftService) *Profiles {
+	return &Profiles{commands: commands, drift: drift}
+}
+
+// Enforce reads the keys the profiles matching the charger set, changes the
+// ones that differ and stores the outcome. A charger no profile matches
+// loses its drift entry and gets nil.
+func (p *Profiles) Enforce(ctx context.Context, conn *Conn, profiles []config.Profile, vendor, model string) (*domain.ConfigurationDrift, error) {
+	desired, names := config.DesiredSettings(profiles, conn.ID, vendor, model)
+	if len(desired) == 0 {
+		return nil, p.drift.Delete(ctx, conn.ID)
+	}
+	keys := slices.Sorted(maps.Keys(desired))
+	drift := &domain.ConfigurationDrift{
+		CpID:     conn.ID,
+		Vendor:   vendor,
+		Model:    model,
+		Profiles: names,
+		Keys:     make([]domain.KeyDrift, 0, len(keys)),
+	}
+	current, err := p.commands.getConfiguration(ctx, conn, domain.GetConfigurationReq{Key: keys})
+	if err != nil {
+		for _, key := range keys {
+			drift.Keys = append(drift.Keys, domain.KeyDrift{Key: key, Desired: desired[key], Status: domain.KeyFailed, Error: err.Error()})
+		}
+	} else {
+		actual := make(map[string]domain.ConfigurationKey, len(current.ConfigurationKey))
+		for _, key := range current.ConfigurationKey {
+			actual[key.Key] = key
+		}
+		for _, key := range keys {
+			drift.Keys = append(drift.Keys, p.enforceKey(ctx, conn, key, desired[key], actual))
+		}
+	}
+	drift.CheckedAt = time.Now()
+	drift.InSync = true
+	for _, key := range drift.Keys {
+		metrics.ProfileKeys.WithLabelValues(string(key.Status)).Inc()
+		if key.Status != domain.KeyInSync && key.Status != domain.KeyAccepted {
+			drift.InSync = false
+		}
+	}
+	return drift, errors.Join(err, p.drift.Save(ctx, drift))
+}
+
+func (p *Profiles) enforceKey(ctx context.Context, conn *Conn, key, value string, actual map[string]domain.ConfigurationKey) domain.KeyDrift {
+	drift := domain.KeyDrift{Key: key, Desired: value}
+	current, ok := actual[key]
+	if !ok {
+		drift.Status = domain.KeyUnknown
+		return drift
+	}
+	drift.Actual = current.Value
+	switch {
+	case sameValue(current.Value, value):
+		drift.Status = domain.KeyInSync
+		return drift
+	case current.Readonly:
+		drift.Status = domain.KeyReadonly
+		return drift
+	}
+	res, err := p.commands.changeConfiguration(ctx, conn, domain.ChangeConfigurationReq{Key: key, Value: value})
+	if err != nil {
+		drift.Status = domain.KeyFailed
+		drift.Error = err.Error()
+		return drift
+	}
+	drift.Status = domain.KeyStatus(res.Status)
+	// The watchdog has to wait as long as the charger now does.
+	if drift.Status == domain.KeyAccepted && key == "HeartbeatInterval" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			conn.SetHeartbeatInterval(time.Duration(seconds) * time.Second)
+		}
+	}
+	return drift
+}
+
+// sameValue compares configuration values the way chargers report them:
+// list items may have spaces after the commas and booleans any case.
+func sameValue(a, b string) bool {
+	return strings.EqualFold(normalizeValue(a), normalizeValue(b))
+}
+
+func normalizeValue(value string) string {
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return strings.Join(items, ",")
+}
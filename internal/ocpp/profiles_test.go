@@ -0,0 +1,101 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/services"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestProfiles_Enforce(t *testing.T) {
+	commands, url := setupCommands(t)
+	charger := newFakeCharger(t, url, "CP-1", map[string]string{
+		"GetConfiguration": `{"configurationKey":[
+			{"key":"HeartbeatInterval","readonly":false,"value":"60"},
+			{"key":"MeterValuesSampledData","readonly":false,"value":"Energy.Active.Import.Register, Power.Active.Import"},
+			{"key":"NumberOfConnectors","readonly":true,"value":"2"}
+		],"unknownKey":["Foo"]}`,
+		"ChangeConfiguration": `{"status":"Accepted"}`,
+	})
+	conn := waitConn(t, commands.csys, "127.0.0.1:CP-1")
+	drift := services.NewDriftService(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+	profiles := NewProfiles(commands, drift)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := profiles.Enforce(ctx, conn, []config.Profile{
+		{Name: "default", Settings: map[string]string{
+			"HeartbeatInterval":      "300",
+			"MeterValuesSampledData": "Energy.Active.Import.Register,Power.Active.Import",
+			"NumberOfConnectors":     "4",
+			"Foo":                    "1",
+		}},
+		{Name: "other", Match: config.ProfileMatch{Model: "Other"}, Settings: map[string]string{"Bar": "1"}},
+	}, "Simulator", "SIM-1")
+	if err != nil {
+		t.Fatalf("Enforce() error = %v", err)
+	}
+	want := map[string]domain.KeyStatus{
+		"Foo":                    domain.KeyUnknown,
+		"HeartbeatInterval":      domain.KeyAccepted,
+		"MeterValuesSampledData": domain.KeyInSync,
+		"NumberOfConnectors":     domain.KeyReadonly,
+	}
+	if len(result.Keys) != len(want) || result.InSync {
+		t.Fatalf("Enforce() = %+v, want four keys out of sync", result)
+	}
+	for _, key := range result.Keys {
+		if key.Status != want[key.Key] {
+			t.Errorf("%s = %s, want %s", key.Key, key.Status, want[key.Key])
+		}
+	}
+	if conn.HeartbeatInterval() != 5*time.Minute {
+		t.Errorf("heartbeat interval = %s, want the changed 5m", conn.HeartbeatInterval())
+	}
+
+	if frame := <-charger.received; frame.Action != "GetConfiguration" {
+		t.Fatalf("first call = %s, want GetConfiguration", frame.Action)
+	}
+	frame := <-charger.received
+	var change domain.ChangeConfigurationReq
+	json.Unmarshal(frame.Payload, &change)
+	if frame.Action != "ChangeConfiguration" || change != (domain.ChangeConfigurationReq{Key: "HeartbeatInterval", Value: "300"}) {
+		t.Errorf("second call = %s %s, want only HeartbeatInterval changed", frame.Action, frame.Payload)
+	}
+
+	stored, _ := drift.List(ctx, domain.DriftFilter{CpID: conn.ID})
+	if len(stored) != 1 || stored[0].Model != "SIM-1" || len(stored[0].Profiles) != 1 {
+		t.Errorf("stored = %+v, want the check of CP-1", stored)
+	}
+
+	if result, err := profiles.Enforce(ctx, conn, nil, "Simulator", "SIM-1"); result != nil || err != nil {
+		t.Errorf("Enforce() = %v, %v without profiles", result, err)
+	}
+	if stored, _ := drift.List(ctx, domain.DriftFilter{CpID: conn.ID}); len(stored) != 0 {
+		t.Errorf("stored = %+v, want it removed without profiles", stored)
+	}
+}
+
+func TestSameValue(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"60", "60", true},
+		{"True", "true", true},
+		{"Energy.Active.Import.Register, SoC", "Energy.Active.Import.Register,SoC", true},
+		{"SoC,Energy.Active.Import.Register", "Energy.Active.Import.Register,SoC", false},
+		{"60", "300", false},
+	}
+	for _, tt := range tests {
+		if got := sameValue(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameValue(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Version is an OCPP-J protocol version, named after its WebSocket subprotocol.
+type Version string
+
+const (
+	V16  Version = "ocpp1.6"
+	V201 Version = "ocpp2.0.1"
+)
+
+type MessageType int
+
+const (
+	Call       MessageType = 2
+	CallResult MessageType = 3
+	CallError  MessageType = 4
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case Call:
+		return "CALL"
+	case CallResult:
+		return "CALLRESULT"
+	case CallError:
+		return "CALLERROR"
+	}
+	return "INVALID"
+}
+
+type ErrorCode string
+
+const (
+	NotImplemented                ErrorCode = "NotImplemented"
+	NotSupported                  ErrorCode = "NotSupported"
+	InternalError                 ErrorCode = "InternalError"
+	ProtocolError                 ErrorCode = "ProtocolError"
+	SecurityError                 ErrorCode = "SecurityError"
+	FormationViolation            ErrorCode = "FormationViolation"
+	PropertyConstraintViolation   ErrorCode = "PropertyConstraintViolation"
+	OccurrenceConstraintViolation ErrorCode = "OccurrenceConstraintViolation"
+	TypeConstraintViolation       ErrorCode = "TypeConstraintViolation"
+	GenericError                  ErrorCode = "GenericError"
+)
+
+// Frame is a single OCPP-J message: [2, id, action, payload], [3, id, payload]
+// or [4, id, code, description, details].
+type Frame struct {
+	Type             MessageType
+	ID               string
+	Action           string
+	Payload          json.RawMessage
+	ErrorCode        ErrorCode
+	ErrorDescription string
+	ErrorDetails     json.RawMessage
+}
+
+var ErrInvalidFrame = errors.New("invalid OCPP-J frame")
+
+func ParseFrame(data []byte) (*Frame, error) {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFrame, err)
+	}
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("%w: too few fields", ErrInvalidFrame)
+	}
+	frame := &Frame{}
+	if err := json.Unmarshal(fields[0], &frame.Type); err != nil {
+		return nil, fmt.Errorf("%w: message type: %v", ErrInvalidFrame, err)
+	}
+	if err := json.Unmarshal(fields[1], &frame.ID); err != nil {
+		return nil, fmt.Errorf("%w: message id: %v", ErrInvalidFrame, err)
+	}
+	switch frame.Type {
+	case Call:
+		if len(fields) != 4 {
+			return frame, fmt.Errorf("%w: CALL needs 4 fields", ErrInvalidFrame)
+		}
+		if err := json.Unmarshal(fields[2], &frame.Action); err != nil {
+			return frame, fmt.Errorf("%w: action: %v", ErrInvalidFrame, err)
+		}
+		frame.Payload = fields[3]
+	case CallResult:
+		frame.Payload = fields[2]
+	case CallError:
+		if len(fields) != 5 {
+			return frame, fmt.Errorf("%w: CALLERROR needs 5 fields", ErrInvalidFrame)
+		}
+		if err := json.Unmarshal(fields[2], &frame.ErrorCode); err != nil {
+			return frame, fmt.Errorf("%w: error code: %v", ErrInvalidFrame, err)
+		}
+		if err := json.Unmarshal(fields[3], &frame.ErrorDescription); err != nil {
+			return frame, fmt.Errorf("%w: error description: %v", ErrInvalidFrame, err)
+		}
+		frame.ErrorDetails = fields[4]
+	default:
+		return frame, fmt.Errorf("%w: unknown message type %d", ErrInvalidFrame, frame.Type)
+	}
+	return frame, nil
+}
+
+func (f *Frame) MarshalJSON() ([]byte, error) {
+	switch f.Type {
+	case Call:
+		return json.Marshal([]any{f.Type, f.ID, f.Action, payloadOrEmpty(f.Payload)})
+	case CallResult:
+		return json.Marshal([]any{f.Type, f.ID, payloadOrEmpty(f.Payload)})
+	case CallError:
+		return json.Marshal([]any{f.Type, f.ID, f.ErrorCode, f.ErrorDescription, payloadOrEmpty(f.ErrorDetails)})
+	}
+	return nil, fmt.Errorf("%w: unknown message type %d", ErrInvalidFrame, f.Type)
+}
+
+func payloadOrEmpty(payload json.RawMessage) json.RawMessage {
+	if len(payload) == 0 || string(payload) == "null" {
+		return json.RawMessage("{}")
+	}
+	return payload
+}
+
+// CallErr is an OCPP-level error. It is what a charger's CALLERROR decodes
+// to, and what gets encoded as a CALLERROR when returned from a handler.
+type CallErr struct {
+	Code        ErrorCode
+	Description string
+	Details     map[string]any
+}
+
+func (e *CallErr) Error() string {
+	if e.Description == "" {
+		return string(e.Code)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func newCallErrorFrame(id string, err error) *Frame {
+	var callErr *CallErr
+	if !errors.As(err, &callErr) {
+		callErr = &CallErr{Code: InternalError, Description: err.Error()}
+	}
+	details, _ := json.Marshal(callErr.Details)
+	return &Frame{
+		Type:             CallError,
+		ID:               id,
+		ErrorCode:        callErr.Code,
+		ErrorDescription: callErr.Description,
+		ErrorDetails:     details,
+	}
+}
+
+func (f *Frame) callErr() *CallErr {
+	err := &CallErr{Code: f.ErrorCode, Description: f.ErrorDescription}
+	_ = json.Unmarshal(f.ErrorDetails, &err.Details)
+	return err
+}
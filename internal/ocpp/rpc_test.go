@@ -0,0 +1,91 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    Frame
+		wantErr bool
+	}{
+		{
+			name: "call",
+			raw:  `[2, "1", "Heartbeat", {}]`,
+			want: Frame{Type: Call, ID: "1", Action: "Heartbeat"},
+		},
+		{
+			name: "call result",
+			raw:  `[3, "2", {"status": "Accepted"}]`,
+			want: Frame{Type: CallResult, ID: "2"},
+		},
+		{
+			name: "call error",
+			raw:  `[4, "3", "NotImplemented", "unknown action", {}]`,
+			want: Frame{Type: CallError, ID: "3", ErrorCode: NotImplemented, ErrorDescription: "unknown action"},
+		},
+		{name: "not json", raw: `hello`, wantErr: true},
+		{name: "unknown type", raw: `[5, "4", {}]`, wantErr: true},
+		{name: "short call", raw: `[2, "5", "Heartbeat"]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := ParseFrame([]byte(tt.raw))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidFrame) {
+					t.Fatalf("ParseFrame() error = %v, want ErrInvalidFrame", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFrame() error = %v", err)
+			}
+			if frame.Type != tt.want.Type || frame.ID != tt.want.ID || frame.Action != tt.want.Action {
+				t.Errorf("ParseFrame() = %+v, want %+v", frame, tt.want)
+			}
+			if frame.ErrorCode != tt.want.ErrorCode || frame.ErrorDescription != tt.want.ErrorDescription {
+				t.Errorf("ParseFrame() error fields = %s %q", frame.ErrorCode, frame.ErrorDescription)
+			}
+		})
+	}
+}
+
+func TestFrame_MarshalJSON(t *testing.T) {
+	frame := &Frame{Type: CallResult, ID: "abc", Payload: json.RawMessage(`{"status":"Accepted"}`)}
+	data, err := json.Marshal(frame)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != `[3,"abc",{"status":"Accepted"}]` {
+		t.Errorf("Marshal() = %s", data)
+	}
+}
+
+func TestNewCallErrorFrame(t *testing.T) {
+	frame := newCallErrorFrame("1", errors.New("boom"))
+	if frame.ErrorCode != InternalError || frame.ErrorDescription != "boom" {
+		t.Errorf("plain error = %s %q, want InternalError boom", frame.ErrorCode, frame.ErrorDescription)
+	}
+
+	frame = newCallErrorFrame("2", &CallErr{Code: FormationViolation, Description: "bad"})
+	if frame.ErrorCode != FormationViolation {
+		t.Errorf("ErrorCode = %s, want FormationViolation", frame.ErrorCode)
+	}
+	data, _ := json.Marshal(frame)
+	if string(data) != `[4,"2","FormationViolation","bad",{}]` {
+		t.Errorf("Marshal() = %s", data)
+	}
+
+	// Wrapped CALLERRORs keep their code, and details go in the fifth field.
+	err := fmt.Errorf("start: %w", propertyViolation("connectorId", 0, "connectorId must be greater than 0"))
+	data, _ = json.Marshal(newCallErrorFrame("3", err))
+	if string(data) != `[4,"3","PropertyConstraintViolation","connectorId must be greater than 0",{"field":"connectorId","value":0}]` {
+		t.Errorf("Marshal() = %s", data)
+	}
+}
@@ -0,0 +1,35 @@
+package ocpp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+// handleSchemas lists the JSON Schemas on /schemas and serves one on
+// /schemas/<events|commands>/<name>.json.
+func (s *Server) handleSchemas(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid Method " + r.Method}, http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/schemas"), "/")
+	if name == "" {
+		names := s.schemas.Names()
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = "/schemas/" + name + ".json"
+		}
+		writeJson(w, domain.SchemaList{Count: len(paths), Schemas: paths}, http.StatusOK)
+		return
+	}
+	document, ok := s.schemas.Document(strings.TrimSuffix(name, ".json"))
+	if !ok {
+		writeJson(w, domain.ErrorResponse{Detail: "Schema not found"}, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(document)
+}
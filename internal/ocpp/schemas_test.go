@@ -0,0 +1,66 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/schema"
+	"go.uber.org/zap"
+)
+
+func TestServer_HandleSchemas(t *testing.T) {
+	schemas, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	s := &Server{log: zap.NewNop(), schemas: schemas}
+
+	w := httptest.NewRecorder()
+	s.handleSchemas(w, httptest.NewRequest(http.MethodGet, "/schemas", nil))
+	var list domain.SchemaList
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil || list.Count != len(schemas.Names()) {
+		t.Fatalf("index = %+v, %v", list, err)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleSchemas(w, httptest.NewRequest(http.MethodGet, "/schemas/commands/remote_start_transaction.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/schema+json" {
+		t.Errorf("schema status = %d, Content-Type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	w = httptest.NewRecorder()
+	s.handleSchemas(w, httptest.NewRequest(http.MethodGet, "/schemas/events/unknown.json", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown schema status = %d, want 404", w.Code)
+	}
+}
+
+func TestServer_HandleCommand_FieldErrors(t *testing.T) {
+	schemas, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	s := &Server{log: zap.NewNop(), schemas: schemas}
+
+	body := `{"cp_id":"cp-1","command":"remote_start_transaction","data":{"connector_id":"1"}}`
+	w := httptest.NewRecorder()
+	s.handleCommand(w, httptest.NewRequest(http.MethodPost, "/command/", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	var res domain.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	fields := map[string]string{}
+	for _, e := range res.Errors {
+		fields[e.Field] = e.Message
+	}
+	if res.Detail != "Invalid request body" || fields["data.tag"] != "required" || fields["data.connector_id"] == "" {
+		t.Errorf("response = %+v", res)
+	}
+}
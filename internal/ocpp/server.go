@@ -2,43 +2,167 @@ package ocpp
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/JscorpTech/ocpp/internal/client"
 	"github.com/JscorpTech/ocpp/internal/config"
 	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/journal"
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"github.com/JscorpTech/ocpp/internal/ocpp/v201"
+	"github.com/JscorpTech/ocpp/internal/schema"
 	"github.com/JscorpTech/ocpp/internal/services"
+	"github.com/JscorpTech/ocpp/internal/tracing"
 	"github.com/redis/go-redis/v9"
-	"github.com/voltbras/go-ocpp"
 	"github.com/voltbras/go-ocpp/cs"
+	actions "github.com/voltbras/go-ocpp/messages/req"
 	"github.com/voltbras/go-ocpp/messages/v1x/cpreq"
 	"github.com/voltbras/go-ocpp/messages/v1x/cpresp"
-	"github.com/voltbras/go-ocpp/messages/v1x/csreq"
-	"github.com/voltbras/go-ocpp/messages/v1x/csresp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 type Server struct {
-	cfg   *config.Config
-	ctx   context.Context
-	log   *zap.Logger
-	redis *redis.Client
-	event services.EventService
+	cfg *config.Config
+	// current holds cfg with the settings applied by Reload since.
+	current  atomic.Pointer[config.Config]
+	ctx      context.Context
+	log      *zap.Logger
+	redis    redis.UniversalClient
+	event    services.EventService
+	csys     *CentralSystem
+	commands *Commands
+	profiles *Profiles
+	watchdog *Watchdog
+	presence services.PresenceService
+	backend  client.TransactionClient
+	journal  *journal.Journal
+	schemas  *schema.Registry
+	feed     *services.Feed
+	http     *http.Server
 }
 
-func NewServer(ctx context.Context, cfg *config.Config, logger *zap.Logger, rdb *redis.Client) *Server {
-	return &Server{
-		cfg:   cfg,
-		ctx:   ctx,
-		log:   logger,
-		redis: rdb,
-		event: services.NewEventService(),
+func NewServer(ctx context.Context, cfg *config.Config, logger *zap.Logger, rdb redis.UniversalClient, backend client.TransactionClient) *Server {
+	// The schemas come from the compiled-in domain types, so this only
+	// fails on a programming error.
+	schemas, err := schema.New()
+	if err != nil {
+		panic(err)
 	}
+	var feed *services.Feed
+	if cfg.LiveEventsToken != "" {
+		feed = services.NewFeed(rdb, liveEventsChannel, int(cfg.LiveEventsBuffer), logger)
+	}
+	s := &Server{
+		cfg:      cfg,
+		ctx:      ctx,
+		log:      logger,
+		redis:    rdb,
+		event:    newEventService(cfg, rdb, schemas, feed, logger),
+		schemas:  schemas,
+		feed:     feed,
+		presence: services.NewPresenceService(rdb),
+		backend:  backend,
+		http:     &http.Server{Addr: cfg.Addr},
+	}
+	s.current.Store(cfg)
+	if feed != nil {
+		s.http.RegisterOnShutdown(feed.Disconnect)
+	}
+	s.csys = NewCentralSystem(logger, s.handleRequest)
+	s.csys.SetTrustedProxies(cfg.TrustedProxies)
+	s.commands = NewCommands(s.csys, rdb, logger)
+	s.profiles = NewProfiles(s.commands, services.NewDriftService(rdb))
+	s.watchdog = NewWatchdog(s.csys, cfg.HeartbeatInterval, cfg.HeartbeatGrace)
+	if cfg.JournalDir != "" {
+		j, err := journal.New(cfg.JournalDir, cfg.JournalMaxSize, int(cfg.JournalMaxFiles), logger)
+		if err != nil {
+			logger.Error("Journal disabled", zap.Error(err))
+		} else {
+			s.journal = j
+		}
+	}
+	return s
+}
+
+// newEventService routes events to the sinks in EVENT_SINKS, each behind its
+// own outbox, and to the live feed when there is one. Events get their
+// envelope stamped and are checked against their schema first. A sink that
+// cannot be set up is left out.
+func newEventService(cfg *config.Config, rdb redis.UniversalClient, schemas *schema.Registry, feed *services.Feed, logger *zap.Logger) services.EventService {
+	var routes []services.Route
+	for _, name := range cfg.EventSinks {
+		sink, err := newSink(name, cfg, rdb)
+		if err != nil {
+			logger.Error("Event sink disabled", zap.String("sink", name), zap.Error(err))
+			continue
+		}
+		if cfg.OutboxDir != "" {
+			outbox, err := services.NewOutbox(filepath.Join(cfg.OutboxDir, name), cfg.OutboxMaxBytes, sink, logger)
+			if err != nil {
+				logger.Error("Outbox disabled", zap.String("sink", name), zap.Error(err))
+			} else {
+				sink = outbox
+			}
+		}
+		routes = append(routes, services.Route{Sink: sink, Events: cfg.SinkEvents[name]})
+	}
+	if feed != nil {
+		routes = append(routes, services.Route{Sink: feed})
+	}
+	event := services.NewEventService(routes...)
+	if cfg.EventValidation != "off" {
+		event = services.WithValidation(event, schemas, cfg.EventValidation == "strict", logger)
+	}
+	return services.WithEnvelope(event, rdb, cfg.InstanceID, logger)
+}
+
+func newSink(name string, cfg *config.Config, rdb redis.UniversalClient) (services.Sink, error) {
+	format, err := services.ParseFormat(cfg.SinkFormats[name])
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "redis":
+		if cfg.EventsTransport == "stream" {
+			return services.NewStreamSink(rdb, cfg.EventsStream, cfg.EventsStreamMaxLen, cfg.EventsStreamGroup, format), nil
+		}
+		return services.NewRedisSink(rdb, format), nil
+	case "webhook":
+		return services.NewWebhookSink(services.WebhookOptions{
+			URL:     cfg.WebhookURL,
+			Secret:  cfg.WebhookSecret,
+			Timeout: cfg.EventSinkTimeout,
+			Format:  format,
+		}), nil
+	case "nats":
+		return services.NewNatsSink(cfg.NatsURL, cfg.NatsSubject, cfg.EventSinkTimeout, format)
+	case "kafka":
+		return services.NewKafkaSink(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.EventSinkTimeout, format)
+	case "mqtt":
+		return services.NewMqttSink(services.MqttOptions{
+			URL:      cfg.MqttURL,
+			ClientID: cfg.MqttClientID,
+			Topic:    cfg.MqttTopic,
+			QoS:      byte(cfg.MqttQoS),
+			Timeout:  cfg.EventSinkTimeout,
+			Format:   format,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown event sink %q", name)
 }
 
 func writeJson(w http.ResponseWriter, data any, statusCode int) {
@@ -46,144 +170,461 @@ func writeJson(w http.ResponseWriter, data any, statusCode int) {
 	json.NewEncoder(w).Encode(data)
 }
 
-func (s *Server) Validate(w http.ResponseWriter, req domain.RemoteCommandReq) string {
-	if req.Command == "" {
-		return "Command required"
-	}
-	if req.CpID == "" {
-		return "CpId required"
+func (s *Server) Run() error {
+	s.http.Handler = s.Handler()
+	var err error
+	if s.cfg.TLSCertFile != "" {
+		err = s.http.ListenAndServeTLS(s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+	} else {
+		err = s.http.ListenAndServe()
 	}
-	if len(req.Data) == 0 {
-		return "Data required"
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
-	return ""
+	return nil
 }
 
-func (s *Server) Run() error {
-	ocpp.SetDebugLogger(log.New(os.Stdout, "DEBUG:", log.Ltime))
-	ocpp.SetErrorLogger(log.New(os.Stderr, "ERROR:", log.Ltime))
-	csys := cs.New()
+// settings is the configuration with the latest reload applied. Read the
+// reloadable settings through it.
+func (s *Server) settings() *config.Config {
+	return s.current.Load()
+}
+
+// Reload applies the settings of next that can change at runtime and returns
+// the variables of those that need a restart, which keep their old value.
+func (s *Server) Reload(next *config.Config) []string {
+	cfg, restart := s.settings().Reload(next)
+	s.current.Store(cfg)
+	s.watchdog.SetTiming(cfg.HeartbeatInterval, cfg.HeartbeatGrace)
+	return restart
+}
 
-	csys.SetChargePointDisconnectionListener(func(CpID string, host string) {
+// authorized checks the API_TOKEN bearer token of the management API.
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		want := s.settings().APIToken
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJson(w, domain.ErrorResponse{Detail: "Unauthorized"}, http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Handler wires the charger listeners, starts the background work and
+// returns the HTTP routes. Run serves it on cfg.Addr; tests and embedders can
+// mount it themselves. Call it once.
+func (s *Server) Handler() http.Handler {
+	s.csys.SetDisconnectionListener(func(conn *Conn) {
+		s.watchdog.Forget(conn)
+		// The charger is still here on its new connection.
+		if conn.Replaced() {
+			return
+		}
 		event := domain.Event{
-			Domain: host,
+			Domain: conn.Host,
+			CpID:   conn.ID,
 			Event:  domain.DisconnectChargerEvent,
 			Data: domain.DisconnectCharger{
-				Charger: CpID,
+				Charger: conn.ID,
 			},
 		}
-		s.event.SendEvent(s.ctx, s.redis, &event, s.log)
+		s.event.SendEvent(s.ctx, &event, s.log)
+		if err := s.presence.Unregister(s.ctx, conn.ID, conn.SessionID); err != nil {
+			s.log.Error("presence error", zap.Error(err))
+		}
+		if s.journal != nil {
+			s.journal.Close(conn.ID)
+		}
 	})
 
-	csys.SetChargePointConnectionListener(func(CpID string, host string) {
+	s.csys.SetConnectionListener(func(conn *Conn) {
+		s.registerPresence(conn)
 		event := domain.Event{
-			Domain: host,
+			Domain: conn.Host,
+			CpID:   conn.ID,
 			Event:  domain.ConnectChargerEvent,
 			Data: domain.ConnectCharger{
-				Charger: CpID,
+				Charger: conn.ID,
 			},
 		}
-		s.event.SendEvent(s.ctx, s.redis, &event, s.log)
+		s.event.SendEvent(s.ctx, &event, s.log)
 	})
 
-	http.HandleFunc("/command/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			writeJson(w, domain.ErrorResponse{Detail: "Invalid Method " + r.Method}, http.StatusBadRequest)
-			return
+	s.csys.SetMessageListener(s.watchdog.Seen)
+	if s.journal != nil {
+		s.csys.SetFrameListener(s.recordFrame)
+	}
+	s.watchdog.SetOfflineListener(func(conn *Conn, connectors []int) {
+		s.log.Warn("Charger went silent", zap.String("cp_id", conn.ID), zap.Time("last_message_at", conn.LastMessageAt()))
+		event := domain.Event{
+			Domain: conn.Host,
+			CpID:   conn.ID,
+			Event:  domain.OfflineEvent,
+			Data: domain.ChargerOffline{
+				Charger:       conn.ID,
+				LastMessageAt: conn.LastMessageAt(),
+			},
 		}
-		var req domain.RemoteCommandReq
-		dataByte, err := io.ReadAll(r.Body)
-		if err != nil {
-			writeJson(w, domain.ErrorResponse{Detail: "Invalid request"}, http.StatusBadRequest)
-			return
+		s.event.SendEvent(s.ctx, &event, s.log)
+		for _, connector := range connectors {
+			event := domain.Event{
+				Domain: conn.Host,
+				CpID:   conn.ID,
+				Event:  domain.ChangeConnectorStatusEvent,
+				Data: domain.ChangeConnectorStatus{
+					Charger: conn.ID,
+					Conn:    connector,
+					Status:  "Unavailable",
+				},
+			}
+			s.event.SendEvent(s.ctx, &event, s.log)
 		}
-		if err := json.Unmarshal(dataByte, &req); err != nil {
-			s.log.Warn("Invalid request body", zap.Any("req", string(dataByte)))
-			writeJson(w, domain.ErrorResponse{Detail: "Invalid request body " + err.Error()}, http.StatusBadRequest)
-			return
+	})
+	s.watchdog.SetOnlineListener(func(conn *Conn) {
+		event := domain.Event{
+			Domain: conn.Host,
+			CpID:   conn.ID,
+			Event:  domain.OnlineEvent,
+			Data: domain.ChargerOnline{
+				Charger: conn.ID,
+			},
 		}
-		if res := s.Validate(w, req); res != "" {
-			writeJson(w, domain.ErrorResponse{Detail: res}, http.StatusBadRequest)
+		s.event.SendEvent(s.ctx, &event, s.log)
+	})
+	go s.watchdog.Run(s.ctx)
+	go s.event.Run(s.ctx)
+	go s.refreshPresence()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", s.csys)
+	mux.Handle("/command/", otelhttp.NewHandler(s.authorized(s.handleCommand), "command"))
+	mux.HandleFunc("/chargers", s.authorized(s.handleChargers))
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/journal", s.authorized(s.handleJournal))
+	mux.HandleFunc("/profiles/drift", s.authorized(s.handleDrift))
+	mux.HandleFunc("/configuration", s.authorized(s.handleConfiguration))
+	mux.HandleFunc("/schemas", s.handleSchemas)
+	mux.HandleFunc("/schemas/", s.handleSchemas)
+	mux.HandleFunc("/events/stream", s.handleEventStream)
+	return mux
+}
+
+// Shutdown drains the server: no new chargers or HTTP requests are accepted,
+// in-flight CALLs and commands finish, and the charger sockets are closed
+// once their disconnect events are in Redis. Events keep using the server
+// context, so cancel it only after Shutdown returns.
+func (s *Server) Shutdown(ctx context.Context) error {
+	httpDone := make(chan error, 1)
+	go func() {
+		httpDone <- s.http.Shutdown(ctx)
+	}()
+	err := s.csys.Shutdown(ctx)
+	if httpErr := <-httpDone; err == nil {
+		err = httpErr
+	}
+	return err
+}
+
+// Close releases the event sinks and writes out the journal. Call it after
+// Shutdown, once the server context is cancelled; disconnect listeners that
+// outlive the drain deadline only lose their journal entries.
+func (s *Server) Close() error {
+	var err error
+	if s.journal != nil {
+		err = s.journal.Stop()
+	}
+	return errors.Join(err, s.event.Close())
+}
+
+func (s *Server) handleCommand(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid Method " + r.Method}, http.StatusMethodNotAllowed)
+		return
+	}
+	var req domain.RemoteCommandReq
+	dataByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid request"}, http.StatusBadRequest)
+		return
+	}
+	if errs := s.schemas.ValidateCommand(dataByte); errs != nil {
+		s.log.Warn("Invalid request body", zap.Any("req", string(dataByte)), zap.Any("errors", errs))
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid request body", Errors: errs}, http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal(dataByte, &req); err != nil {
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid request body " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	res, err := s.commands.Execute(r.Context(), req)
+	if err != nil {
+		s.log.Error("remote command error", zap.String("cp_id", req.CpID), zap.String("command", string(req.Command)), zap.Error(err))
+		writeJson(w, domain.ErrorResponse{Detail: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	writeJson(w, res, http.StatusOK)
+}
+
+func (s *Server) registerPresence(conn *Conn) {
+	err := s.presence.Register(s.ctx, &domain.ChargerPresence{
+		CpID:          conn.ID,
+		Domain:        conn.Host,
+		RemoteAddr:    conn.RemoteAddr,
+		Version:       string(conn.Version),
+		ConnectedAt:   conn.ConnectedAt,
+		SessionID:     conn.SessionID,
+		LastMessageAt: conn.LastMessageAt(),
+		InstanceID:    s.cfg.InstanceID,
+	})
+	if err != nil {
+		s.log.Error("presence error", zap.String("cp_id", conn.ID), zap.Error(err))
+	}
+}
+
+// refreshPresence rewrites the registry entries of our chargers well within
+// services.PresenceTTL, which also keeps last_message_at current.
+func (s *Server) refreshPresence() {
+	ticker := time.NewTicker(services.PresenceTTL / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, conn := range s.csys.Conns() {
+				s.registerPresence(conn)
+			}
+		case <-s.ctx.Done():
 			return
 		}
-		w.Header().Add("Content-Type", "application/json")
-		station, err := csys.GetServiceOf(req.CpID, ocpp.V16, "")
-		if err != nil {
-			writeJson(w, domain.ErrorResponse{Detail: "Charger not connected"}, http.StatusBadRequest)
-			s.log.Error("Station error", zap.Error(err))
-			return
+	}
+}
+
+func (s *Server) handleChargers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid Method " + r.Method}, http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	chargers, err := s.presence.List(r.Context(), domain.ChargerFilter{
+		CpID:       query.Get("cp_id"),
+		Domain:     query.Get("domain"),
+		Version:    query.Get("version"),
+		InstanceID: query.Get("instance_id"),
+	})
+	if err != nil {
+		s.log.Error("presence error", zap.Error(err))
+		writeJson(w, domain.ErrorResponse{Detail: "Registry unavailable"}, http.StatusServiceUnavailable)
+		return
+	}
+	writeJson(w, domain.ChargerList{Count: len(chargers), Chargers: chargers}, http.StatusOK)
+}
+
+// handleDrift lists the outcome of the last profile check of each charger;
+// drifted=true keeps those not in sync.
+func (s *Server) handleDrift(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid Method " + r.Method}, http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	drifted, _ := strconv.ParseBool(query.Get("drifted"))
+	chargers, err := s.profiles.drift.List(r.Context(), domain.DriftFilter{CpID: query.Get("cp_id"), Drifted: drifted})
+	if err != nil {
+		s.log.Error("drift error", zap.Error(err))
+		writeJson(w, domain.ErrorResponse{Detail: "Registry unavailable"}, http.StatusServiceUnavailable)
+		return
+	}
+	writeJson(w, domain.DriftList{Count: len(chargers), Chargers: chargers}, http.StatusOK)
+}
+
+// handleConfiguration returns the last known configuration of a charger,
+// which needs not be connected; key narrows it to some keys.
+func (s *Server) handleConfiguration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid Method " + r.Method}, http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	cpID := query.Get("cp_id")
+	if cpID == "" {
+		writeJson(w, domain.ErrorResponse{Detail: "cp_id is required"}, http.StatusBadRequest)
+		return
+	}
+	snapshot, err := s.commands.snapshots.Get(r.Context(), cpID)
+	if err != nil {
+		s.log.Error("snapshot error", zap.String("cp_id", cpID), zap.Error(err))
+		writeJson(w, domain.ErrorResponse{Detail: "Registry unavailable"}, http.StatusServiceUnavailable)
+		return
+	}
+	if snapshot == nil {
+		writeJson(w, domain.ErrorResponse{Detail: "No configuration known for " + cpID}, http.StatusNotFound)
+		return
+	}
+	if keys := query["key"]; len(keys) > 0 {
+		snapshot.ConfigurationKey = slices.DeleteFunc(snapshot.ConfigurationKey, func(k domain.SnapshotKey) bool { return !slices.Contains(keys, k.Key) })
+		snapshot.UnknownKey = slices.DeleteFunc(snapshot.UnknownKey, func(k string) bool { return !slices.Contains(keys, k) })
+	}
+	writeJson(w, snapshot, http.StatusOK)
+}
+
+// handleRequest decodes a charger CALL for the negotiated version and hands
+// it to the matching handler.
+func (s *Server) handleRequest(ctx context.Context, conn *Conn, action string, payload json.RawMessage) (any, error) {
+	ctx = services.WithReceivedAt(ctx, time.Now())
+	ctx, span := tracing.Tracer().Start(ctx, "handler "+actionLabel(conn.Version, action), trace.WithAttributes(
+		tracing.ChargerID.String(conn.ID),
+		tracing.Action.String(action),
+	))
+	defer span.End()
+	handler := NewHandler(ctx, s.log, s.redis, s.backend, cs.ChargePointRequestMetadata{
+		ChargePointID: conn.ID,
+		HTTPRequest:   conn.Request,
+		Host:          conn.Host,
+	}, s.settings(), s.event)
+	var resp any
+	var err error
+	if conn.Version == V201 {
+		resp, err = s.dispatchV201(conn, handler.V201(), action, payload)
+	} else {
+		resp, err = s.dispatchV16(conn, handler, action, payload)
+	}
+	if err == nil {
+		s.trackBoot(conn, resp)
+	} else {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return resp, err
+}
+
+// trackBoot records the heartbeat interval a charger was given, so the
+// watchdog knows how long it may stay silent.
+func (s *Server) trackBoot(conn *Conn, resp any) {
+	switch resp := resp.(type) {
+	case *cpresp.BootNotification:
+		if resp.Status == "Accepted" {
+			conn.SetHeartbeatInterval(time.Duration(resp.Interval) * time.Second)
 		}
-		switch req.Command {
-		case domain.RemoteStartTransaction:
-			var data domain.RemoteStartTransactionReq
-			json.Unmarshal(req.Data, &data)
-			resp, err := station.Send(&csreq.RemoteStartTransaction{
-				IdTag:       data.Tag,
-				ConnectorId: data.ConnectorID,
-			})
-			if err != nil {
-				writeJson(w, domain.ErrorResponse{Detail: "Internal server error"}, http.StatusBadRequest)
-				s.log.Error("remote start transaction error", zap.Error(err))
-			}
-			res := resp.(*csresp.RemoteStartTransaction)
-			writeJson(w, domain.RemoteStartTransactionRes{Status: res.Status}, http.StatusOK)
-		case domain.RemoteStopTransaction:
-			var data domain.RemoteStopTransactionReq
-			json.Unmarshal(req.Data, &data)
-			resp, err := station.Send(&csreq.RemoteStopTransaction{TransactionId: data.TransactionId})
-			if err != nil {
-				writeJson(w, domain.ErrorResponse{Detail: "Internal server error"}, http.StatusBadRequest)
-				s.log.Error("remote stop transaction error", zap.Error(err))
-			}
-			res := resp.(*csresp.RemoteStopTransaction)
-			writeJson(w, domain.RemoteStopTransactionRes{Status: res.Status}, http.StatusOK)
-		case domain.GetConfiguration:
-			var data domain.GetConfigurationReq
-			json.Unmarshal(req.Data, &data)
-			resp, err := station.Send(&csreq.GetConfiguration{Key: data.Key})
-			if err != nil {
-				writeJson(w, domain.ErrorResponse{Detail: "Error"}, http.StatusBadRequest)
-			}
-			res := resp.(*csresp.GetConfiguration)
-			writeJson(w, res, http.StatusOK)
-		case domain.ChangeConfiguration:
-			var data domain.ChangeConfigurationReq
-			json.Unmarshal(req.Data, &data)
-			resp, err := station.Send(&csreq.ChangeConfiguration{Key: data.Key, Value: data.Value})
-			if err != nil {
-				writeJson(w, domain.ErrorResponse{Detail: "Error"}, http.StatusBadRequest)
-			}
-			res := resp.(*csresp.ChangeConfiguration)
-			writeJson(w, res, http.StatusOK)
-		default:
-			writeJson(w, domain.ErrorResponse{Detail: "Invalid command"}, http.StatusBadRequest)
-			s.log.Info("Invalid command")
+	case *v201.BootNotificationResponse:
+		if resp.Status == "Accepted" {
+			conn.SetHeartbeatInterval(time.Duration(resp.Interval) * time.Second)
 		}
-	})
+	}
+}
 
-	return csys.Run(s.cfg.Addr, func(req cpreq.ChargePointRequest, metadata cs.ChargePointRequestMetadata) (cpresp.ChargePointResponse, error) {
-		handler := NewHandler(s.ctx, s.log, s.redis, metadata, s.cfg, s.event)
-		switch req := req.(type) {
-		case *cpreq.BootNotification:
-			return handler.BootNotification(req)
-		case *cpreq.StatusNotification:
-			return handler.StatusNotification(req)
-		case *cpreq.Authorize:
-			return handler.Authorize(req)
-		case *cpreq.Heartbeat:
-			return handler.Heartbeart(req)
-		case *cpreq.MeterValues:
-			return handler.MeterValues(req)
-		case *cpreq.StartTransaction:
-			return handler.StartTransaction(req)
-		case *cpreq.StopTransaction:
-			return handler.StopTransaction(req)
-		case *cpreq.DataTransfer:
-			return handler.DataTransfer(req)
-		default:
-			fmt.Printf("EXAMPLE(MAIN): action not supported: %s\n", req.Action())
-			return nil, errors.New("Response not supported")
+// applyProfiles checks the charger against its configuration profiles once
+// it has the answer to its BootNotification.
+func (s *Server) applyProfiles(conn *Conn, vendor, model string) {
+	profiles := s.settings().Profiles
+	conn.AfterResponse(func() {
+		drift, err := s.profiles.Enforce(s.ctx, conn, profiles, vendor, model)
+		switch {
+		case err != nil:
+			s.log.Error("Configuration profile error", zap.String("cp_id", conn.ID), zap.Error(err))
+		case drift != nil && !drift.InSync:
+			s.log.Warn("Charger configuration drifted from its profile", zap.String("cp_id", conn.ID), zap.Strings("profiles", drift.Profiles), zap.Any("keys", drift.Keys))
+		case drift != nil:
+			s.log.Info("Charger configuration in sync", zap.String("cp_id", conn.ID), zap.Strings("profiles", drift.Profiles))
 		}
 	})
 }
+
+func (s *Server) dispatchV16(conn *Conn, handler *Handlers, action string, payload json.RawMessage) (any, error) {
+	message := actions.FromActionName(action)
+	if message == nil {
+		return nil, &CallErr{Code: NotImplemented, Description: "Unknown action " + action}
+	}
+	// A central system action, such as Reset, sent by the charger.
+	request, ok := message.(cpreq.ChargePointRequest)
+	if !ok {
+		return nil, &CallErr{Code: NotSupported, Description: "Not a charge point action " + action}
+	}
+	if err := json.Unmarshal(payload, request); err != nil {
+		return nil, &CallErr{Code: FormationViolation, Description: err.Error()}
+	}
+	switch req := request.(type) {
+	case *cpreq.BootNotification:
+		resp, err := handler.BootNotification(req)
+		if boot, ok := resp.(*cpresp.BootNotification); ok && err == nil && boot.Status == "Accepted" {
+			s.applyProfiles(conn, req.ChargePointVendor, req.ChargePointModel)
+		}
+		return resp, err
+	case *cpreq.StatusNotification:
+		s.watchdog.Connector(conn, req.ConnectorId)
+		return handler.StatusNotification(req)
+	case *cpreq.Authorize:
+		return handler.Authorize(req)
+	case *cpreq.Heartbeat:
+		return handler.Heartbeart(req)
+	case *cpreq.MeterValues:
+		if req.TransactionId != 0 {
+			conn.TrackTransaction(strconv.Itoa(int(req.TransactionId)), true)
+		}
+		return handler.MeterValues(req)
+	case *cpreq.StartTransaction:
+		resp, err := handler.StartTransaction(req)
+		if start, ok := resp.(*cpresp.StartTransaction); ok && err == nil {
+			conn.TrackTransaction(strconv.Itoa(int(start.TransactionId)), true)
+		}
+		return resp, err
+	case *cpreq.StopTransaction:
+		conn.TrackTransaction(strconv.Itoa(req.TransactionId), false)
+		return handler.StopTransaction(req)
+	case *cpreq.DataTransfer:
+		return handler.DataTransfer(req)
+	default:
+		// A 1.6 action from a profile we do not implement, such as
+		// FirmwareStatusNotification.
+		return nil, &CallErr{Code: NotSupported, Description: "Action not supported " + action}
+	}
+}
+
+func (s *Server) dispatchV201(conn *Conn, handler *HandlersV201, action string, payload json.RawMessage) (any, error) {
+	request := v201.NewRequest(action)
+	if request == nil {
+		return nil, &CallErr{Code: NotImplemented, Description: "action not supported: " + action}
+	}
+	if err := json.Unmarshal(payload, request); err != nil {
+		return nil, &CallErr{Code: FormationViolation, Description: err.Error()}
+	}
+	switch req := request.(type) {
+	case *v201.BootNotificationRequest:
+		resp, err := handler.BootNotification(req)
+		if err == nil && resp.Status == "Accepted" {
+			s.applyProfiles(conn, req.ChargingStation.VendorName, req.ChargingStation.Model)
+		}
+		return resp, err
+	case *v201.StatusNotificationRequest:
+		s.watchdog.Connector(conn, req.EvseId)
+		return handler.StatusNotification(req)
+	case *v201.AuthorizeRequest:
+		return handler.Authorize(req)
+	case *v201.HeartbeatRequest:
+		return handler.Heartbeat(req)
+	case *v201.MeterValuesRequest:
+		return handler.MeterValues(req)
+	case *v201.TransactionEventRequest:
+		conn.TrackTransaction(req.TransactionInfo.TransactionId, req.EventType != v201.TransactionEnded)
+		return handler.TransactionEvent(req)
+	case *v201.NotifyReportRequest:
+		resp, err := handler.NotifyReport(req)
+		if err == nil {
+			// Reports come in parts, so each one is merged.
+			if err := s.commands.snapshots.Record(s.ctx, conn.ID, reportKeys(req), nil, false); err != nil {
+				s.log.Error("Configuration snapshot error", zap.String("cp_id", conn.ID), zap.Error(err))
+			}
+		}
+		return resp, err
+	default:
+		return nil, &CallErr{Code: NotImplemented, Description: "action not supported: " + action}
+	}
+}
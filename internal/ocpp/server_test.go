@@ -0,0 +1,325 @@
+package ocpp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/client"
+	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"github.com/JscorpTech/ocpp/internal/services"
+	"github.com/JscorpTech/ocpp/internal/simulator"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// testServer is the whole server in-process: an in-memory Redis behind it
+// and the fake backend handing out transactions.
+type testServer struct {
+	url     string
+	rdb     redis.UniversalClient
+	backend *client.FakeBackend
+	server  *ocpp.Server
+	cfg     *config.Config
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	fake := &client.FakeBackend{}
+	backend := httptest.NewServer(fake)
+	cfg := &config.Config{
+		BaseUrl:           backend.URL,
+		InstanceID:        "test",
+		HeartbeatInterval: time.Minute,
+		EventSinks:        []string{"redis"},
+		EventValidation:   "strict",
+	}
+	s := ocpp.NewServer(ctx, cfg, zap.NewNop(), rdb, client.NewTransactionClient(cfg))
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancelDrain()
+		s.Shutdown(drainCtx)
+		server.Close()
+		cancel()
+		s.Close()
+		backend.Close()
+		rdb.Close()
+	})
+	return &testServer{url: server.URL, rdb: rdb, backend: fake, server: s, cfg: cfg}
+}
+
+func (ts *testServer) dial(t *testing.T, id string) *simulator.ChargePoint {
+	t.Helper()
+	cp, err := simulator.Dial(context.Background(), simulator.Options{
+		URL:           "ws" + strings.TrimPrefix(ts.url, "http"),
+		ID:            id,
+		Timeout:       2 * time.Second,
+		MeterInterval: 20 * time.Millisecond,
+		MeterEnergy:   10,
+	})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { cp.Close() })
+	return cp
+}
+
+// command posts to /command/ and returns the status the charger answered.
+func (ts *testServer) command(t *testing.T, cpID string, command domain.RemoteCommand, data string) string {
+	t.Helper()
+	body, _ := json.Marshal(domain.RemoteCommandReq{CpID: cpID, Command: command, Data: json.RawMessage(data)})
+	res, err := http.Post(ts.url+"/command/", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	defer res.Body.Close()
+	var out struct {
+		Status string `json:"status"`
+		Detail string `json:"detail"`
+	}
+	json.NewDecoder(res.Body).Decode(&out)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("%s status = %d, detail = %s", command, res.StatusCode, out.Detail)
+	}
+	return out.Status
+}
+
+// events returns the types of the events pushed to Redis so far.
+func (ts *testServer) events(t *testing.T) []domain.EventTypes {
+	t.Helper()
+	payloads, err := ts.rdb.LRange(context.Background(), services.EventsKey, 0, -1).Result()
+	if err != nil {
+		t.Fatalf("LRange() error = %v", err)
+	}
+	types := make([]domain.EventTypes, 0, len(payloads))
+	for _, payload := range payloads {
+		var event domain.Event
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			t.Fatalf("event %s: %v", payload, err)
+		}
+		types = append(types, event.Event)
+	}
+	return types
+}
+
+func (ts *testServer) awaitEvent(t *testing.T, event domain.EventTypes) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if slices.Contains(ts.events(t), event) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %s event, got %v", event, ts.events(t))
+}
+
+func TestServer_ChargingSession(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	cp := ts.dial(t, "CP-1")
+	if err := cp.Run(ctx, simulator.Scenarios["idle"]); err != nil {
+		t.Fatalf("Run(idle) error = %v", err)
+	}
+
+	res, err := http.Get(ts.url + "/chargers")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	var chargers domain.ChargerList
+	json.NewDecoder(res.Body).Decode(&chargers)
+	res.Body.Close()
+	if chargers.Count != 1 || chargers.Chargers[0].CpID != "127.0.0.1:CP-1" || chargers.Chargers[0].InstanceID != "test" {
+		t.Fatalf("chargers = %+v, want CP-1 on this instance", chargers)
+	}
+
+	if status := ts.command(t, "127.0.0.1:CP-1", domain.RemoteStartTransaction, `{"tag":"TAG-1","connector_id":1}`); status != "Accepted" {
+		t.Fatalf("remote start = %s, want Accepted", status)
+	}
+	ts.awaitEvent(t, domain.StartTransactionEvent)
+	ts.awaitEvent(t, domain.MeterValuesEvent)
+	if ts.backend.Transactions() != 1 {
+		t.Errorf("backend transactions = %d, want 1", ts.backend.Transactions())
+	}
+
+	if status := ts.command(t, "127.0.0.1:CP-1", domain.RemoteStopTransaction, `{"transaction_id":1}`); status != "Accepted" {
+		t.Fatalf("remote stop = %s, want Accepted", status)
+	}
+	ts.awaitEvent(t, domain.StopTransactionEvent)
+
+	cp.Close()
+	ts.awaitEvent(t, domain.DisconnectChargerEvent)
+
+	events := ts.events(t)
+	order := []domain.EventTypes{
+		domain.ConnectChargerEvent,
+		domain.StartTransactionEvent,
+		domain.StopTransactionEvent,
+		domain.DisconnectChargerEvent,
+	}
+	last := -1
+	for _, event := range order {
+		i := slices.Index(events, event)
+		if i < last {
+			t.Fatalf("events = %v, want %v in that order", events, order)
+		}
+		last = i
+	}
+}
+
+func TestServer_Reconnect(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	ts.dial(t, "CP-1")
+	ts.awaitEvent(t, domain.ConnectChargerEvent)
+	// The charger comes back before the old connection is gone.
+	cp := ts.dial(t, "CP-1")
+	if err := cp.Run(ctx, simulator.Scenarios["idle"]); err != nil {
+		t.Fatalf("Run(idle) error = %v", err)
+	}
+
+	res, err := http.Get(ts.url + "/chargers")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	var chargers domain.ChargerList
+	json.NewDecoder(res.Body).Decode(&chargers)
+	res.Body.Close()
+	if chargers.Count != 1 {
+		t.Fatalf("chargers = %+v, want CP-1 still listed", chargers)
+	}
+
+	cp.Close()
+	ts.awaitEvent(t, domain.DisconnectChargerEvent)
+	var connects, disconnects int
+	for _, event := range ts.events(t) {
+		switch event {
+		case domain.ConnectChargerEvent:
+			connects++
+		case domain.DisconnectChargerEvent:
+			disconnects++
+		}
+	}
+	if connects != 2 || disconnects != 1 {
+		t.Errorf("events = %v, want two connects and only the last disconnect", ts.events(t))
+	}
+}
+
+func TestServer_Reload(t *testing.T) {
+	ts := newTestServer(t)
+	get := func(token string) int {
+		req, _ := http.NewRequest(http.MethodGet, ts.url+"/chargers", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /chargers error = %v", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if code := get(""); code != http.StatusOK {
+		t.Fatalf("GET /chargers = %d without API_TOKEN, want 200", code)
+	}
+
+	next := *ts.cfg
+	next.APIToken = "secret"
+	next.Addr = ":9999"
+	if restart := ts.server.Reload(&next); !slices.Equal(restart, []string{"ADDR"}) {
+		t.Errorf("Reload() = %v, want ADDR to need a restart", restart)
+	}
+	if code := get(""); code != http.StatusUnauthorized {
+		t.Errorf("GET /chargers = %d without a token, want 401", code)
+	}
+	if code := get("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("GET /chargers = %d with a wrong token, want 401", code)
+	}
+	if code := get("secret"); code != http.StatusOK {
+		t.Errorf("GET /chargers = %d with the token, want 200", code)
+	}
+}
+
+func TestServer_Profiles(t *testing.T) {
+	ts := newTestServer(t)
+	next := *ts.cfg
+	next.Profiles = []config.Profile{{
+		Name:     "sim",
+		Match:    config.ProfileMatch{Model: "SIM-1"},
+		Settings: map[string]string{"MeterValueSampleInterval": "30", "NumberOfConnectors": "4"},
+	}}
+	if restart := ts.server.Reload(&next); len(restart) != 0 {
+		t.Fatalf("Reload() = %v, want profiles to reload", restart)
+	}
+	cp := ts.dial(t, "CP-1")
+	if err := cp.Run(context.Background(), simulator.Scenarios["idle"]); err != nil {
+		t.Fatalf("Run(idle) error = %v", err)
+	}
+
+	var drift domain.DriftList
+	deadline := time.Now().Add(5 * time.Second)
+	for drift.Count == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		res, err := http.Get(ts.url + "/profiles/drift?drifted=true")
+		if err != nil {
+			t.Fatalf("GET /profiles/drift error = %v", err)
+		}
+		json.NewDecoder(res.Body).Decode(&drift)
+		res.Body.Close()
+	}
+	if drift.Count != 1 || drift.Chargers[0].CpID != "127.0.0.1:CP-1" {
+		t.Fatalf("drift = %+v, want CP-1", drift)
+	}
+	keys := drift.Chargers[0].Keys
+	if len(keys) != 2 || keys[0].Status != domain.KeyAccepted || keys[1].Status != domain.KeyReadonly {
+		t.Errorf("keys = %+v, want MeterValueSampleInterval changed and NumberOfConnectors readonly", keys)
+	}
+}
+
+func TestServer_Configuration(t *testing.T) {
+	ts := newTestServer(t)
+	cp := ts.dial(t, "CP-1")
+	if err := cp.Run(context.Background(), simulator.Scenarios["idle"]); err != nil {
+		t.Fatalf("Run(idle) error = %v", err)
+	}
+	ts.command(t, "127.0.0.1:CP-1", domain.GetConfiguration, `{"key":[]}`)
+	if status := ts.command(t, "127.0.0.1:CP-1", domain.ChangeConfiguration, `{"key":"MeterValueSampleInterval","value":"30"}`); status != "Accepted" {
+		t.Fatalf("change = %s, want Accepted", status)
+	}
+	cp.Close()
+	ts.awaitEvent(t, domain.DisconnectChargerEvent)
+
+	get := func(query string) (*domain.ConfigurationSnapshot, int) {
+		res, err := http.Get(ts.url + "/configuration?" + query)
+		if err != nil {
+			t.Fatalf("GET /configuration error = %v", err)
+		}
+		defer res.Body.Close()
+		var snapshot domain.ConfigurationSnapshot
+		json.NewDecoder(res.Body).Decode(&snapshot)
+		return &snapshot, res.StatusCode
+	}
+	snapshot, code := get("cp_id=127.0.0.1:CP-1")
+	if code != http.StatusOK || len(snapshot.ConfigurationKey) != 3 {
+		t.Fatalf("GET /configuration = %d %+v, want the three simulator keys", code, snapshot)
+	}
+	snapshot, _ = get("cp_id=127.0.0.1:CP-1&key=MeterValueSampleInterval")
+	if len(snapshot.ConfigurationKey) != 1 || snapshot.ConfigurationKey[0].Value != "30" {
+		t.Errorf("keys = %+v, want the changed MeterValueSampleInterval", snapshot.ConfigurationKey)
+	}
+	if _, code := get("cp_id=127.0.0.1:CP-2"); code != http.StatusNotFound {
+		t.Errorf("GET /configuration = %d for an unknown charger, want 404", code)
+	}
+}
@@ -0,0 +1,107 @@
+package ocpp
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/services"
+)
+
+const (
+	liveEventsChannel = "events:live"
+	// streamPing keeps proxies from closing an idle stream.
+	streamPing = 15 * time.Second
+)
+
+// handleEventStream follows the live feed as Server-Sent Events. The token
+// comes as "Authorization: Bearer <token>" or, for browsers' EventSource
+// that cannot set headers, as ?token=. cp_id, event and domain filter the
+// feed and take comma-separated values; Last-Event-ID (or ?last_event_id=)
+// resumes after that event.
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		writeJson(w, domain.ErrorResponse{Detail: "Invalid Method " + r.Method}, http.StatusMethodNotAllowed)
+		return
+	}
+	if s.feed == nil {
+		writeJson(w, domain.ErrorResponse{Detail: "Event stream disabled"}, http.StatusNotFound)
+		return
+	}
+	if !s.streamAuthorized(r) {
+		writeJson(w, domain.ErrorResponse{Detail: "Unauthorized"}, http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJson(w, domain.ErrorResponse{Detail: "Streaming unsupported"}, http.StatusInternalServerError)
+		return
+	}
+	query := r.URL.Query()
+	filter := domain.EventFilter{
+		CpIDs:   splitList(query.Get("cp_id")),
+		Domains: splitList(query.Get("domain")),
+	}
+	for _, event := range splitList(query.Get("event")) {
+		filter.Events = append(filter.Events, domain.EventTypes(event))
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+
+	backlog, events, cancel := s.feed.Subscribe(filter, lastEventID)
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range backlog {
+		writeStreamEvent(w, event)
+	}
+	flusher.Flush()
+
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			writeStreamEvent(w, event)
+		}
+		flusher.Flush()
+	}
+}
+
+func (s *Server) streamAuthorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.settings().LiveEventsToken)) == 1
+}
+
+func writeStreamEvent(w http.ResponseWriter, event services.FeedEvent) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Event.ID, event.Event.Event, event.Payload)
+}
+
+// splitList splits a comma-separated query value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
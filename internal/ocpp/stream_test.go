@@ -0,0 +1,80 @@
+package ocpp
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/services"
+	"go.uber.org/zap"
+)
+
+func TestServer_HandleEventStream(t *testing.T) {
+	feed := services.NewFeed(nil, "", 10, zap.NewNop())
+	s := &Server{log: zap.NewNop(), cfg: &config.Config{LiveEventsToken: "secret"}, feed: feed}
+	s.current.Store(s.cfg)
+	server := httptest.NewServer(http.HandlerFunc(s.handleEventStream))
+	defer server.Close()
+
+	ctx := context.Background()
+	feed.Publish(ctx, &domain.Event{ID: "1", Event: domain.HealthEvent, CpID: "cp-1"})
+	feed.Publish(ctx, &domain.Event{ID: "2", Event: domain.StartTransactionEvent, CpID: "cp-1"})
+
+	res, err := http.Get(server.URL + "?token=wrong")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong token status = %d, want 401", res.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"?cp_id=cp-1&event=start_transaction,stop_transaction", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Last-Event-ID", "0")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, Content-Type = %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	feed.Publish(ctx, &domain.Event{ID: "3", Event: domain.StopTransactionEvent, CpID: "cp-2"})
+	feed.Publish(ctx, &domain.Event{ID: "4", Event: domain.StopTransactionEvent, CpID: "cp-1"})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "id: ") {
+				lines <- line
+			}
+		}
+	}()
+	// The backlog after Last-Event-ID, then the live event, both filtered.
+	for _, want := range []string{"id: 2", "id: 4"} {
+		select {
+		case line := <-lines:
+			if line != want {
+				t.Errorf("got %q, want %q", line, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %q", want)
+		}
+	}
+}
+
+func TestServer_HandleEventStream_Disabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	(&Server{}).handleEventStream(w, httptest.NewRequest(http.MethodGet, "/events/stream", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
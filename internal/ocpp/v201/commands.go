@@ -0,0 +1,85 @@
+package v201
+
+// Messages sent by the server to a charging station.
+
+type RequestStartTransactionRequest struct {
+	EvseId        int     `json:"evseId,omitempty"`
+	RemoteStartId int     `json:"remoteStartId"`
+	IdToken       IdToken `json:"idToken"`
+}
+
+func (*RequestStartTransactionRequest) Action() string { return "RequestStartTransaction" }
+
+type RequestStartTransactionResponse struct {
+	Status        string `json:"status"`
+	TransactionId string `json:"transactionId,omitempty"`
+}
+
+type RequestStopTransactionRequest struct {
+	TransactionId string `json:"transactionId"`
+}
+
+func (*RequestStopTransactionRequest) Action() string { return "RequestStopTransaction" }
+
+type RequestStopTransactionResponse struct {
+	Status string `json:"status"`
+}
+
+type GetVariableData struct {
+	AttributeType string    `json:"attributeType,omitempty"`
+	Component     Component `json:"component"`
+	Variable      Variable  `json:"variable"`
+}
+
+type GetVariablesRequest struct {
+	GetVariableData []GetVariableData `json:"getVariableData"`
+}
+
+func (*GetVariablesRequest) Action() string { return "GetVariables" }
+
+type GetVariableResult struct {
+	AttributeStatus string    `json:"attributeStatus"`
+	AttributeType   string    `json:"attributeType,omitempty"`
+	AttributeValue  string    `json:"attributeValue,omitempty"`
+	Component       Component `json:"component"`
+	Variable        Variable  `json:"variable"`
+}
+
+type GetVariablesResponse struct {
+	GetVariableResult []GetVariableResult `json:"getVariableResult"`
+}
+
+type SetVariableData struct {
+	AttributeType  string    `json:"attributeType,omitempty"`
+	AttributeValue string    `json:"attributeValue"`
+	Component      Component `json:"component"`
+	Variable       Variable  `json:"variable"`
+}
+
+type SetVariablesRequest struct {
+	SetVariableData []SetVariableData `json:"setVariableData"`
+}
+
+func (*SetVariablesRequest) Action() string { return "SetVariables" }
+
+type SetVariableResult struct {
+	AttributeType   string    `json:"attributeType,omitempty"`
+	AttributeStatus string    `json:"attributeStatus"`
+	Component       Component `json:"component"`
+	Variable        Variable  `json:"variable"`
+}
+
+type SetVariablesResponse struct {
+	SetVariableResult []SetVariableResult `json:"setVariableResult"`
+}
+
+type GetBaseReportRequest struct {
+	RequestId  int    `json:"requestId"`
+	ReportBase string `json:"reportBase"`
+}
+
+func (*GetBaseReportRequest) Action() string { return "GetBaseReport" }
+
+type GetBaseReportResponse struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,196 @@
+// Package v201 holds the OCPP 2.0.1 messages the server understands.
+package v201
+
+import (
+	"time"
+)
+
+// Request is a CALL payload sent by a charging station.
+type Request interface {
+	Action() string
+}
+
+// NewRequest returns an empty request for a charging station action, or nil
+// if the action is not handled.
+func NewRequest(action string) Request {
+	switch action {
+	case "Authorize":
+		return &AuthorizeRequest{}
+	case "BootNotification":
+		return &BootNotificationRequest{}
+	case "Heartbeat":
+		return &HeartbeatRequest{}
+	case "MeterValues":
+		return &MeterValuesRequest{}
+	case "NotifyReport":
+		return &NotifyReportRequest{}
+	case "StatusNotification":
+		return &StatusNotificationRequest{}
+	case "TransactionEvent":
+		return &TransactionEventRequest{}
+	}
+	return nil
+}
+
+type ChargingStation struct {
+	SerialNumber    string `json:"serialNumber,omitempty"`
+	Model           string `json:"model"`
+	VendorName      string `json:"vendorName"`
+	FirmwareVersion string `json:"firmwareVersion,omitempty"`
+}
+
+type IdToken struct {
+	IdToken string `json:"idToken"`
+	Type    string `json:"type"`
+}
+
+type IdTokenInfo struct {
+	Status string `json:"status"`
+}
+
+type EVSE struct {
+	Id          int `json:"id"`
+	ConnectorId int `json:"connectorId,omitempty"`
+}
+
+type Component struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance,omitempty"`
+	EVSE     *EVSE  `json:"evse,omitempty"`
+}
+
+type Variable struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance,omitempty"`
+}
+
+type UnitOfMeasure struct {
+	Unit       string `json:"unit,omitempty"`
+	Multiplier int    `json:"multiplier,omitempty"`
+}
+
+type SampledValue struct {
+	Value         float64        `json:"value"`
+	Context       string         `json:"context,omitempty"`
+	Measurand     string         `json:"measurand,omitempty"`
+	Phase         string         `json:"phase,omitempty"`
+	Location      string         `json:"location,omitempty"`
+	UnitOfMeasure *UnitOfMeasure `json:"unitOfMeasure,omitempty"`
+}
+
+type MeterValue struct {
+	Timestamp    time.Time      `json:"timestamp"`
+	SampledValue []SampledValue `json:"sampledValue"`
+}
+
+type AuthorizeRequest struct {
+	IdToken IdToken `json:"idToken"`
+}
+
+func (*AuthorizeRequest) Action() string { return "Authorize" }
+
+type AuthorizeResponse struct {
+	IdTokenInfo IdTokenInfo `json:"idTokenInfo"`
+}
+
+type BootNotificationRequest struct {
+	ChargingStation ChargingStation `json:"chargingStation"`
+	Reason          string          `json:"reason"`
+}
+
+func (*BootNotificationRequest) Action() string { return "BootNotification" }
+
+type BootNotificationResponse struct {
+	CurrentTime time.Time `json:"currentTime"`
+	Interval    int       `json:"interval"`
+	Status      string    `json:"status"`
+}
+
+type HeartbeatRequest struct{}
+
+func (*HeartbeatRequest) Action() string { return "Heartbeat" }
+
+type HeartbeatResponse struct {
+	CurrentTime time.Time `json:"currentTime"`
+}
+
+type MeterValuesRequest struct {
+	EvseId     int          `json:"evseId"`
+	MeterValue []MeterValue `json:"meterValue"`
+}
+
+func (*MeterValuesRequest) Action() string { return "MeterValues" }
+
+type MeterValuesResponse struct{}
+
+type VariableAttribute struct {
+	Type       string `json:"type,omitempty"`
+	Value      string `json:"value,omitempty"`
+	Mutability string `json:"mutability,omitempty"`
+	Persistent bool   `json:"persistent,omitempty"`
+	Constant   bool   `json:"constant,omitempty"`
+}
+
+type ReportData struct {
+	Component         Component           `json:"component"`
+	Variable          Variable            `json:"variable"`
+	VariableAttribute []VariableAttribute `json:"variableAttribute"`
+}
+
+type NotifyReportRequest struct {
+	RequestId   int          `json:"requestId"`
+	GeneratedAt time.Time    `json:"generatedAt"`
+	Tbc         bool         `json:"tbc,omitempty"`
+	SeqNo       int          `json:"seqNo"`
+	ReportData  []ReportData `json:"reportData,omitempty"`
+}
+
+func (*NotifyReportRequest) Action() string { return "NotifyReport" }
+
+type NotifyReportResponse struct{}
+
+type StatusNotificationRequest struct {
+	Timestamp       time.Time `json:"timestamp"`
+	ConnectorStatus string    `json:"connectorStatus"`
+	EvseId          int       `json:"evseId"`
+	ConnectorId     int       `json:"connectorId"`
+}
+
+func (*StatusNotificationRequest) Action() string { return "StatusNotification" }
+
+type StatusNotificationResponse struct{}
+
+type Transaction struct {
+	TransactionId     string `json:"transactionId"`
+	ChargingState     string `json:"chargingState,omitempty"`
+	TimeSpentCharging int    `json:"timeSpentCharging,omitempty"`
+	StoppedReason     string `json:"stoppedReason,omitempty"`
+	RemoteStartId     int    `json:"remoteStartId,omitempty"`
+}
+
+const (
+	TransactionStarted = "Started"
+	TransactionUpdated = "Updated"
+	TransactionEnded   = "Ended"
+)
+
+type TransactionEventRequest struct {
+	EventType          string       `json:"eventType"`
+	Timestamp          time.Time    `json:"timestamp"`
+	TriggerReason      string       `json:"triggerReason"`
+	SeqNo              int          `json:"seqNo"`
+	Offline            bool         `json:"offline,omitempty"`
+	NumberOfPhasesUsed int          `json:"numberOfPhasesUsed,omitempty"`
+	CableMaxCurrent    int          `json:"cableMaxCurrent,omitempty"`
+	ReservationId      int          `json:"reservationId,omitempty"`
+	TransactionInfo    Transaction  `json:"transactionInfo"`
+	IdToken            *IdToken     `json:"idToken,omitempty"`
+	EVSE               *EVSE        `json:"evse,omitempty"`
+	MeterValue         []MeterValue `json:"meterValue,omitempty"`
+}
+
+func (*TransactionEventRequest) Action() string { return "TransactionEvent" }
+
+type TransactionEventResponse struct {
+	IdTokenInfo *IdTokenInfo `json:"idTokenInfo,omitempty"`
+}
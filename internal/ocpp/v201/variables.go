@@ -0,0 +1,74 @@
+package v201
+
+import "strings"
+
+// legacyKeys maps OCPP 1.6 configuration keys onto their 2.0.1 device model
+// variables, so the same key works for both versions.
+var legacyKeys = map[string][2]string{
+	"HeartbeatInterval":                 {"OCPPCommCtrlr", "HeartbeatInterval"},
+	"WebSocketPingInterval":             {"OCPPCommCtrlr", "WebSocketPingInterval"},
+	"ResetRetries":                      {"OCPPCommCtrlr", "ResetRetries"},
+	"MeterValueSampleInterval":          {"SampledDataCtrlr", "TxUpdatedInterval"},
+	"MeterValuesSampledData":            {"SampledDataCtrlr", "TxUpdatedMeasurands"},
+	"StopTxnSampledData":                {"SampledDataCtrlr", "TxEndedMeasurands"},
+	"ClockAlignedDataInterval":          {"AlignedDataCtrlr", "Interval"},
+	"MeterValuesAlignedData":            {"AlignedDataCtrlr", "Measurands"},
+	"ConnectionTimeOut":                 {"TxCtrlr", "EVConnectionTimeOut"},
+	"StopTransactionOnEVSideDisconnect": {"TxCtrlr", "StopTxOnEVSideDisconnect"},
+	"LocalAuthorizeOffline":             {"AuthCtrlr", "LocalAuthorizeOffline"},
+	"LocalPreAuthorize":                 {"AuthCtrlr", "LocalPreAuthorize"},
+	"AuthorizeRemoteTxRequests":         {"AuthCtrlr", "AuthorizeRemoteStart"},
+	"LocalAuthListEnabled":              {"LocalAuthListCtrlr", "Enabled"},
+	"TransactionMessageAttempts":        {"OCPPCommCtrlr", "MessageAttempts"},
+	"TransactionMessageRetryInterval":   {"OCPPCommCtrlr", "MessageAttemptInterval"},
+}
+
+// VariableKey names a device model variable as "Component[instance].Variable[instance]".
+func VariableKey(component Component, variable Variable) string {
+	key := component.Name
+	if component.Instance != "" {
+		key += "[" + component.Instance + "]"
+	}
+	key += "." + variable.Name
+	if variable.Instance != "" {
+		key += "[" + variable.Instance + "]"
+	}
+	return key
+}
+
+// ParseVariableKey is the inverse of VariableKey. Plain 1.6 key names are
+// translated through the legacy key table.
+func ParseVariableKey(key string) (Component, Variable, bool) {
+	if names, ok := legacyKeys[key]; ok {
+		return Component{Name: names[0]}, Variable{Name: names[1]}, true
+	}
+	depth := 0
+	for i, r := range key {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth != 0 {
+				continue
+			}
+			name, instance := splitInstance(key[:i])
+			component := Component{Name: name, Instance: instance}
+			name, instance = splitInstance(key[i+1:])
+			if component.Name == "" || name == "" {
+				return Component{}, Variable{}, false
+			}
+			return component, Variable{Name: name, Instance: instance}, true
+		}
+	}
+	return Component{}, Variable{}, false
+}
+
+func splitInstance(s string) (string, string) {
+	open := strings.IndexByte(s, '[')
+	if open < 0 || !strings.HasSuffix(s, "]") {
+		return s, ""
+	}
+	return s[:open], s[open+1 : len(s)-1]
+}
@@ -0,0 +1,33 @@
+package v201
+
+import "testing"
+
+func TestVariableKey(t *testing.T) {
+	got := VariableKey(Component{Name: "EVSE", Instance: "1"}, Variable{Name: "Power"})
+	if got != "EVSE[1].Power" {
+		t.Errorf("VariableKey() = %v, want EVSE[1].Power", got)
+	}
+}
+
+func TestParseVariableKey(t *testing.T) {
+	tests := []struct {
+		key           string
+		wantComponent Component
+		wantVariable  Variable
+		wantOK        bool
+	}{
+		{"OCPPCommCtrlr.HeartbeatInterval", Component{Name: "OCPPCommCtrlr"}, Variable{Name: "HeartbeatInterval"}, true},
+		{"HeartbeatInterval", Component{Name: "OCPPCommCtrlr"}, Variable{Name: "HeartbeatInterval"}, true},
+		{"EVSE[1].Power[max.total]", Component{Name: "EVSE", Instance: "1"}, Variable{Name: "Power", Instance: "max.total"}, true},
+		{"Unknown", Component{}, Variable{}, false},
+		{".Power", Component{}, Variable{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			component, variable, ok := ParseVariableKey(tt.key)
+			if ok != tt.wantOK || component.Name != tt.wantComponent.Name || component.Instance != tt.wantComponent.Instance || variable != tt.wantVariable {
+				t.Errorf("ParseVariableKey() = %+v, %+v, %v", component, variable, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package ocpp
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const watchdogTick = 5 * time.Second
+
+// Watchdog reports chargers that stay connected but stop talking, which is
+// what a half-open TCP connection looks like from our side.
+type Watchdog struct {
+	csys     *CentralSystem
+	interval time.Duration
+	grace    time.Duration
+
+	// Both are kept per connection, so a replaced one cannot touch the state
+	// of its successor.
+	mux        sync.Mutex
+	offline    map[*Conn]bool
+	connectors map[*Conn]map[int]struct{}
+
+	onOffline func(conn *Conn, connectors []int)
+	onOnline  func(conn *Conn)
+}
+
+// NewWatchdog uses interval for chargers that have not sent a
+// BootNotification on their current connection.
+func NewWatchdog(csys *CentralSystem, interval, grace time.Duration) *Watchdog {
+	return &Watchdog{
+		csys:       csys,
+		interval:   interval,
+		grace:      grace,
+		offline:    make(map[*Conn]bool),
+		connectors: make(map[*Conn]map[int]struct{}),
+		onOffline:  func(*Conn, []int) {},
+		onOnline:   func(*Conn) {},
+	}
+}
+
+// SetTiming changes the default heartbeat interval and the grace period.
+func (w *Watchdog) SetTiming(interval, grace time.Duration) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.interval = interval
+	w.grace = grace
+}
+
+func (w *Watchdog) SetOfflineListener(f func(conn *Conn, connectors []int)) {
+	w.onOffline = f
+}
+
+func (w *Watchdog) SetOnlineListener(f func(conn *Conn)) {
+	w.onOnline = f
+}
+
+// Connector remembers a connector so it can be marked Unavailable later.
+func (w *Watchdog) Connector(conn *Conn, connector int) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.connectors[conn] == nil {
+		w.connectors[conn] = make(map[int]struct{})
+	}
+	w.connectors[conn][connector] = struct{}{}
+}
+
+// Forget drops what is known of a closed connection.
+func (w *Watchdog) Forget(conn *Conn) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	delete(w.offline, conn)
+	delete(w.connectors, conn)
+}
+
+// Seen is called for every message; it reports a charger that was offline
+// as online again.
+func (w *Watchdog) Seen(conn *Conn) {
+	w.mux.Lock()
+	wasOffline := w.offline[conn]
+	delete(w.offline, conn)
+	w.mux.Unlock()
+	if wasOffline {
+		w.onOnline(conn)
+	}
+}
+
+func (w *Watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(watchdogTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			w.check(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *Watchdog) check(now time.Time) {
+	w.mux.Lock()
+	defaultInterval, grace := w.interval, w.grace
+	w.mux.Unlock()
+	for _, conn := range w.csys.Conns() {
+		interval := conn.HeartbeatInterval()
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+		if now.Sub(conn.LastMessageAt()) <= interval+grace {
+			continue
+		}
+		w.mux.Lock()
+		if w.offline[conn] {
+			w.mux.Unlock()
+			continue
+		}
+		w.offline[conn] = true
+		connectors := make([]int, 0, len(w.connectors[conn]))
+		for connector := range w.connectors[conn] {
+			connectors = append(connectors, connector)
+		}
+		w.mux.Unlock()
+		sort.Ints(connectors)
+		w.onOffline(conn, connectors)
+	}
+}
@@ -0,0 +1,75 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestWatchdog(t *testing.T) {
+	csys := NewCentralSystem(zap.NewNop(), func(context.Context, *Conn, string, json.RawMessage) (any, error) { return struct{}{}, nil })
+	server := httptest.NewServer(csys)
+	defer server.Close()
+	dialCharger(t, server.URL, "CP-1")
+	conn := waitConn(t, csys, "127.0.0.1:CP-1")
+
+	watchdog := NewWatchdog(csys, time.Minute, 30*time.Second)
+	var offline []int
+	offlineCalls, onlineCalls := 0, 0
+	watchdog.SetOfflineListener(func(conn *Conn, connectors []int) {
+		offlineCalls++
+		offline = connectors
+	})
+	watchdog.SetOnlineListener(func(conn *Conn) { onlineCalls++ })
+	watchdog.Connector(conn, 2)
+	watchdog.Connector(conn, 1)
+
+	last := conn.LastMessageAt()
+	watchdog.check(last.Add(80 * time.Second))
+	if offlineCalls != 0 {
+		t.Fatal("charger reported offline inside the grace period")
+	}
+
+	watchdog.check(last.Add(100 * time.Second))
+	watchdog.check(last.Add(200 * time.Second))
+	if offlineCalls != 1 {
+		t.Fatalf("offline reported %d times, want 1", offlineCalls)
+	}
+	if len(offline) != 2 || offline[0] != 1 || offline[1] != 2 {
+		t.Errorf("connectors = %v, want [1 2]", offline)
+	}
+
+	watchdog.Seen(conn)
+	watchdog.Seen(conn)
+	if onlineCalls != 1 {
+		t.Errorf("online reported %d times, want 1", onlineCalls)
+	}
+
+	watchdog.check(last.Add(300 * time.Second))
+	watchdog.Forget(conn)
+	if len(watchdog.offline) != 0 || len(watchdog.connectors) != 0 {
+		t.Errorf("Forget() left offline = %v, connectors = %v", watchdog.offline, watchdog.connectors)
+	}
+}
+
+func TestWatchdog_BootInterval(t *testing.T) {
+	csys := NewCentralSystem(zap.NewNop(), func(context.Context, *Conn, string, json.RawMessage) (any, error) { return struct{}{}, nil })
+	server := httptest.NewServer(csys)
+	defer server.Close()
+	dialCharger(t, server.URL, "CP-1")
+	conn := waitConn(t, csys, "127.0.0.1:CP-1")
+	conn.SetHeartbeatInterval(10 * time.Minute)
+
+	watchdog := NewWatchdog(csys, time.Minute, 30*time.Second)
+	offlineCalls := 0
+	watchdog.SetOfflineListener(func(*Conn, []int) { offlineCalls++ })
+
+	watchdog.check(conn.LastMessageAt().Add(5 * time.Minute))
+	if offlineCalls != 0 {
+		t.Error("charger reported offline before its own heartbeat interval")
+	}
+}
@@ -0,0 +1,258 @@
+// Package replay plays a captured message journal back against a central
+// system, acting as the charger, and compares the answers with the recorded
+// ones.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"github.com/gorilla/websocket"
+)
+
+type Options struct {
+	// URL is the central system, e.g. ws://localhost:10800.
+	URL string
+	// ID is the charger path to connect as; defaults to the journal's.
+	ID string
+	// Protocol is the subprotocol to offer; defaults to the journal's version.
+	Protocol string
+	// Speed scales the recorded gaps between CALLs: 1 keeps the original
+	// pacing, 0 sends each CALL as soon as the previous one is answered.
+	Speed float64
+	// Timeout bounds the wait for each answer.
+	Timeout time.Duration
+}
+
+type Result struct {
+	Sent       int
+	Matched    int
+	Mismatched int
+	Failed     int
+}
+
+// ChargerPath returns the URL path part of a cp_id ("host:path").
+func ChargerPath(cpID string) string {
+	if i := strings.LastIndex(cpID, ":"); i >= 0 {
+		return cpID[i+1:]
+	}
+	return cpID
+}
+
+type session struct {
+	socket  *websocket.Conn
+	out     io.Writer
+	writeMu sync.Mutex
+
+	mux     sync.Mutex
+	pending map[string]chan *ocpp.Frame
+	// answers are the charger's recorded answers to central system CALLs,
+	// queued per action.
+	answers map[string][]*ocpp.Frame
+}
+
+func Replay(ctx context.Context, entries []domain.JournalEntry, opts Options, out io.Writer) (*Result, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("journal is empty")
+	}
+	if opts.ID == "" {
+		opts.ID = ChargerPath(entries[0].CpID)
+	}
+	if opts.Protocol == "" {
+		opts.Protocol = entries[0].Version
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	dialer := websocket.Dialer{Subprotocols: []string{opts.Protocol}}
+	socket, _, err := dialer.DialContext(ctx, strings.TrimRight(opts.URL, "/")+"/"+opts.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer socket.Close()
+	fmt.Fprintf(out, "connected as %s (%s)\n", opts.ID, socket.Subprotocol())
+
+	s := &session{
+		socket:  socket,
+		out:     out,
+		pending: make(map[string]chan *ocpp.Frame),
+		answers: recordedAnswers(entries),
+	}
+	go s.read()
+
+	recorded := make(map[string]*ocpp.Frame)
+	for _, entry := range entries {
+		if entry.Direction == domain.DirectionOut && (entry.Type == "CALLRESULT" || entry.Type == "CALLERROR") {
+			if frame, err := ocpp.ParseFrame([]byte(entry.Raw)); err == nil {
+				recorded[frame.ID] = frame
+			}
+		}
+	}
+
+	result := &Result{}
+	var last time.Time
+	for _, entry := range entries {
+		if entry.Direction != domain.DirectionIn || (entry.Type != "CALL" && entry.Type != "INVALID") {
+			continue
+		}
+		if opts.Speed > 0 && !last.IsZero() {
+			select {
+			case <-time.After(time.Duration(float64(entry.Time.Sub(last)) / opts.Speed)):
+			case <-ctx.Done():
+				return result, ctx.Err()
+			}
+		}
+		last = entry.Time
+
+		if entry.Type == "INVALID" {
+			fmt.Fprintf(out, "-> invalid frame %s\n", entry.Raw)
+			if err := s.write([]byte(entry.Raw)); err != nil {
+				return result, err
+			}
+			result.Sent++
+			continue
+		}
+
+		answer := make(chan *ocpp.Frame, 1)
+		s.mux.Lock()
+		s.pending[entry.MessageID] = answer
+		s.mux.Unlock()
+		fmt.Fprintf(out, "-> %s %s\n", entry.Action, entry.MessageID)
+		if err := s.write([]byte(entry.Raw)); err != nil {
+			return result, err
+		}
+		result.Sent++
+
+		select {
+		case got := <-answer:
+			want, ok := recorded[entry.MessageID]
+			switch {
+			case !ok:
+				fmt.Fprintf(out, "   got      %s (nothing recorded)\n", frameString(got))
+				result.Matched++
+			case sameAnswer(want, got):
+				fmt.Fprintf(out, "   ok       %s\n", frameString(got))
+				result.Matched++
+			default:
+				fmt.Fprintf(out, "   DIFFERS  recorded %s\n            got      %s\n", frameString(want), frameString(got))
+				result.Mismatched++
+			}
+		case <-time.After(opts.Timeout):
+			fmt.Fprintf(out, "   FAILED   no answer within %s\n", opts.Timeout)
+			result.Failed++
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+	fmt.Fprintf(out, "sent %d, matched %d, differs %d, failed %d\n", result.Sent, result.Matched, result.Mismatched, result.Failed)
+	return result, nil
+}
+
+// recordedAnswers pairs each CALL the central system sent with the charger's
+// answer to it.
+func recordedAnswers(entries []domain.JournalEntry) map[string][]*ocpp.Frame {
+	actions := make(map[string]string)
+	answers := make(map[string][]*ocpp.Frame)
+	for _, entry := range entries {
+		frame, err := ocpp.ParseFrame([]byte(entry.Raw))
+		if err != nil {
+			continue
+		}
+		switch {
+		case entry.Direction == domain.DirectionOut && frame.Type == ocpp.Call:
+			actions[frame.ID] = frame.Action
+		case entry.Direction == domain.DirectionIn && frame.Type != ocpp.Call:
+			if action, ok := actions[frame.ID]; ok {
+				answers[action] = append(answers[action], frame)
+			}
+		}
+	}
+	return answers
+}
+
+func (s *session) write(data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.socket.WriteMessage(websocket.TextMessage, data)
+}
+
+func (s *session) read() {
+	for {
+		_, data, err := s.socket.ReadMessage()
+		if err != nil {
+			return
+		}
+		frame, err := ocpp.ParseFrame(data)
+		if err != nil {
+			fmt.Fprintf(s.out, "<- invalid frame %s\n", data)
+			continue
+		}
+		if frame.Type == ocpp.Call {
+			s.answer(frame)
+			continue
+		}
+		s.mux.Lock()
+		answer, ok := s.pending[frame.ID]
+		delete(s.pending, frame.ID)
+		s.mux.Unlock()
+		if ok {
+			answer <- frame
+		} else {
+			fmt.Fprintf(s.out, "<- unexpected %s\n", data)
+		}
+	}
+}
+
+// answer replies to a central system CALL with the next recorded answer for
+// its action, or NotImplemented when the journal has none.
+func (s *session) answer(call *ocpp.Frame) {
+	s.mux.Lock()
+	queue := s.answers[call.Action]
+	var reply *ocpp.Frame
+	if len(queue) > 0 {
+		reply, s.answers[call.Action] = queue[0], queue[1:]
+	}
+	s.mux.Unlock()
+	if reply == nil {
+		reply = &ocpp.Frame{Type: ocpp.CallError, ErrorCode: ocpp.NotImplemented, ErrorDescription: "not in journal"}
+	}
+	answer := *reply
+	answer.ID = call.ID
+	data, err := json.Marshal(&answer)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "<- %s %s, answered %s\n", call.Action, call.ID, frameString(&answer))
+	s.write(data)
+}
+
+func sameAnswer(want, got *ocpp.Frame) bool {
+	if want.Type != got.Type {
+		return false
+	}
+	if want.Type == ocpp.CallError {
+		return want.ErrorCode == got.ErrorCode
+	}
+	var a, b any
+	if json.Unmarshal(want.Payload, &a) != nil || json.Unmarshal(got.Payload, &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func frameString(frame *ocpp.Frame) string {
+	if frame.Type == ocpp.CallError {
+		return fmt.Sprintf("CALLERROR %s %q", frame.ErrorCode, frame.ErrorDescription)
+	}
+	return "CALLRESULT " + string(frame.Payload)
+}
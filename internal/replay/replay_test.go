@@ -0,0 +1,71 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"go.uber.org/zap"
+)
+
+func TestChargerPath(t *testing.T) {
+	for cpID, want := range map[string]string{"example.com:CP-1": "CP-1", "::1:CP-1": "CP-1", "CP-1": "CP-1"} {
+		if got := ChargerPath(cpID); got != want {
+			t.Errorf("ChargerPath(%q) = %q, want %q", cpID, got, want)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	reset := make(chan string, 1)
+	csys := ocpp.NewCentralSystem(zap.NewNop(), func(ctx context.Context, conn *ocpp.Conn, action string, payload json.RawMessage) (any, error) {
+		switch action {
+		case "Heartbeat":
+			return map[string]string{"currentTime": "2024-01-01T00:00:00Z"}, nil
+		case "Authorize":
+			// The server now rejects the tag and asks for a reset meanwhile.
+			result, err := conn.Call(ctx, "Reset", map[string]string{"type": "Soft"})
+			if err != nil {
+				reset <- err.Error()
+			} else {
+				reset <- string(result)
+			}
+			return map[string]any{"idTagInfo": map[string]string{"status": "Blocked"}}, nil
+		}
+		return nil, &ocpp.CallErr{Code: ocpp.NotImplemented}
+	})
+	server := httptest.NewServer(csys)
+	defer server.Close()
+
+	start := time.Now()
+	entries := []domain.JournalEntry{
+		{Time: start, CpID: "example.com:CP-1", Version: "ocpp1.6", Direction: "in", Type: "CALL", MessageID: "1", Action: "Heartbeat", Raw: `[2,"1","Heartbeat",{}]`},
+		{Time: start, CpID: "example.com:CP-1", Version: "ocpp1.6", Direction: "out", Type: "CALLRESULT", MessageID: "1", Raw: `[3,"1",{"currentTime":"2024-01-01T00:00:00Z"}]`},
+		{Time: start, CpID: "example.com:CP-1", Version: "ocpp1.6", Direction: "out", Type: "CALL", MessageID: "r", Action: "Reset", Raw: `[2,"r","Reset",{"type":"Hard"}]`},
+		{Time: start, CpID: "example.com:CP-1", Version: "ocpp1.6", Direction: "in", Type: "CALLRESULT", MessageID: "r", Raw: `[3,"r",{"status":"Accepted"}]`},
+		{Time: start, CpID: "example.com:CP-1", Version: "ocpp1.6", Direction: "in", Type: "CALL", MessageID: "2", Action: "Authorize", Raw: `[2,"2","Authorize",{"idTag":"RFID"}]`},
+		{Time: start, CpID: "example.com:CP-1", Version: "ocpp1.6", Direction: "out", Type: "CALLRESULT", MessageID: "2", Raw: `[3,"2",{"idTagInfo":{"status":"Accepted"}}]`},
+	}
+
+	var out bytes.Buffer
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	result, err := Replay(context.Background(), entries, Options{URL: url, Timeout: 2 * time.Second}, &out)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if result.Sent != 2 || result.Matched != 1 || result.Mismatched != 1 || result.Failed != 0 {
+		t.Errorf("Replay() = %+v, output:\n%s", result, out.String())
+	}
+	if got := <-reset; got != `{"status":"Accepted"}` {
+		t.Errorf("Reset answer = %s, want the recorded one", got)
+	}
+	if !strings.Contains(out.String(), "connected as CP-1 (ocpp1.6)") {
+		t.Errorf("did not connect as CP-1:\n%s", out.String())
+	}
+}
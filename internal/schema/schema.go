@@ -0,0 +1,241 @@
+// Package schema generates JSON Schemas from the domain types and validates
+// events and command bodies against them.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/invopop/jsonschema"
+	validator "github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// eventData is the data type of each event.
+var eventData = map[domain.EventTypes]any{
+	domain.ChangeConnectorStatusEvent: domain.ChangeConnectorStatus{},
+	domain.StartTransactionEvent:      domain.StartTransaction{},
+	domain.StopTransactionEvent:       domain.StopTransaction{},
+	domain.MeterValuesEvent:           domain.MeterValues{},
+	domain.HealthEvent:                domain.Healthcheck{},
+	domain.DataTransferEvent:          domain.DataTransfer{},
+	domain.DisconnectChargerEvent:     domain.DisconnectCharger{},
+	domain.ConnectChargerEvent:        domain.ConnectCharger{},
+	domain.ConfigurationReportEvent:   domain.ConfigurationReport{},
+	domain.OfflineEvent:               domain.ChargerOffline{},
+	domain.OnlineEvent:                domain.ChargerOnline{},
+}
+
+// commandData is the data type of each command.
+var commandData = map[domain.RemoteCommand]any{
+	domain.RemoteStartTransaction: domain.RemoteStartTransactionReq{},
+	domain.RemoteStopTransaction:  domain.RemoteStopTransactionReq{},
+	domain.GetConfiguration:       domain.GetConfigurationReq{},
+	domain.ChangeConfiguration:    domain.ChangeConfigurationReq{},
+}
+
+// EventName and CommandName are the registry names of the schema of an
+// event and of a /command/ body.
+func EventName(event domain.EventTypes) string {
+	return "events/" + string(event)
+}
+
+func CommandName(command domain.RemoteCommand) string {
+	return "commands/" + string(command)
+}
+
+// Registry holds the schema of every event and command.
+type Registry struct {
+	documents map[string]json.RawMessage
+	compiled  map[string]*validator.Schema
+}
+
+// New generates the schemas. Events list every field the server fills in as
+// required; commands only the fields tagged `jsonschema:"required"`.
+func New() (*Registry, error) {
+	schemas := make(map[string]*jsonschema.Schema)
+
+	events := &jsonschema.Reflector{Anonymous: true, DoNotReference: true, ExpandedStruct: true}
+	for event, data := range eventData {
+		s := events.Reflect(&domain.Event{})
+		s.Title = string(event) + " event"
+		s.Properties.Set("event", &jsonschema.Schema{Type: "string", Const: string(event)})
+		s.Properties.Set("data", body(events, data))
+		schemas[EventName(event)] = s
+	}
+
+	commands := &jsonschema.Reflector{
+		Anonymous:                  true,
+		DoNotReference:             true,
+		ExpandedStruct:             true,
+		AllowAdditionalProperties:  true,
+		RequiredFromJSONSchemaTags: true,
+	}
+	for command, data := range commandData {
+		s := commands.Reflect(&domain.RemoteCommandReq{})
+		s.Title = string(command) + " command"
+		s.Properties.Set("command", &jsonschema.Schema{Type: "string", Const: string(command)})
+		s.Properties.Set("data", body(commands, data))
+		schemas[CommandName(command)] = s
+	}
+
+	r := &Registry{
+		documents: make(map[string]json.RawMessage, len(schemas)),
+		compiled:  make(map[string]*validator.Schema, len(schemas)),
+	}
+	compiler := validator.NewCompiler()
+	compiler.AssertFormat()
+	for name, s := range schemas {
+		document, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := validator.UnmarshalJSON(bytes.NewReader(document))
+		if err != nil {
+			return nil, err
+		}
+		if err := compiler.AddResource(resourceURL(name), parsed); err != nil {
+			return nil, err
+		}
+		r.documents[name] = document
+	}
+	for name := range schemas {
+		compiled, err := compiler.Compile(resourceURL(name))
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+		r.compiled[name] = compiled
+	}
+	return r, nil
+}
+
+// body is the schema of a data struct, without the meta-schema line.
+func body(reflector *jsonschema.Reflector, data any) *jsonschema.Schema {
+	s := reflector.ReflectFromType(reflect.TypeOf(data))
+	s.Version = ""
+	return s
+}
+
+func resourceURL(name string) string {
+	return "urn:ocpp:" + name
+}
+
+// Names lists the schemas, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.documents))
+	for name := range r.documents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Document is the schema as served over HTTP.
+func (r *Registry) Document(name string) (json.RawMessage, bool) {
+	document, ok := r.documents[name]
+	return document, ok
+}
+
+// Validate checks a JSON document against a schema and lists what is wrong
+// with it; nil means it is valid.
+func (r *Registry) Validate(name string, document []byte) []domain.FieldError {
+	compiled, ok := r.compiled[name]
+	if !ok {
+		return []domain.FieldError{{Message: "unknown schema " + name}}
+	}
+	value, err := validator.UnmarshalJSON(bytes.NewReader(document))
+	if err != nil {
+		return []domain.FieldError{{Message: "invalid JSON: " + err.Error()}}
+	}
+	err = compiled.Validate(value)
+	if err == nil {
+		return nil
+	}
+	validationErr, ok := err.(*validator.ValidationError)
+	if !ok {
+		return []domain.FieldError{{Message: err.Error()}}
+	}
+	var errs []domain.FieldError
+	collect(validationErr, &errs)
+	return errs
+}
+
+// ValidateEvent checks an outgoing event against the schema of its type.
+func (r *Registry) ValidateEvent(event *domain.Event) error {
+	document, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if errs := r.Validate(EventName(event.Event), document); errs != nil {
+		return &Error{Errors: errs}
+	}
+	return nil
+}
+
+// ValidateCommand checks a /command/ body: first the command name, then the
+// whole body against the schema of that command.
+func (r *Registry) ValidateCommand(document []byte) []domain.FieldError {
+	var req struct {
+		Command domain.RemoteCommand `json:"command"`
+	}
+	if err := json.Unmarshal(document, &req); err != nil {
+		return []domain.FieldError{{Message: "invalid JSON: " + err.Error()}}
+	}
+	if !slices.Contains(domain.RemoteCommands, req.Command) {
+		names := make([]string, len(domain.RemoteCommands))
+		for i, command := range domain.RemoteCommands {
+			names[i] = string(command)
+		}
+		return []domain.FieldError{{Field: "command", Message: "must be one of " + strings.Join(names, ", ")}}
+	}
+	return r.Validate(CommandName(req.Command), document)
+}
+
+// Error is an event that does not match its schema.
+type Error struct {
+	Errors []domain.FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Field + ": " + err.Message
+	}
+	return "schema violation: " + strings.Join(messages, "; ")
+}
+
+var printer = message.NewPrinter(language.English)
+
+// collect flattens the error tree into one entry per field. A missing
+// property is reported on the property itself.
+func collect(err *validator.ValidationError, errs *[]domain.FieldError) {
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			collect(cause, errs)
+		}
+		return
+	}
+	field := strings.Join(err.InstanceLocation, ".")
+	if required, ok := err.ErrorKind.(*kind.Required); ok {
+		for _, missing := range required.Missing {
+			*errs = append(*errs, domain.FieldError{Field: join(field, missing), Message: "required"})
+		}
+		return
+	}
+	*errs = append(*errs, domain.FieldError{Field: field, Message: err.ErrorKind.LocalizedString(printer)})
+}
+
+func join(parent, field string) string {
+	if parent == "" {
+		return field
+	}
+	return parent + "." + field
+}
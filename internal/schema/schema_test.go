@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+func TestNew_CoversEveryType(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, event := range domain.EventTypesAll {
+		if _, ok := r.Document(EventName(event)); !ok {
+			t.Errorf("no schema for event %s", event)
+		}
+	}
+	for _, command := range domain.RemoteCommands {
+		if _, ok := r.Document(CommandName(command)); !ok {
+			t.Errorf("no schema for command %s", command)
+		}
+	}
+}
+
+func TestValidateEvent(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	now := time.Now().UTC()
+	event := func(data any) *domain.Event {
+		return &domain.Event{
+			ID:            "0190c4d8-0000-7000-8000-000000000000",
+			Event:         domain.StartTransactionEvent,
+			Domain:        "example.com",
+			CpID:          "cp-1",
+			OccurredAt:    now,
+			ReceivedAt:    now,
+			SchemaVersion: domain.EventSchemaVersion,
+			Data:          data,
+		}
+	}
+
+	if err := r.ValidateEvent(event(domain.StartTransaction{Charger: "cp-1", Conn: 1, Tag: "TAG"})); err != nil {
+		t.Errorf("ValidateEvent() error = %v", err)
+	}
+	err = r.ValidateEvent(event(domain.StopTransaction{Charger: "cp-1"}))
+	schemaErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("ValidateEvent() error = %v, want *Error", err)
+	}
+	fields := map[string]bool{}
+	for _, e := range schemaErr.Errors {
+		fields[e.Field] = true
+	}
+	for _, want := range []string{"data.conn", "data.tag", "data.meter_start"} {
+		if !fields[want] {
+			t.Errorf("errors = %+v, want %s", schemaErr.Errors, want)
+		}
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		body string
+		want []domain.FieldError
+	}{
+		{
+			name: "valid",
+			body: `{"cp_id":"cp-1","command":"remote_start_transaction","data":{"tag":"TAG","connector_id":1}}`,
+		},
+		{
+			name: "unknown command",
+			body: `{"cp_id":"cp-1","command":"reboot","data":{}}`,
+			want: []domain.FieldError{{Field: "command", Message: "must be one of remote_start_transaction, remote_stop_transaction, get_configuration, change_configuration"}},
+		},
+		{
+			name: "missing fields",
+			body: `{"command":"remote_stop_transaction","data":{}}`,
+			want: []domain.FieldError{{Field: "cp_id", Message: "required"}, {Field: "data.transaction_id", Message: "required"}},
+		},
+		{
+			name: "wrong type",
+			body: `{"cp_id":"cp-1","command":"change_configuration","data":{"key":"HeartbeatInterval","value":60}}`,
+			want: []domain.FieldError{{Field: "data.value", Message: "got number, want string"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.ValidateCommand([]byte(tt.body))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateCommand() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocument(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	document, _ := r.Document(EventName(domain.MeterValuesEvent))
+	var s struct {
+		Properties struct {
+			Event struct {
+				Const string `json:"const"`
+			} `json:"event"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(document, &s); err != nil || s.Properties.Event.Const != string(domain.MeterValuesEvent) {
+		t.Errorf("Document() = %s, %v", document, err)
+	}
+}
+
+func TestValidate_MeterValue(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	now := time.Now().UTC()
+	event := domain.Event{
+		ID:            "0190c4d8-0000-7000-8000-000000000000",
+		Event:         domain.MeterValuesEvent,
+		Domain:        "example.com",
+		CpID:          "example.com:cp-1",
+		OccurredAt:    now,
+		ReceivedAt:    now,
+		SchemaVersion: domain.EventSchemaVersion,
+		Data: domain.MeterValues{Conn: 1, TransactionId: 7, MeterValue: []domain.MeterValue{{
+			SampledValue: []domain.SampledValue{{Measurand: "Energy.Active.Import.Register", Unit: "Wh", Value: "1000"}},
+			Timestamp:    now,
+		}}},
+	}
+	if err := r.ValidateEvent(&event); err != nil {
+		t.Errorf("ValidateEvent() error = %v", err)
+	}
+	document := []byte(`{"id":"0190c4d8-0000-7000-8000-000000000000","event":"meter_value","domain":"example.com",` +
+		`"cp_id":"example.com:cp-1","occurred_at":"2024-01-01T00:00:00Z","received_at":"2024-01-01T00:00:00Z",` +
+		`"schema_version":1,"data":{"conn":1,"transaction_id":7,"meter_value":[{"value":1000}]}}`)
+	errs := r.Validate(EventName(domain.MeterValuesEvent), document)
+	if len(errs) == 0 {
+		t.Error("Validate() accepted a meter value without sampledValue")
+	}
+	for _, e := range errs {
+		if !strings.HasPrefix(e.Field, "data.meter_value") {
+			t.Errorf("Validate() error on %s, want only data.meter_value", e.Field)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const driftKey = "configuration:drift"
+
+// DriftService keeps the latest profile check of every charger. Entries
+// outlive the connection, so drift stays visible while a charger is away.
+type DriftService interface {
+	Save(context.Context, *domain.ConfigurationDrift) error
+	// Delete forgets a charger no profile applies to anymore.
+	Delete(ctx context.Context, cpID string) error
+	List(context.Context, domain.DriftFilter) ([]domain.ConfigurationDrift, error)
+}
+
+type driftService struct {
+	rdb redis.UniversalClient
+}
+
+func NewDriftService(rdb redis.UniversalClient) DriftService {
+	return &driftService{rdb: rdb}
+}
+
+func (d *driftService) Save(ctx context.Context, drift *domain.ConfigurationDrift) error {
+	payload, err := json.Marshal(drift)
+	if err != nil {
+		return err
+	}
+	return d.rdb.HSet(ctx, driftKey, drift.CpID, payload).Err()
+}
+
+func (d *driftService) Delete(ctx context.Context, cpID string) error {
+	return d.rdb.HDel(ctx, driftKey, cpID).Err()
+}
+
+func (d *driftService) List(ctx context.Context, filter domain.DriftFilter) ([]domain.ConfigurationDrift, error) {
+	var entries map[string]string
+	if filter.CpID != "" {
+		payload, err := d.rdb.HGet(ctx, driftKey, filter.CpID).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		entries = map[string]string{}
+		if err == nil {
+			entries[filter.CpID] = payload
+		}
+	} else {
+		var err error
+		if entries, err = d.rdb.HGetAll(ctx, driftKey).Result(); err != nil {
+			return nil, err
+		}
+	}
+	chargers := make([]domain.ConfigurationDrift, 0, len(entries))
+	for _, payload := range entries {
+		var drift domain.ConfigurationDrift
+		if err := json.Unmarshal([]byte(payload), &drift); err != nil {
+			continue
+		}
+		if filter.Match(&drift) {
+			chargers = append(chargers, drift)
+		}
+	}
+	sort.Slice(chargers, func(i, j int) bool { return chargers[i].CpID < chargers[j].CpID })
+	return chargers, nil
+}
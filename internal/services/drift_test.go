@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+func TestDriftService(t *testing.T) {
+	ctx := context.Background()
+	service := NewDriftService(newTestRedis(t))
+	for _, drift := range []*domain.ConfigurationDrift{
+		{CpID: "a:cp-2", InSync: true, CheckedAt: time.Now()},
+		{CpID: "a:cp-1", CheckedAt: time.Now(), Keys: []domain.KeyDrift{{Key: "HeartbeatInterval", Desired: "300", Actual: "60", Status: domain.KeyRebootRequired}}},
+	} {
+		if err := service.Save(ctx, drift); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	chargers, err := service.List(ctx, domain.DriftFilter{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(chargers) != 2 || chargers[0].CpID != "a:cp-1" || chargers[0].Keys[0].Status != domain.KeyRebootRequired {
+		t.Errorf("List() = %+v, want both chargers sorted", chargers)
+	}
+
+	chargers, _ = service.List(ctx, domain.DriftFilter{Drifted: true})
+	if len(chargers) != 1 || chargers[0].CpID != "a:cp-1" {
+		t.Errorf("List(drifted) = %+v, want a:cp-1", chargers)
+	}
+
+	chargers, _ = service.List(ctx, domain.DriftFilter{CpID: "a:cp-2"})
+	if len(chargers) != 1 || !chargers[0].InSync {
+		t.Errorf("List(cp_id) = %+v, want a:cp-2", chargers)
+	}
+
+	service.Delete(ctx, "a:cp-2")
+	chargers, _ = service.List(ctx, domain.DriftFilter{CpID: "a:cp-2"})
+	if len(chargers) != 0 {
+		t.Errorf("entry still listed after Delete()")
+	}
+}
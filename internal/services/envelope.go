@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const sequencesKey = "events:sequences"
+
+type receivedAtKey struct{}
+
+// WithReceivedAt records when the message behind the events published with
+// ctx reached the server.
+func WithReceivedAt(ctx context.Context, receivedAt time.Time) context.Context {
+	return context.WithValue(ctx, receivedAtKey{}, receivedAt)
+}
+
+// envelopeService fills in the envelope of every event before inner
+// publishes it.
+type envelopeService struct {
+	EventService
+	rdb        redis.UniversalClient
+	instanceID string
+	log        *zap.Logger
+}
+
+// WithEnvelope keeps the last sequence of every charger in Redis, shared by
+// all replicas.
+func WithEnvelope(inner EventService, rdb redis.UniversalClient, instanceID string, logger *zap.Logger) EventService {
+	return &envelopeService{
+		EventService: inner,
+		rdb:          rdb,
+		instanceID:   instanceID,
+		log:          logger,
+	}
+}
+
+func (e *envelopeService) SendEvent(ctx context.Context, event *domain.Event, log *zap.Logger) {
+	if err := e.Publish(ctx, event); err != nil {
+		log.Error("Event publish failed", zap.String("event", string(event.Event)), zap.String("id", event.ID), zap.Error(err))
+	}
+}
+
+func (e *envelopeService) Publish(ctx context.Context, event *domain.Event) error {
+	e.stamp(ctx, event)
+	return e.EventService.Publish(ctx, event)
+}
+
+// stamp leaves the fields a handler already set alone.
+func (e *envelopeService) stamp(ctx context.Context, event *domain.Event) {
+	now := time.Now().UTC()
+	if event.ID == "" {
+		event.ID = uuid.Must(uuid.NewV7()).String()
+	}
+	if event.ReceivedAt.IsZero() {
+		event.ReceivedAt = now
+		if receivedAt, ok := ctx.Value(receivedAtKey{}).(time.Time); ok {
+			event.ReceivedAt = receivedAt.UTC()
+		}
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = event.ReceivedAt
+	}
+	if event.InstanceID == "" {
+		event.InstanceID = e.instanceID
+	}
+	if event.SchemaVersion == 0 {
+		event.SchemaVersion = domain.EventSchemaVersion
+	}
+	if event.TraceParent == "" {
+		event.TraceParent = tracing.TraceParent(ctx)
+	}
+	if event.CpID != "" && event.Sequence == 0 {
+		event.Sequence = e.next(ctx, event.CpID, now)
+	}
+}
+
+// sequenceScript hands out max(last+1, now): starting from the clock in
+// microseconds keeps the sequence increasing should the hash be lost.
+var sequenceScript = redis.NewScript(`
+local sequence = redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+local now = tonumber(ARGV[2])
+if sequence < now then
+	redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+	sequence = now
+end
+return sequence
+`)
+
+// next falls back to the clock while Redis is unreachable, which keeps the
+// order only as far as the clocks of the replicas agree.
+func (e *envelopeService) next(ctx context.Context, cpID string, now time.Time) int64 {
+	sequence, err := sequenceScript.Run(ctx, e.rdb, []string{sequencesKey}, cpID, now.UnixMicro()).Int64()
+	if err != nil {
+		e.log.Warn("Event sequence taken from the clock", zap.String("cp_id", cpID), zap.Error(err))
+		return now.UnixMicro()
+	}
+	return sequence
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"go.uber.org/zap"
+)
+
+func TestWithEnvelope(t *testing.T) {
+	sink := &fakeSink{}
+	service := WithEnvelope(NewEventService(Route{Sink: sink}), newTestRedis(t), "ocpp-1", zap.NewNop())
+	receivedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	occurredAt := receivedAt.Add(-time.Minute)
+	ctx := WithReceivedAt(context.Background(), receivedAt)
+
+	first := &domain.Event{Event: domain.StartTransactionEvent, CpID: "cp-1", OccurredAt: occurredAt}
+	second := &domain.Event{Event: domain.HealthEvent, CpID: "cp-1"}
+	other := &domain.Event{Event: domain.HealthEvent, CpID: "cp-2"}
+	for _, event := range []*domain.Event{first, second, other} {
+		if err := service.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	if first.ID == "" || first.ID == second.ID {
+		t.Errorf("IDs = %q, %q, want unique", first.ID, second.ID)
+	}
+	if first.InstanceID != "ocpp-1" || first.SchemaVersion != domain.EventSchemaVersion {
+		t.Errorf("envelope = %+v", first)
+	}
+	if !first.OccurredAt.Equal(occurredAt) || !first.ReceivedAt.Equal(receivedAt) {
+		t.Errorf("first times = %v, %v, want the charger's and the receipt", first.OccurredAt, first.ReceivedAt)
+	}
+	if !second.OccurredAt.Equal(receivedAt) {
+		t.Errorf("OccurredAt = %v, want ReceivedAt without a charger timestamp", second.OccurredAt)
+	}
+	if second.Sequence <= first.Sequence {
+		t.Errorf("sequences = %d, %d, want increasing", first.Sequence, second.Sequence)
+	}
+	if other.Sequence == 0 {
+		t.Error("second charger got no sequence")
+	}
+	if got := len(sink.published()); got != 3 {
+		t.Errorf("published %d events, want 3", got)
+	}
+}
+
+func TestWithEnvelope_SequenceAcrossReplicas(t *testing.T) {
+	rdb := newTestRedis(t)
+	event := func(service EventService) int64 {
+		e := &domain.Event{Event: domain.HealthEvent, CpID: "cp-1"}
+		service.Publish(context.Background(), e)
+		return e.Sequence
+	}
+	one := WithEnvelope(NewEventService(), rdb, "ocpp-1", zap.NewNop())
+	two := WithEnvelope(NewEventService(), rdb, "ocpp-2", zap.NewNop())
+	// A replica whose clock runs behind still continues the sequence.
+	rdb.HSet(context.Background(), sequencesKey, "cp-1", time.Now().Add(time.Hour).UnixMicro())
+	before := event(one)
+	if after := event(two); after != before+1 {
+		t.Errorf("sequence on the other replica = %d, want %d", after, before+1)
+	}
+
+	// Without Redis the clock still gives one.
+	rdb.Close()
+	if sequence := event(one); sequence == 0 {
+		t.Error("no sequence without Redis")
+	}
+}
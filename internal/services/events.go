@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// Sink delivers events to one destination: Redis, a webhook, NATS, Kafka or
+// MQTT. A sink may also implement Backlogger, Runner and io.Closer.
+type Sink interface {
+	// Name identifies the sink in config, logs and metrics.
+	Name() string
+	Publish(context.Context, *domain.Event) error
+}
+
+// ErrRejected marks an event a sink refused for good, such as a webhook
+// answering 400: sending it again cannot help, so it is not queued.
+var ErrRejected = errors.New("rejected")
+
+// Backlogger is a sink that knows how many events its consumers have yet to
+// take. A sink that can only count them one by one stops after limit, or
+// answers with an upper bound when limit is 0.
+type Backlogger interface {
+	Backlog(ctx context.Context, limit int64) (int64, error)
+}
+
+// Runner is a sink with background work, such as replaying queued events.
+type Runner interface {
+	Run(context.Context)
+}
+
+type EventService interface {
+	// SendEvent publishes an event and only logs a failure.
+	SendEvent(context.Context, *domain.Event, *zap.Logger)
+	// Publish publishes an event and reports a failure to the caller.
+	Publish(context.Context, *domain.Event) error
+	// Backlog is the number of events not yet taken by consumers, summed
+	// over the sinks that can tell; limit is passed on to each.
+	Backlog(ctx context.Context, limit int64) (int64, error)
+	// Run does the background work of the sinks until ctx is done.
+	Run(context.Context)
+	// Close releases the sinks.
+	Close() error
+}
+
+// Route sends events to a sink. Empty Events means every event type.
+type Route struct {
+	Sink   Sink
+	Events []domain.EventTypes
+}
+
+func (r Route) matches(event domain.EventTypes) bool {
+	return len(r.Events) == 0 || slices.Contains(r.Events, event)
+}
+
+type eventService struct {
+	routes []Route
+}
+
+// NewEventService publishes every event to the sinks whose route matches it.
+// Without routes events are dropped.
+func NewEventService(routes ...Route) EventService {
+	return &eventService{routes: routes}
+}
+
+func (e *eventService) SendEvent(ctx context.Context, event *domain.Event, log *zap.Logger) {
+	if err := e.Publish(ctx, event); err != nil {
+		log.Error("Event publish failed", zap.String("event", string(event.Event)), zap.Error(err))
+	}
+}
+
+// Publish hands the event to every matching sink at once, so a slow or
+// failing sink neither holds the event back from the others nor adds its
+// timeout to theirs.
+func (e *eventService) Publish(ctx context.Context, event *domain.Event) error {
+	errs := make([]error, len(e.routes))
+	var wg sync.WaitGroup
+	for i, route := range e.routes {
+		if !route.matches(event.Event) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = route.Sink.Publish(ctx, event)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (e *eventService) Backlog(ctx context.Context, limit int64) (int64, error) {
+	var total int64
+	var errs []error
+	for _, route := range e.routes {
+		backlogger, ok := route.Sink.(Backlogger)
+		if !ok {
+			continue
+		}
+		backlog, err := backlogger.Backlog(ctx, limit)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		total += backlog
+	}
+	return total, errors.Join(errs...)
+}
+
+func (e *eventService) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, route := range e.routes {
+		if runner, ok := route.Sink.(Runner); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				runner.Run(ctx)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func (e *eventService) Close() error {
+	var errs []error
+	for _, route := range e.routes {
+		if closer, ok := route.Sink.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sinkError counts a failed publish and names the sink in the error.
+func sinkError(sink Sink, event *domain.Event, err error) error {
+	if err == nil {
+		return nil
+	}
+	metrics.EventPushFailures.WithLabelValues(sink.Name(), string(event.Event)).Inc()
+	return fmt.Errorf("%s: %w", sink.Name(), err)
+}
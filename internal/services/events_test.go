@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+type backlogSink struct {
+	fakeSink
+	backlog int64
+	closed  bool
+}
+
+func (b *backlogSink) Backlog(context.Context, int64) (int64, error) {
+	return b.backlog, nil
+}
+
+func (b *backlogSink) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestEventService_Routes(t *testing.T) {
+	ctx := context.Background()
+	all := &backlogSink{backlog: 3}
+	stops := &backlogSink{backlog: 4}
+	failing := &fakeSink{}
+	failing.fail.Store(true)
+	service := NewEventService(
+		Route{Sink: all},
+		Route{Sink: stops, Events: []domain.EventTypes{domain.StopTransactionEvent}},
+		Route{Sink: failing, Events: []domain.EventTypes{domain.HealthEvent}},
+	)
+
+	if err := service.Publish(ctx, stopEvent(1)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := service.Publish(ctx, &domain.Event{Event: domain.HealthEvent}); err == nil {
+		t.Error("Publish() error = nil, want the failing sink's error")
+	}
+	if got := len(all.published()); got != 2 {
+		t.Errorf("catch-all sink got %d events, want 2", got)
+	}
+	if got := len(stops.published()); got != 1 {
+		t.Errorf("routed sink got %d events, want 1", got)
+	}
+
+	if backlog, err := service.Backlog(ctx, 0); err != nil || backlog != 7 {
+		t.Errorf("Backlog() = %d, %v, want 7", backlog, err)
+	}
+	service.Close()
+	if !all.closed || !stops.closed {
+		t.Error("Close() did not close the sinks")
+	}
+}
+
+// meetSink only returns once every meetSink has been called.
+type meetSink struct {
+	fakeSink
+	met *sync.WaitGroup
+}
+
+func (m *meetSink) Publish(ctx context.Context, event *domain.Event) error {
+	m.met.Done()
+	done := make(chan struct{})
+	go func() {
+		m.met.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(time.Second):
+		return errors.New("published one sink at a time")
+	}
+}
+
+func TestEventService_PublishesConcurrently(t *testing.T) {
+	var met sync.WaitGroup
+	met.Add(2)
+	service := NewEventService(Route{Sink: &meetSink{met: &met}}, Route{Sink: &meetSink{met: &met}})
+	if err := service.Publish(context.Background(), stopEvent(1)); err != nil {
+		t.Errorf("Publish() error = %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// feedQueue is how many events a subscriber may fall behind before it is
+// dropped; it reconnects with Last-Event-ID and catches up from the buffer.
+const feedQueue = 256
+
+// FeedEvent is an event on the live feed with its JSON encoding.
+type FeedEvent struct {
+	Event   *domain.Event
+	Payload []byte
+}
+
+// Feed is the sink behind /events/stream. Replicas share events over a Redis
+// pub/sub channel, so a dashboard sees every charger whichever replica it
+// connects to, and each replica keeps the last events for resuming. Without
+// Redis, events stay on the replica that received them.
+type Feed struct {
+	rdb     redis.UniversalClient
+	channel string
+	size    int
+	log     *zap.Logger
+
+	mux         sync.Mutex
+	buffer      []FeedEvent
+	subscribers map[*feedSubscriber]struct{}
+	closed      bool
+}
+
+type feedSubscriber struct {
+	filter domain.EventFilter
+	events chan FeedEvent
+}
+
+// NewFeed keeps the last size events, none when size is not positive. rdb
+// may be nil.
+func NewFeed(rdb redis.UniversalClient, channel string, size int, log *zap.Logger) *Feed {
+	size = max(size, 0)
+	return &Feed{
+		rdb:         rdb,
+		channel:     channel,
+		size:        size,
+		log:         log,
+		subscribers: make(map[*feedSubscriber]struct{}),
+	}
+}
+
+func (f *Feed) Name() string {
+	return "live"
+}
+
+// Publish never fails: a feed is best effort, and while Redis is down the
+// event still reaches the subscribers of this replica.
+func (f *Feed) Publish(ctx context.Context, event *domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if f.rdb != nil {
+		err := f.rdb.Publish(ctx, f.channel, payload).Err()
+		if err == nil {
+			return nil
+		}
+		f.log.Debug("Live feed publish failed, delivering locally", zap.Error(err))
+	}
+	f.deliver(FeedEvent{Event: event, Payload: payload})
+	return nil
+}
+
+// Run delivers the events of every replica until ctx is done.
+func (f *Feed) Run(ctx context.Context) {
+	if f.rdb == nil {
+		return
+	}
+	pubsub := f.rdb.Subscribe(ctx, f.channel)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var event domain.Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				f.log.Warn("Invalid live feed message", zap.Error(err))
+				continue
+			}
+			f.deliver(FeedEvent{Event: &event, Payload: []byte(msg.Payload)})
+		}
+	}
+}
+
+func (f *Feed) deliver(event FeedEvent) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.buffer = append(f.buffer, event)
+	if len(f.buffer) > f.size {
+		f.buffer = f.buffer[len(f.buffer)-f.size:]
+	}
+	for sub := range f.subscribers {
+		if !sub.filter.Match(event.Event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			f.drop(sub)
+		}
+	}
+}
+
+// Subscribe returns the buffered events after lastEventID, oldest first, and
+// a channel of the events that follow. Event IDs are UUIDv7, so "after"
+// holds across replicas and for an ID that has left the buffer. The channel
+// is closed when the subscriber falls behind or the feed disconnects.
+func (f *Feed) Subscribe(filter domain.EventFilter, lastEventID string) ([]FeedEvent, <-chan FeedEvent, func()) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	var backlog []FeedEvent
+	if lastEventID != "" {
+		for _, event := range f.buffer {
+			if event.Event.ID > lastEventID && filter.Match(event.Event) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+	sub := &feedSubscriber{filter: filter, events: make(chan FeedEvent, feedQueue)}
+	if f.closed {
+		close(sub.events)
+		return backlog, sub.events, func() {}
+	}
+	f.subscribers[sub] = struct{}{}
+	metrics.LiveEventClients.Inc()
+	cancel := func() {
+		f.mux.Lock()
+		defer f.mux.Unlock()
+		f.drop(sub)
+	}
+	return backlog, sub.events, cancel
+}
+
+// Disconnect ends every subscription, so that streams let a shutdown finish.
+func (f *Feed) Disconnect() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.closed = true
+	for sub := range f.subscribers {
+		f.drop(sub)
+	}
+}
+
+func (f *Feed) drop(sub *feedSubscriber) {
+	if _, ok := f.subscribers[sub]; !ok {
+		return
+	}
+	delete(f.subscribers, sub)
+	close(sub.events)
+	metrics.LiveEventClients.Dec()
+}
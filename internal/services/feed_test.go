@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"go.uber.org/zap"
+)
+
+func feedEvent(id, cpID string) *domain.Event {
+	return &domain.Event{ID: id, Event: domain.HealthEvent, CpID: cpID, Data: domain.Healthcheck{Charger: cpID}}
+}
+
+func TestFeed(t *testing.T) {
+	feed := NewFeed(nil, "", 3, zap.NewNop())
+	ctx := context.Background()
+	for i, cpID := range []string{"cp-1", "cp-2", "cp-1", "cp-1"} {
+		feed.Publish(ctx, feedEvent(string(rune('a'+i)), cpID))
+	}
+
+	// "a" has left the buffer; resuming from it returns what is left after it.
+	backlog, events, cancel := feed.Subscribe(domain.EventFilter{CpIDs: []string{"cp-1"}}, "a")
+	defer cancel()
+	if len(backlog) != 2 || backlog[0].Event.ID != "c" || backlog[1].Event.ID != "d" {
+		t.Errorf("backlog = %+v, want c, d", backlog)
+	}
+
+	feed.Publish(ctx, feedEvent("e", "cp-2"))
+	feed.Publish(ctx, feedEvent("f", "cp-1"))
+	select {
+	case event := <-events:
+		if event.Event.ID != "f" {
+			t.Errorf("event = %s, want f", event.Event.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+
+	feed.Disconnect()
+	if _, ok := <-events; ok {
+		t.Error("events still open after Disconnect")
+	}
+}
+
+func TestFeed_NegativeSize(t *testing.T) {
+	feed := NewFeed(nil, "", -1, zap.NewNop())
+	feed.Publish(context.Background(), feedEvent("a", "cp-1"))
+	backlog, _, cancel := feed.Subscribe(domain.EventFilter{}, "")
+	defer cancel()
+	if len(backlog) != 0 {
+		t.Errorf("backlog = %+v, want none", backlog)
+	}
+}
+
+func TestFeed_DropsSlowSubscriber(t *testing.T) {
+	feed := NewFeed(nil, "", 10, zap.NewNop())
+	_, events, cancel := feed.Subscribe(domain.EventFilter{}, "")
+	defer cancel()
+	for range feedQueue + 1 {
+		feed.Publish(context.Background(), feedEvent("x", "cp-1"))
+	}
+	n := 0
+	for range events {
+		n++
+	}
+	if n != feedQueue {
+		t.Errorf("received %d events before the drop, want %d", n, feedQueue)
+	}
+}
+
+func TestFeed_Redis(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rdb := newTestRedis(t)
+	// Two replicas on one channel.
+	a := NewFeed(rdb, "test:events:live", 10, zap.NewNop())
+	b := NewFeed(rdb, "test:events:live", 10, zap.NewNop())
+	go a.Run(ctx)
+	go b.Run(ctx)
+	_, events, unsubscribe := b.Subscribe(domain.EventFilter{}, "")
+	defer unsubscribe()
+	time.Sleep(100 * time.Millisecond)
+
+	a.Publish(ctx, feedEvent("a", "cp-1"))
+	select {
+	case event := <-events:
+		if event.Event.ID != "a" || event.Event.CpID != "cp-1" {
+			t.Errorf("event = %+v", event.Event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event did not reach the other replica")
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+// Format is how a sink encodes events.
+type Format string
+
+const (
+	// FormatNative is domain.Event as JSON.
+	FormatNative Format = "native"
+	// FormatCloudEvents is the CloudEvents 1.0 structured JSON mode.
+	FormatCloudEvents Format = "cloudevents"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case "", FormatNative:
+		return FormatNative, nil
+	case FormatCloudEvents:
+		return FormatCloudEvents, nil
+	}
+	return "", fmt.Errorf("unknown event format %q", value)
+}
+
+func (f Format) Encode(event *domain.Event) ([]byte, error) {
+	if f == FormatCloudEvents {
+		return json.Marshal(event.CloudEvent())
+	}
+	return json.Marshal(event)
+}
+
+// ContentType is the media type of an encoded event.
+func (f Format) ContentType() string {
+	if f == FormatCloudEvents {
+		return "application/cloudevents+json"
+	}
+	return "application/json"
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+func TestFormat_CloudEvents(t *testing.T) {
+	occurredAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	event := &domain.Event{
+		ID:            "0194...",
+		Event:         domain.StopTransactionEvent,
+		Domain:        "example.com",
+		CpID:          "cp-1",
+		InstanceID:    "ocpp-1",
+		Sequence:      42,
+		OccurredAt:    occurredAt,
+		ReceivedAt:    occurredAt.Add(time.Second),
+		SchemaVersion: domain.EventSchemaVersion,
+		Data:          domain.StopTransaction{Charger: "cp-1", TransactionId: 7},
+	}
+	payload, err := FormatCloudEvents.Encode(event)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var got map[string]any
+	json.Unmarshal(payload, &got)
+	want := map[string]any{
+		"specversion":     "1.0",
+		"id":              "0194...",
+		"source":          "/ocpp/ocpp-1",
+		"type":            "ocpp.stop_transaction",
+		"subject":         "cp-1",
+		"time":            "2025-01-02T03:04:05Z",
+		"datacontenttype": "application/json",
+		"sequence":        float64(42),
+		"receivedat":      "2025-01-02T03:04:06Z",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %v, want %v", key, got[key], value)
+		}
+	}
+	if data, _ := got["data"].(map[string]any); data["transaction_id"] != float64(7) {
+		t.Errorf("data = %v", got["data"])
+	}
+	if FormatCloudEvents.ContentType() != "application/cloudevents+json" {
+		t.Errorf("ContentType() = %q", FormatCloudEvents.ContentType())
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if format, err := ParseFormat(""); err != nil || format != FormatNative {
+		t.Errorf("ParseFormat(\"\") = %q, %v", format, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(\"xml\") error = nil")
+	}
+}
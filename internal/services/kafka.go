@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// kafkaSink produces every event to one topic, keyed by charger so the
+// events of a charger stay in order on one partition.
+type kafkaSink struct {
+	client *kgo.Client
+	format Format
+}
+
+func NewKafkaSink(brokers []string, topic string, timeout time.Duration, format Format) (Sink, error) {
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(brokers...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.ClientID("ocpp"),
+		kgo.RecordDeliveryTimeout(timeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaSink{client: client, format: format}, nil
+}
+
+func (k *kafkaSink) Name() string {
+	return "kafka"
+}
+
+func (k *kafkaSink) Publish(ctx context.Context, event *domain.Event) error {
+	payload, err := k.format.Encode(event)
+	if err != nil {
+		return err
+	}
+	record := &kgo.Record{
+		Key:   []byte(eventKey(event)),
+		Value: payload,
+		Headers: []kgo.RecordHeader{
+			{Key: "event", Value: []byte(event.Event)},
+			{Key: "content-type", Value: []byte(k.format.ContentType())},
+		},
+	}
+	return sinkError(k, event, k.client.ProduceSync(ctx, record).FirstErr())
+}
+
+func (k *kafkaSink) Close() error {
+	k.client.Close()
+	return nil
+}
+
+// eventKey is the charger an event is about, already "<domain>:<charger>",
+// or the domain alone for an event without one.
+func eventKey(event *domain.Event) string {
+	if event.CpID == "" {
+		return event.Domain
+	}
+	return event.CpID
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestKafkaSink(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, "ocpp.events"))
+	if err != nil {
+		t.Fatalf("NewCluster() error = %v", err)
+	}
+	defer cluster.Close()
+
+	sink, err := NewKafkaSink(cluster.ListenAddrs(), "ocpp.events", 5*time.Second, FormatNative)
+	if err != nil {
+		t.Fatalf("NewKafkaSink() error = %v", err)
+	}
+	defer sink.(*kafkaSink).Close()
+	event := &domain.Event{
+		Event:  domain.StopTransactionEvent,
+		Domain: "example.com",
+		CpID:   "example.com:cp-1",
+		Data:   domain.StopTransaction{Charger: "example.com:cp-1", TransactionId: 7},
+	}
+	if err := sink.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumeTopics("ocpp.events"),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer consumer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fetches := consumer.PollFetches(ctx)
+	if err := fetches.Err(); err != nil {
+		t.Fatalf("PollFetches() error = %v", err)
+	}
+	records := fetches.Records()
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records))
+	}
+	if string(records[0].Key) != "example.com:cp-1" {
+		t.Errorf("Key = %q, want the charger", records[0].Key)
+	}
+	var got domain.Event
+	if err := json.Unmarshal(records[0].Value, &got); err != nil || got.Event != domain.StopTransactionEvent {
+		t.Errorf("Value = %s, %v", records[0].Value, err)
+	}
+}
+
+func TestEventKey(t *testing.T) {
+	tests := []struct {
+		event domain.Event
+		want  string
+	}{
+		{domain.Event{Domain: "a", CpID: "a:cp-1"}, "a:cp-1"},
+		{domain.Event{Domain: "a"}, "a"},
+	}
+	for _, tt := range tests {
+		if got := eventKey(&tt.event); got != tt.want {
+			t.Errorf("eventKey(%+v) = %q, want %q", tt.event, got, tt.want)
+		}
+	}
+}
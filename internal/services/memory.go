@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+// MemorySink keeps every event it is given, for tests and in-process runs
+// that want to look at what the server sent without a broker.
+type MemorySink struct {
+	mux    sync.Mutex
+	events []*domain.Event
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (m *MemorySink) Name() string {
+	return "memory"
+}
+
+func (m *MemorySink) Publish(ctx context.Context, event *domain.Event) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.events = append(m.events, event)
+	return nil
+}
+
+// Events returns the events published so far, oldest first, or only those of
+// the given types.
+func (m *MemorySink) Events(types ...domain.EventTypes) []*domain.Event {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if len(types) == 0 {
+		return slices.Clone(m.events)
+	}
+	var events []*domain.Event
+	for _, event := range m.events {
+		if slices.Contains(types, event.Event) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
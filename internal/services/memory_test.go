@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"go.uber.org/zap"
+)
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	service := NewEventService(Route{Sink: sink})
+	ctx := context.Background()
+	service.SendEvent(ctx, &domain.Event{Event: domain.HealthEvent, Data: domain.Healthcheck{Charger: "cp-1"}}, zap.NewNop())
+	service.SendEvent(ctx, &domain.Event{Event: domain.ConnectChargerEvent, Data: domain.ConnectCharger{Charger: "cp-1"}}, zap.NewNop())
+
+	if events := sink.Events(); len(events) != 2 || events[0].Event != domain.HealthEvent {
+		t.Errorf("Events() = %+v, want both events in order", events)
+	}
+	if events := sink.Events(domain.ConnectChargerEvent); len(events) != 1 || events[0].Event != domain.ConnectChargerEvent {
+		t.Errorf("Events(connect) = %+v", events)
+	}
+	if events := sink.Events(domain.StopTransactionEvent); len(events) != 0 {
+		t.Errorf("Events(stop) = %+v, want none", events)
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+func TestMqttSink(t *testing.T) {
+	broker := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	broker.AddHook(new(auth.AllowHook), nil)
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := broker.AddListener(tcp); err != nil {
+		t.Fatalf("AddListener() error = %v", err)
+	}
+	if err := broker.Serve(); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	defer broker.Close()
+
+	received := make(chan packets.Packet, 1)
+	broker.Subscribe("ocpp/events/#", 1, func(cl *mochi.Client, sub packets.Subscription, pk packets.Packet) {
+		received <- pk
+	})
+
+	sink := NewMqttSink(MqttOptions{
+		URL:      "tcp://" + tcp.Address(),
+		ClientID: "ocpp-test",
+		Topic:    "ocpp/events",
+		QoS:      1,
+		Timeout:  5 * time.Second,
+	})
+	defer sink.(*mqttSink).Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !sink.(*mqttSink).client.IsConnectionOpen() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sink.Publish(context.Background(), stopEvent(7)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	select {
+	case pk := <-received:
+		if pk.TopicName != "ocpp/events/stop_transaction" {
+			t.Errorf("TopicName = %q", pk.TopicName)
+		}
+		var event domain.Event
+		if err := json.Unmarshal(pk.Payload, &event); err != nil || event.Event != domain.StopTransactionEvent {
+			t.Errorf("Payload = %s, %v", pk.Payload, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
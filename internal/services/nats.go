@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/nats-io/nats.go"
+)
+
+// natsSink publishes every event to "<subject>.<event type>", so consumers
+// can subscribe to "<subject>.>" or to single event types.
+type natsSink struct {
+	conn    *nats.Conn
+	subject string
+	timeout time.Duration
+	format  Format
+}
+
+// NewNatsSink connects to url. The connection is retried in the background,
+// so a NATS server that is down at startup only fails publishes.
+func NewNatsSink(url, subject string, timeout time.Duration, format Format) (Sink, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("ocpp"),
+		nats.MaxReconnects(-1),
+		nats.RetryOnFailedConnect(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &natsSink{conn: conn, subject: subject, timeout: timeout, format: format}, nil
+}
+
+func (n *natsSink) Name() string {
+	return "nats"
+}
+
+// Publish waits for the server to take the event; while disconnected it fails
+// instead of buffering, so the outbox keeps the event.
+func (n *natsSink) Publish(ctx context.Context, event *domain.Event) error {
+	payload, err := n.format.Encode(event)
+	if err != nil {
+		return err
+	}
+	if !n.conn.IsConnected() {
+		return sinkError(n, event, nats.ErrDisconnected)
+	}
+	msg := nats.NewMsg(n.subject + "." + string(event.Event))
+	msg.Header.Set("Ocpp-Event", string(event.Event))
+	msg.Header.Set("Content-Type", n.format.ContentType())
+	msg.Data = payload
+	if err := n.conn.PublishMsg(msg); err != nil {
+		return sinkError(n, event, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	return sinkError(n, event, n.conn.FlushWithContext(ctx))
+}
+
+func (n *natsSink) Close() error {
+	return n.conn.Drain()
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestNatsSink(t *testing.T) {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoSigs: true})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	go srv.Start()
+	defer srv.Shutdown()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+
+	consumer, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer consumer.Close()
+	sub, err := consumer.SubscribeSync("ocpp.events.>")
+	if err != nil {
+		t.Fatalf("SubscribeSync() error = %v", err)
+	}
+	consumer.Flush()
+
+	sink, err := NewNatsSink(srv.ClientURL(), "ocpp.events", time.Second, FormatNative)
+	if err != nil {
+		t.Fatalf("NewNatsSink() error = %v", err)
+	}
+	defer sink.(*natsSink).Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !sink.(*natsSink).conn.IsConnected() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sink.Publish(context.Background(), stopEvent(7)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("NextMsg() error = %v", err)
+	}
+	if msg.Subject != "ocpp.events.stop_transaction" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	var event domain.Event
+	if err := json.Unmarshal(msg.Data, &event); err != nil || event.Event != domain.StopTransactionEvent {
+		t.Errorf("Data = %s, %v", msg.Data, err)
+	}
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	outboxSegmentSize = 8 << 20
+	outboxSegmentExt  = ".seg"
+	outboxCursorFile  = "cursor"
+
+	outboxMinBackoff = time.Second
+	outboxMaxBackoff = 30 * time.Second
+)
+
+var ErrOutboxFull = errors.New("outbox full")
+
+// Outbox wraps a sink: it appends the events the sink cannot take to segment
+// files on disk and replays them in order once the sink is back. While
+// anything is queued, new events are queued behind it so the order holds.
+//
+// Segments are JSON lines named by sequence number; the cursor file keeps the
+// replay position, so a restart resends at most the event in flight.
+type Outbox struct {
+	inner    Sink
+	dir      string
+	maxBytes int64
+	log      *zap.Logger
+	wake     chan struct{}
+	// segmentSize is where a new segment is started.
+	segmentSize int64
+
+	mux      sync.Mutex
+	segments []int64
+	writer   *os.File
+	// writeSize is the size of the newest segment; bytes is what is still
+	// to be replayed across all segments.
+	writeSize int64
+	bytes     int64
+	pending   int64
+
+	// The read side belongs to Run; head is the line being replayed.
+	readSeg    int64
+	readOffset int64
+	reader     *os.File
+	buf        *bufio.Reader
+	head       []byte
+}
+
+// NewOutbox queues events for inner in dir, holding at most maxBytes. Events
+// left from a previous run are replayed by Run.
+func NewOutbox(dir string, maxBytes int64, inner Sink, log *zap.Logger) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	o := &Outbox{
+		inner:    inner,
+		dir:      dir,
+		maxBytes: maxBytes,
+		log:      log.With(zap.String("sink", inner.Name())),
+		wake:     make(chan struct{}, 1),
+
+		segmentSize: outboxSegmentSize,
+	}
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+	if o.pending > 0 {
+		o.log.Warn("Outbox has events from a previous run", zap.Int64("events", o.pending))
+	}
+	return o, nil
+}
+
+func (o *Outbox) segmentPath(seq int64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%012d%s", seq, outboxSegmentExt))
+}
+
+func (o *Outbox) load() error {
+	names, err := filepath.Glob(filepath.Join(o.dir, "*"+outboxSegmentExt))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		seq, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), outboxSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		o.segments = append(o.segments, seq)
+	}
+	sort.Slice(o.segments, func(i, j int) bool { return o.segments[i] < o.segments[j] })
+
+	if data, err := os.ReadFile(filepath.Join(o.dir, outboxCursorFile)); err == nil {
+		fmt.Sscan(string(data), &o.readSeg, &o.readOffset)
+	}
+	// Segments before the cursor were fully replayed.
+	for len(o.segments) > 0 && o.segments[0] < o.readSeg {
+		os.Remove(o.segmentPath(o.segments[0]))
+		o.segments = o.segments[1:]
+	}
+	if len(o.segments) == 0 || o.segments[0] != o.readSeg {
+		o.readOffset = 0
+		if len(o.segments) > 0 {
+			o.readSeg = o.segments[0]
+		}
+	}
+
+	for _, seq := range o.segments {
+		info, err := os.Stat(o.segmentPath(seq))
+		if err != nil {
+			return err
+		}
+		o.writeSize = info.Size()
+		offset := int64(0)
+		if seq == o.readSeg {
+			offset = o.readOffset
+		}
+		o.bytes += info.Size() - offset
+		lines, err := countLines(o.segmentPath(seq), offset)
+		if err != nil {
+			return err
+		}
+		o.pending += lines
+	}
+	if len(o.segments) > 0 {
+		if err := o.openWriter(o.segments[len(o.segments)-1]); err != nil {
+			return err
+		}
+	}
+	o.updateGauges()
+	return nil
+}
+
+func countLines(path string, offset int64) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var lines int64
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), outboxSegmentSize)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines, scanner.Err()
+}
+
+// openWriter opens a segment for appending. A line cut short by a crash is
+// terminated so the next event starts on its own line.
+func (o *Outbox) openWriter(seq int64) error {
+	file, err := os.OpenFile(o.segmentPath(seq), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	size := info.Size()
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, size-1); err == nil && last[0] != '\n' {
+			n, _ := file.Write([]byte{'\n'})
+			size += int64(n)
+			o.bytes += int64(n)
+		}
+	}
+	o.writer, o.writeSize = file, size
+	return nil
+}
+
+func (o *Outbox) updateGauges() {
+	metrics.OutboxEvents.WithLabelValues(o.inner.Name()).Set(float64(o.pending))
+	metrics.OutboxBytes.WithLabelValues(o.inner.Name()).Set(float64(o.bytes))
+}
+
+func (o *Outbox) Name() string {
+	return o.inner.Name()
+}
+
+// Publish sends the event straight to the sink when nothing is queued, and
+// appends it to the outbox otherwise or when the sink fails, leaving the
+// retries to Run. It fails when the sink rejected the event or it could not
+// be written to disk either.
+func (o *Outbox) Publish(ctx context.Context, event *domain.Event) error {
+	o.mux.Lock()
+	queued := o.pending > 0
+	o.mux.Unlock()
+	if !queued {
+		err := o.inner.Publish(ctx, event)
+		if err == nil || errors.Is(err, ErrRejected) {
+			return err
+		}
+		o.log.Warn("Queueing event in outbox", zap.String("event", string(event.Event)), zap.Error(err))
+	}
+	return o.append(event)
+}
+
+func (o *Outbox) append(event *domain.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.bytes+int64(len(line)) > o.maxBytes {
+		metrics.OutboxDropped.WithLabelValues(o.inner.Name()).Inc()
+		return ErrOutboxFull
+	}
+	if o.writer == nil || o.writeSize+int64(len(line)) > o.segmentSize {
+		if err := o.roll(); err != nil {
+			return err
+		}
+	}
+	n, err := o.writer.Write(line)
+	if err == nil {
+		// Write-ahead: the event is on disk before Publish reports it
+		// queued.
+		err = o.writer.Sync()
+	}
+	if err != nil {
+		o.discard(n)
+		o.updateGauges()
+		return err
+	}
+	o.writeSize += int64(n)
+	o.bytes += int64(n)
+	o.pending++
+	o.updateGauges()
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// discard cuts the n bytes of a failed append off the newest segment, so the
+// next event does not join a partial line. When that fails too, the next
+// append starts a new segment, and the partial line stays behind to be
+// read and dropped as corrupt. The caller holds o.mux.
+func (o *Outbox) discard(n int) {
+	if n == 0 || o.writer.Truncate(o.writeSize) == nil {
+		return
+	}
+	o.writer.Close()
+	o.writer = nil
+	o.writeSize += int64(n)
+	o.bytes += int64(n)
+	o.pending++
+}
+
+// roll starts a new segment. The caller holds o.mux.
+func (o *Outbox) roll() error {
+	// Numbers never go back, so a stale cursor cannot point past a new
+	// segment.
+	seq := o.readSeg + 1
+	if len(o.segments) > 0 {
+		seq = o.segments[len(o.segments)-1] + 1
+	}
+	if o.writer != nil {
+		if err := o.writer.Close(); err != nil {
+			return err
+		}
+		o.writer = nil
+	}
+	if err := o.openWriter(seq); err != nil {
+		return err
+	}
+	o.segments = append(o.segments, seq)
+	if len(o.segments) == 1 {
+		if o.reader != nil {
+			o.reader.Close()
+			o.reader, o.buf = nil, nil
+		}
+		o.readSeg, o.readOffset = seq, 0
+		return o.saveCursor()
+	}
+	return nil
+}
+
+// Backlog adds the queued events to what the consumers have not taken yet.
+func (o *Outbox) Backlog(ctx context.Context, limit int64) (int64, error) {
+	var backlog int64
+	var err error
+	if backlogger, ok := o.inner.(Backlogger); ok {
+		backlog, err = backlogger.Backlog(ctx, limit)
+	}
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	return backlog + o.pending, err
+}
+
+// Run replays queued events until ctx is done, backing off while the sink
+// keeps failing.
+func (o *Outbox) Run(ctx context.Context) {
+	backoff := outboxMinBackoff
+	for {
+		o.mux.Lock()
+		pending := o.pending
+		o.mux.Unlock()
+		if pending == 0 {
+			select {
+			case <-o.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		err := o.replayNext(ctx)
+		if err == nil {
+			backoff = outboxMinBackoff
+			continue
+		}
+		o.log.Warn("Outbox replay failed", zap.Int64("events", pending), zap.Duration("retry_in", backoff), zap.Error(err))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, outboxMaxBackoff)
+	}
+}
+
+// replayNext publishes the oldest queued event and moves the cursor past it.
+func (o *Outbox) replayNext(ctx context.Context) error {
+	line, err := o.peek()
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage{}
+	event := domain.Event{Data: &raw}
+	if err := json.Unmarshal(line, &event); err != nil {
+		o.log.Error("Dropping corrupt outbox entry", zap.ByteString("line", line), zap.Error(err))
+	} else if err := o.inner.Publish(ctx, &event); errors.Is(err, ErrRejected) {
+		o.log.Error("Dropping rejected outbox entry", zap.String("event", string(event.Event)), zap.Error(err))
+	} else if err != nil {
+		return err
+	}
+	return o.advance()
+}
+
+// peek returns the line at the cursor without consuming it.
+func (o *Outbox) peek() ([]byte, error) {
+	if o.head != nil {
+		return o.head, nil
+	}
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	for {
+		if o.reader == nil {
+			file, err := os.Open(o.segmentPath(o.readSeg))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := file.Seek(o.readOffset, io.SeekStart); err != nil {
+				file.Close()
+				return nil, err
+			}
+			o.reader, o.buf = file, bufio.NewReader(file)
+		}
+		// Appends hold o.mux, so a line without '\n' can only be the tail
+		// of an older segment cut short by a crash; it is returned and then
+		// dropped as corrupt.
+		line, err := o.buf.ReadBytes('\n')
+		if len(line) > 0 {
+			o.head = line
+			return line, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if o.readSeg == o.segments[len(o.segments)-1] {
+			return nil, io.EOF
+		}
+		// The segment is done and a newer one exists.
+		o.reader.Close()
+		o.reader, o.buf = nil, nil
+		os.Remove(o.segmentPath(o.readSeg))
+		o.segments = o.segments[1:]
+		o.readSeg, o.readOffset = o.segments[0], 0
+		o.updateGauges()
+		if err := o.saveCursor(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (o *Outbox) advance() error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.readOffset += int64(len(o.head))
+	o.bytes -= int64(len(o.head))
+	o.head = nil
+	o.pending--
+	if o.pending == 0 && len(o.segments) == 1 && o.writer != nil {
+		// Everything was replayed: start the segment over instead of
+		// keeping its read part on disk.
+		if err := o.writer.Truncate(0); err != nil {
+			o.updateGauges()
+			return err
+		}
+		if o.reader != nil {
+			o.reader.Close()
+			o.reader, o.buf = nil, nil
+		}
+		o.writeSize, o.readOffset, o.bytes = 0, 0, 0
+	}
+	o.updateGauges()
+	return o.saveCursor()
+}
+
+func (o *Outbox) saveCursor() error {
+	return os.WriteFile(filepath.Join(o.dir, outboxCursorFile), []byte(fmt.Sprintf("%d %d", o.readSeg, o.readOffset)), 0o644)
+}
+
+// Close releases the segment files and the sink; queued events stay for the
+// next run.
+func (o *Outbox) Close() error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	var errs []error
+	if o.reader != nil {
+		o.reader.Close()
+		o.reader = nil
+	}
+	if o.writer != nil {
+		errs = append(errs, o.writer.Close())
+		o.writer = nil
+	}
+	if closer, ok := o.inner.(io.Closer); ok {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"go.uber.org/zap"
+)
+
+type fakeSink struct {
+	fail   atomic.Bool
+	reject atomic.Bool
+	mux    sync.Mutex
+	events []string
+}
+
+func (f *fakeSink) Name() string {
+	return "fake"
+}
+
+func (f *fakeSink) Publish(ctx context.Context, event *domain.Event) error {
+	if f.fail.Load() {
+		return errors.New("connection refused")
+	}
+	if f.reject.Load() {
+		return fmt.Errorf("%w: bad request", ErrRejected)
+	}
+	data, _ := json.Marshal(event.Data)
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.events = append(f.events, string(data))
+	return nil
+}
+
+func (f *fakeSink) published() []string {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return append([]string{}, f.events...)
+}
+
+func stopEvent(n int) *domain.Event {
+	return &domain.Event{Event: domain.StopTransactionEvent, Data: domain.StopTransaction{TransactionId: n}}
+}
+
+func waitPublished(t *testing.T, inner *fakeSink, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if events := inner.published(); len(events) >= n {
+			return events
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("published %d events, want %d", len(inner.published()), n)
+	return nil
+}
+
+func TestOutbox_QueuesInOrderWhileSinkIsDown(t *testing.T) {
+	ctx := context.Background()
+	inner := &fakeSink{}
+	outbox, err := NewOutbox(t.TempDir(), 1<<20, inner, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOutbox() error = %v", err)
+	}
+	defer outbox.Close()
+	outbox.segmentSize = 200
+
+	if err := outbox.Publish(ctx, stopEvent(1)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	inner.fail.Store(true)
+	for n := 2; n <= 6; n++ {
+		if err := outbox.Publish(ctx, stopEvent(n)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	// The sink is back, but the queue goes first.
+	inner.fail.Store(false)
+	outbox.Publish(ctx, stopEvent(7))
+	if backlog, _ := outbox.Backlog(ctx, 0); backlog != 6 {
+		t.Errorf("Backlog() = %d, want 6", backlog)
+	}
+	if len(outbox.segments) < 2 {
+		t.Errorf("segments = %v, want the queue spread over several", outbox.segments)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go outbox.Run(runCtx)
+	events := waitPublished(t, inner, 7)
+	for i, event := range events {
+		var stop domain.StopTransaction
+		json.Unmarshal([]byte(event), &stop)
+		if stop.TransactionId != i+1 {
+			t.Fatalf("events = %v, want transactions 1..7 in order", events)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if backlog, _ := outbox.Backlog(ctx, 0); backlog == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if backlog, _ := outbox.Backlog(ctx, 0); backlog != 0 {
+		t.Errorf("Backlog() after replay = %d, want 0", backlog)
+	}
+	outbox.Publish(ctx, stopEvent(8))
+	if events := inner.published(); len(events) != 8 {
+		t.Errorf("event after replay not published directly: %v", events)
+	}
+	names, _ := filepath.Glob(filepath.Join(outbox.dir, "*"+outboxSegmentExt))
+	if len(names) > 1 {
+		t.Errorf("segments left after replay = %v", names)
+	}
+}
+
+func TestOutbox_SurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner := &fakeSink{}
+	inner.fail.Store(true)
+	outbox, _ := NewOutbox(dir, 1<<20, inner, zap.NewNop())
+	for n := 1; n <= 3; n++ {
+		outbox.Publish(ctx, stopEvent(n))
+	}
+	// Replay the first event, then "crash".
+	inner.fail.Store(false)
+	if err := outbox.replayNext(ctx); err != nil {
+		t.Fatalf("replayNext() error = %v", err)
+	}
+	outbox.Close()
+
+	outbox, err := NewOutbox(dir, 1<<20, inner, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOutbox() error = %v", err)
+	}
+	defer outbox.Close()
+	if backlog, _ := outbox.Backlog(ctx, 0); backlog != 2 {
+		t.Fatalf("Backlog() after restart = %d, want 2", backlog)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go outbox.Run(runCtx)
+	events := waitPublished(t, inner, 3)
+	if len(events) != 3 || events[2] != `{"charger":"","transaction_id":3,"reason":"","meter_stop":0}` {
+		t.Errorf("events = %v", events)
+	}
+}
+
+func TestOutbox_FailedAppend(t *testing.T) {
+	ctx := context.Background()
+	inner := &fakeSink{}
+	inner.fail.Store(true)
+	outbox, _ := NewOutbox(t.TempDir(), 1<<20, inner, zap.NewNop())
+	defer outbox.Close()
+	outbox.Publish(ctx, stopEvent(1))
+	partial := []byte(`{"event":"stop_tra`)
+
+	// A short write is cut off again.
+	outbox.mux.Lock()
+	n, _ := outbox.writer.Write(partial)
+	outbox.discard(n)
+	outbox.mux.Unlock()
+	outbox.Publish(ctx, stopEvent(2))
+
+	// When that fails as well, the next event goes to a new segment.
+	outbox.mux.Lock()
+	path := outbox.writer.Name()
+	outbox.writer.Close()
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	n, _ = file.Write(partial)
+	file.Close()
+	outbox.discard(n)
+	outbox.mux.Unlock()
+	if err := outbox.Publish(ctx, stopEvent(3)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	inner.fail.Store(false)
+	for outbox.pending > 0 {
+		if err := outbox.replayNext(ctx); err != nil {
+			t.Fatalf("replayNext() error = %v", err)
+		}
+	}
+	events := inner.published()
+	if len(events) != 3 || events[1] != `{"charger":"","transaction_id":2,"reason":"","meter_stop":0}` {
+		t.Errorf("events = %v, want 1, 2, 3 without the partial lines", events)
+	}
+}
+
+func TestOutbox_Cap(t *testing.T) {
+	inner := &fakeSink{}
+	inner.fail.Store(true)
+	outbox, _ := NewOutbox(t.TempDir(), 150, inner, zap.NewNop())
+	defer outbox.Close()
+	var err error
+	for n := 0; n < 10 && err == nil; n++ {
+		err = outbox.Publish(context.Background(), stopEvent(n))
+	}
+	if !errors.Is(err, ErrOutboxFull) {
+		t.Errorf("Publish() error = %v, want ErrOutboxFull", err)
+	}
+}
+
+func TestOutbox_Rejected(t *testing.T) {
+	ctx := context.Background()
+	inner := &fakeSink{}
+	inner.reject.Store(true)
+	outbox, _ := NewOutbox(t.TempDir(), 1<<20, inner, zap.NewNop())
+	defer outbox.Close()
+	if err := outbox.Publish(ctx, stopEvent(1)); !errors.Is(err, ErrRejected) {
+		t.Errorf("Publish() error = %v, want ErrRejected", err)
+	}
+	if outbox.pending != 0 {
+		t.Errorf("pending = %d, want a rejected event not queued", outbox.pending)
+	}
+
+	// Queued while the sink was down, rejected on replay: dropped, not retried.
+	inner.reject.Store(false)
+	inner.fail.Store(true)
+	outbox.Publish(ctx, stopEvent(2))
+	inner.fail.Store(false)
+	inner.reject.Store(true)
+	if err := outbox.replayNext(ctx); err != nil {
+		t.Errorf("replayNext() error = %v, want the rejected event dropped", err)
+	}
+	if outbox.pending != 0 {
+		t.Errorf("pending = %d after replay, want 0", outbox.pending)
+	}
+}
+
+func TestOutbox_CapFreedByReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner := &fakeSink{}
+	inner.fail.Store(true)
+	outbox, _ := NewOutbox(dir, 1000, inner, zap.NewNop())
+	queued := 0
+	for ; queued < 20; queued++ {
+		if err := outbox.Publish(ctx, stopEvent(queued)); err != nil {
+			if !errors.Is(err, ErrOutboxFull) {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			break
+		}
+	}
+	if queued < 2 || queued == 20 {
+		t.Fatalf("queued %d events, want the cap to stop a few", queued)
+	}
+
+	// Replay one, restart: only the unread part counts.
+	inner.fail.Store(false)
+	if err := outbox.replayNext(ctx); err != nil {
+		t.Fatalf("replayNext() error = %v", err)
+	}
+	unread := outbox.bytes
+	outbox.Close()
+	outbox, _ = NewOutbox(dir, 1000, inner, zap.NewNop())
+	defer outbox.Close()
+	if outbox.bytes != unread {
+		t.Errorf("bytes after restart = %d, want the %d unread", outbox.bytes, unread)
+	}
+
+	for n := 1; n < queued; n++ {
+		if err := outbox.replayNext(ctx); err != nil {
+			t.Fatalf("replayNext() error = %v", err)
+		}
+	}
+	if outbox.bytes != 0 || outbox.pending != 0 {
+		t.Errorf("bytes = %d, pending = %d after replay, want 0", outbox.bytes, outbox.pending)
+	}
+	// The sink fails again: the drained outbox has room for as much as before.
+	inner.fail.Store(true)
+	for n := 0; n < queued; n++ {
+		if err := outbox.Publish(ctx, stopEvent(n)); err != nil {
+			t.Fatalf("Publish() #%d after replay error = %v", n, err)
+		}
+	}
+}
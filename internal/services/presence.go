@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const presenceKey = "chargers"
+
+// PresenceTTL is how long an entry survives without a refresh, so chargers
+// of a crashed instance drop out of the listing.
+const PresenceTTL = time.Minute
+
+type PresenceService interface {
+	Register(context.Context, *domain.ChargerPresence) error
+	// Unregister removes the entry of a connection; one a newer connection
+	// of the charger registered, here or elsewhere, stays.
+	Unregister(ctx context.Context, cpID, sessionID string) error
+	List(context.Context, domain.ChargerFilter) ([]domain.ChargerPresence, error)
+}
+
+type presenceService struct {
+	rdb redis.UniversalClient
+}
+
+func NewPresenceService(rdb redis.UniversalClient) PresenceService {
+	return &presenceService{rdb: rdb}
+}
+
+func (p *presenceService) Register(ctx context.Context, presence *domain.ChargerPresence) error {
+	presence.RefreshedAt = time.Now()
+	payload, err := json.Marshal(presence)
+	if err != nil {
+		return err
+	}
+	return p.rdb.HSet(ctx, presenceKey, presence.CpID, payload).Err()
+}
+
+// unregisterScript deletes the entry only if it still belongs to the
+// connection, since the charger may already have reconnected.
+var unregisterScript = redis.NewScript(`
+local current = redis.call("HGET", KEYS[1], ARGV[1])
+if current and cjson.decode(current).session_id == ARGV[2] then
+	return redis.call("HDEL", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+func (p *presenceService) Unregister(ctx context.Context, cpID, sessionID string) error {
+	return unregisterScript.Run(ctx, p.rdb, []string{presenceKey}, cpID, sessionID).Err()
+}
+
+func (p *presenceService) List(ctx context.Context, filter domain.ChargerFilter) ([]domain.ChargerPresence, error) {
+	entries, err := p.rdb.HGetAll(ctx, presenceKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	chargers := make([]domain.ChargerPresence, 0, len(entries))
+	var stale []string
+	for cpID, payload := range entries {
+		var presence domain.ChargerPresence
+		if err := json.Unmarshal([]byte(payload), &presence); err != nil {
+			stale = append(stale, cpID)
+			continue
+		}
+		if time.Since(presence.RefreshedAt) > PresenceTTL {
+			stale = append(stale, cpID)
+			continue
+		}
+		if filter.Match(&presence) {
+			chargers = append(chargers, presence)
+		}
+	}
+	if len(stale) > 0 {
+		p.rdb.HDel(ctx, presenceKey, stale...)
+	}
+	sort.Slice(chargers, func(i, j int) bool { return chargers[i].CpID < chargers[j].CpID })
+	return chargers, nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+func TestPresenceService(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+
+	service := NewPresenceService(rdb)
+	for _, presence := range []*domain.ChargerPresence{
+		{CpID: "a:cp-2", Domain: "a", Version: "ocpp2.0.1", InstanceID: "one", SessionID: "s1", ConnectedAt: time.Now()},
+		{CpID: "a:cp-1", Domain: "a", Version: "ocpp1.6", InstanceID: "one", SessionID: "s1", ConnectedAt: time.Now()},
+	} {
+		if err := service.Register(ctx, presence); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	chargers, err := service.List(ctx, domain.ChargerFilter{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(chargers) != 2 || chargers[0].CpID != "a:cp-1" {
+		t.Errorf("List() = %+v, want both chargers sorted", chargers)
+	}
+
+	chargers, _ = service.List(ctx, domain.ChargerFilter{Version: "ocpp2.0.1"})
+	if len(chargers) != 1 || chargers[0].CpID != "a:cp-2" {
+		t.Errorf("List(version) = %+v", chargers)
+	}
+
+	// An earlier connection must not remove the entry of the current one.
+	service.Unregister(ctx, "a:cp-1", "s0")
+	chargers, _ = service.List(ctx, domain.ChargerFilter{CpID: "a:cp-1"})
+	if len(chargers) != 1 {
+		t.Errorf("entry removed by an earlier connection")
+	}
+
+	service.Unregister(ctx, "a:cp-1", "s1")
+	chargers, _ = service.List(ctx, domain.ChargerFilter{CpID: "a:cp-1"})
+	if len(chargers) != 0 {
+		t.Errorf("entry still listed after Unregister()")
+	}
+}
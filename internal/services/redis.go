@@ -2,29 +2,35 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/JscorpTech/ocpp/internal/domain"
 	"github.com/redis/go-redis/v9"
-	"go.uber.org/zap"
 )
 
-type EventService interface {
-	SendEvent(context.Context, *redis.Client, *domain.Event, *zap.Logger)
+const EventsKey = "events"
+
+// redisSink pushes events to the events list.
+type redisSink struct {
+	rdb    redis.UniversalClient
+	format Format
 }
 
-type eventService struct{}
+func NewRedisSink(rdb redis.UniversalClient, format Format) Sink {
+	return &redisSink{rdb: rdb, format: format}
+}
 
-func NewEventService() EventService {
-	return &eventService{}
+func (e *redisSink) Name() string {
+	return "redis"
 }
 
-func (e *eventService) SendEvent(ctx context.Context, rdb *redis.Client, event *domain.Event, log *zap.Logger) {
-	payload, err := json.Marshal(event)
+func (e *redisSink) Publish(ctx context.Context, event *domain.Event) error {
+	payload, err := e.format.Encode(event)
 	if err != nil {
-		log.Error("Event encode error", zap.Error(err))
-	}
-	if _, err = rdb.RPush(ctx, "events", payload).Result(); err != nil {
-		log.Error("redis error", zap.Error(err))
+		return err
 	}
+	return sinkError(e, event, e.rdb.RPush(ctx, EventsKey, payload).Err())
+}
+
+func (e *redisSink) Backlog(ctx context.Context, limit int64) (int64, error) {
+	return e.rdb.LLen(ctx, EventsKey).Result()
 }
@@ -6,10 +6,19 @@ import (
 	"testing"
 
 	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
+// newTestRedis starts an in-memory Redis for one test.
+func newTestRedis(t *testing.T) redis.UniversalClient {
+	t.Helper()
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
 func TestNewEventService(t *testing.T) {
 	service := NewEventService()
 	if service == nil {
@@ -18,14 +27,11 @@ func TestNewEventService(t *testing.T) {
 }
 
 func TestEventService_SendEvent(t *testing.T) {
-	// Setup mock Redis client (miniredis yoki test uchun)
 	ctx := context.Background()
-	rdb := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
+	rdb := newTestRedis(t)
 
 	logger, _ := zap.NewDevelopment()
-	service := NewEventService()
+	service := NewEventService(Route{Sink: NewRedisSink(rdb, FormatNative)})
 
 	event := domain.Event{
 		Event: domain.HealthEvent,
@@ -34,39 +40,30 @@ func TestEventService_SendEvent(t *testing.T) {
 		},
 	}
 
-	// Clear any existing events
-	rdb.Del(ctx, "events")
-
 	// Test event sending
-	service.SendEvent(ctx, rdb, &event, logger)
+	service.SendEvent(ctx, &event, logger)
 
 	// Verify event was pushed to Redis
 	result, err := rdb.RPop(ctx, "events").Result()
-	if err != nil && err != redis.Nil {
-		// Redis mavjud bo'lmasa test o'tkazib yuboramiz
-		t.Skip("Redis not available for testing")
-		return
+	if err != nil {
+		t.Fatalf("RPop() error = %v", err)
 	}
 
-	if err == nil {
-		var receivedEvent domain.Event
-		if err := json.Unmarshal([]byte(result), &receivedEvent); err != nil {
-			t.Errorf("Failed to unmarshal event: %v", err)
-		}
+	var receivedEvent domain.Event
+	if err := json.Unmarshal([]byte(result), &receivedEvent); err != nil {
+		t.Errorf("Failed to unmarshal event: %v", err)
+	}
 
-		if receivedEvent.Event != event.Event {
-			t.Errorf("Event type = %v, want %v", receivedEvent.Event, event.Event)
-		}
+	if receivedEvent.Event != event.Event {
+		t.Errorf("Event type = %v, want %v", receivedEvent.Event, event.Event)
 	}
 }
 
 func TestEventService_SendEvent_AllEventTypes(t *testing.T) {
 	ctx := context.Background()
-	rdb := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
+	rdb := newTestRedis(t)
 	logger, _ := zap.NewDevelopment()
-	service := NewEventService()
+	service := NewEventService(Route{Sink: NewRedisSink(rdb, FormatNative)})
 
 	tests := []struct {
 		name  string
@@ -120,12 +117,18 @@ func TestEventService_SendEvent_AllEventTypes(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service.SendEvent(ctx, rdb, &tt.event, logger)
+			service.SendEvent(ctx, &tt.event, logger)
 
-			// Redis test bo'lsa, event mavjudligini tekshiramiz
-			_, err := rdb.LLen(ctx, "events").Result()
-			if err != nil && err != redis.Nil {
-				t.Skip("Redis not available for testing")
+			result, err := rdb.RPop(ctx, "events").Result()
+			if err != nil {
+				t.Fatalf("RPop() error = %v", err)
+			}
+			var receivedEvent domain.Event
+			if err := json.Unmarshal([]byte(result), &receivedEvent); err != nil {
+				t.Fatalf("Failed to unmarshal event: %v", err)
+			}
+			if receivedEvent.Event != tt.event.Event {
+				t.Errorf("Event type = %v, want %v", receivedEvent.Event, tt.event.Event)
 			}
 		})
 	}
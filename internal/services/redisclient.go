@@ -0,0 +1,81 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// NewRedisClient connects to a single Redis at REDIS_ADDR, to the master
+// Sentinel names when REDIS_SENTINEL_MASTER is set, or to Redis Cluster when
+// REDIS_CLUSTER_ADDRS is. It fails only on a bad TLS certificate; the
+// connections are made on first use.
+func NewRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
+	opts := &redis.UniversalOptions{
+		Addrs:            []string{cfg.RedisAddr},
+		DB:               int(cfg.RedisDB),
+		Username:         cfg.RedisUsername,
+		Password:         cfg.RedisPassword,
+		MasterName:       cfg.RedisSentinelMaster,
+		SentinelUsername: cfg.RedisSentinelUsername,
+		SentinelPassword: cfg.RedisSentinelPassword,
+		PoolSize:         int(cfg.RedisPoolSize),
+		MinIdleConns:     int(cfg.RedisMinIdleConns),
+		PoolTimeout:      cfg.RedisPoolTimeout,
+		DialTimeout:      cfg.RedisDialTimeout,
+		ReadTimeout:      cfg.RedisReadTimeout,
+		WriteTimeout:     cfg.RedisWriteTimeout,
+	}
+	if cfg.RedisTLS {
+		tlsConfig, err := redisTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+	switch {
+	case cfg.RedisSentinelMaster != "":
+		opts.Addrs = cfg.RedisSentinelAddrs
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case len(cfg.RedisClusterAddrs) > 0:
+		opts.Addrs = cfg.RedisClusterAddrs
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return redis.NewClient(opts.Simple()), nil
+	}
+}
+
+// redisTLSConfig trusts the system roots plus REDIS_TLS_CA_FILE and presents
+// the client certificate when one is set.
+func redisTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.RedisTLSServerName,
+	}
+	if cfg.RedisTLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.RedisTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis CA: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("redis CA: no certificate in %s", cfg.RedisTLSCAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.RedisTLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.RedisTLSCertFile, cfg.RedisTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
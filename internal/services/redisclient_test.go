@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// selfSigned writes a certificate for 127.0.0.1 to dir and returns it with
+// the path of its PEM file, which doubles as the CA.
+func selfSigned(t *testing.T, dir string) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, path
+}
+
+func TestNewRedisClient_TLS(t *testing.T) {
+	cert, caFile := selfSigned(t, t.TempDir())
+	mini := miniredis.NewMiniRedis()
+	if err := mini.StartTLS(&tls.Config{Certificates: []tls.Certificate{cert}}); err != nil {
+		t.Fatal(err)
+	}
+	defer mini.Close()
+	mini.RequireUserAuth("ocpp", "secret")
+
+	rdb, err := NewRedisClient(&config.Config{
+		RedisAddr:      mini.Addr(),
+		RedisDB:        3,
+		RedisUsername:  "ocpp",
+		RedisPassword:  "secret",
+		RedisTLS:       true,
+		RedisTLSCAFile: caFile,
+	})
+	if err != nil {
+		t.Fatalf("NewRedisClient() error = %v", err)
+	}
+	defer rdb.Close()
+	if err := rdb.Set(context.Background(), "k", "v", 0).Err(); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got, _ := mini.DB(3).Get("k"); got != "v" {
+		t.Errorf("DB 3 k = %q, want v", got)
+	}
+
+	// Without the CA the self-signed certificate is refused.
+	rdb, _ = NewRedisClient(&config.Config{RedisAddr: mini.Addr(), RedisTLS: true})
+	defer rdb.Close()
+	if err := rdb.Ping(context.Background()).Err(); err == nil {
+		t.Error("Ping() trusted an unknown CA")
+	}
+}
+
+func TestNewRedisClient_Modes(t *testing.T) {
+	rdb, _ := NewRedisClient(&config.Config{RedisSentinelMaster: "mymaster", RedisSentinelAddrs: []string{"127.0.0.1:26379"}})
+	defer rdb.Close()
+	if _, ok := rdb.(*redis.Client); !ok {
+		t.Errorf("sentinel client = %T, want a failover *redis.Client", rdb)
+	}
+	rdb, _ = NewRedisClient(&config.Config{RedisClusterAddrs: []string{"127.0.0.1:7000"}, RedisPoolSize: 5})
+	defer rdb.Close()
+	cluster, ok := rdb.(*redis.ClusterClient)
+	if !ok {
+		t.Fatalf("cluster client = %T, want *redis.ClusterClient", rdb)
+	}
+	if cluster.Options().PoolSize != 5 {
+		t.Errorf("PoolSize = %d, want 5", cluster.Options().PoolSize)
+	}
+
+	if _, err := NewRedisClient(&config.Config{RedisTLS: true, RedisTLSCAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("NewRedisClient() accepted a missing CA file")
+	}
+}
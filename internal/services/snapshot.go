@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const snapshotKey = "configuration:snapshots"
+
+// SnapshotService keeps the last known configuration of every charger.
+// Entries outlive the connection, so they can be read while a charger is
+// offline.
+type SnapshotService interface {
+	// Record merges what a charger answered to GetConfiguration: the keys it
+	// reported and the ones it does not know. With replace the answer covers
+	// every key and the old snapshot is dropped.
+	Record(ctx context.Context, cpID string, keys []domain.ConfigurationKey, unknown []string, replace bool) error
+	// Changed records a value the charger accepted in ChangeConfiguration.
+	Changed(ctx context.Context, cpID, key, value string, rebootRequired bool) error
+	// Get returns nil for a charger without a snapshot.
+	Get(ctx context.Context, cpID string) (*domain.ConfigurationSnapshot, error)
+}
+
+// snapshotService merges under a lock: only the instance a charger is
+// connected to sends it commands, so that is the only writer.
+type snapshotService struct {
+	rdb redis.UniversalClient
+	mux sync.Mutex
+}
+
+func NewSnapshotService(rdb redis.UniversalClient) SnapshotService {
+	return &snapshotService{rdb: rdb}
+}
+
+func (s *snapshotService) Record(ctx context.Context, cpID string, keys []domain.ConfigurationKey, unknown []string, replace bool) error {
+	return s.update(ctx, cpID, replace, func(snapshot *domain.ConfigurationSnapshot, now time.Time) {
+		for _, key := range keys {
+			setKey(snapshot, domain.SnapshotKey{Key: key.Key, Value: key.Value, Readonly: key.Readonly, UpdatedAt: now})
+		}
+		for _, key := range unknown {
+			snapshot.ConfigurationKey = slices.DeleteFunc(snapshot.ConfigurationKey, func(k domain.SnapshotKey) bool { return k.Key == key })
+			if !slices.Contains(snapshot.UnknownKey, key) {
+				snapshot.UnknownKey = append(snapshot.UnknownKey, key)
+			}
+		}
+	})
+}
+
+func (s *snapshotService) Changed(ctx context.Context, cpID, key, value string, rebootRequired bool) error {
+	return s.update(ctx, cpID, false, func(snapshot *domain.ConfigurationSnapshot, now time.Time) {
+		changed := domain.SnapshotKey{Key: key, Value: value, RebootRequired: rebootRequired, UpdatedAt: now}
+		for _, k := range snapshot.ConfigurationKey {
+			if k.Key == key {
+				changed.Readonly = k.Readonly
+			}
+		}
+		setKey(snapshot, changed)
+	})
+}
+
+func (s *snapshotService) update(ctx context.Context, cpID string, replace bool, apply func(*domain.ConfigurationSnapshot, time.Time)) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	snapshot := &domain.ConfigurationSnapshot{CpID: cpID}
+	if !replace {
+		current, err := s.Get(ctx, cpID)
+		if err != nil {
+			return err
+		}
+		if current != nil {
+			snapshot = current
+		}
+	}
+	snapshot.UpdatedAt = time.Now()
+	apply(snapshot, snapshot.UpdatedAt)
+	slices.SortFunc(snapshot.ConfigurationKey, func(a, b domain.SnapshotKey) int { return strings.Compare(a.Key, b.Key) })
+	slices.Sort(snapshot.UnknownKey)
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return s.rdb.HSet(ctx, snapshotKey, cpID, payload).Err()
+}
+
+// setKey adds or replaces a key, which the charger then evidently knows.
+func setKey(snapshot *domain.ConfigurationSnapshot, key domain.SnapshotKey) {
+	snapshot.UnknownKey = slices.DeleteFunc(snapshot.UnknownKey, func(k string) bool { return k == key.Key })
+	for i := range snapshot.ConfigurationKey {
+		if snapshot.ConfigurationKey[i].Key == key.Key {
+			snapshot.ConfigurationKey[i] = key
+			return
+		}
+	}
+	snapshot.ConfigurationKey = append(snapshot.ConfigurationKey, key)
+}
+
+func (s *snapshotService) Get(ctx context.Context, cpID string) (*domain.ConfigurationSnapshot, error) {
+	payload, err := s.rdb.HGet(ctx, snapshotKey, cpID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot domain.ConfigurationSnapshot
+	if err := json.Unmarshal([]byte(payload), &snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.ConfigurationKey == nil {
+		snapshot.ConfigurationKey = []domain.SnapshotKey{}
+	}
+	if snapshot.UnknownKey == nil {
+		snapshot.UnknownKey = []string{}
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+func TestSnapshotService(t *testing.T) {
+	ctx := context.Background()
+	service := NewSnapshotService(newTestRedis(t))
+
+	if snapshot, err := service.Get(ctx, "a:cp-1"); snapshot != nil || err != nil {
+		t.Fatalf("Get() = %v, %v, want nothing before a read", snapshot, err)
+	}
+	err := service.Record(ctx, "a:cp-1", []domain.ConfigurationKey{
+		{Key: "NumberOfConnectors", Value: "2", Readonly: true},
+		{Key: "HeartbeatInterval", Value: "60"},
+	}, []string{"Foo"}, true)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	// A read of some keys merges into the snapshot.
+	service.Record(ctx, "a:cp-1", []domain.ConfigurationKey{{Key: "Foo", Value: "1"}}, []string{"Bar"}, false)
+	if err := service.Changed(ctx, "a:cp-1", "HeartbeatInterval", "300", true); err != nil {
+		t.Fatalf("Changed() error = %v", err)
+	}
+
+	snapshot, err := service.Get(ctx, "a:cp-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	keys := snapshot.ConfigurationKey
+	if len(keys) != 3 || keys[0].Key != "Foo" || keys[2].Key != "NumberOfConnectors" || !keys[2].Readonly {
+		t.Fatalf("keys = %+v, want Foo, HeartbeatInterval and NumberOfConnectors sorted", keys)
+	}
+	if keys[1].Value != "300" || !keys[1].RebootRequired || keys[1].UpdatedAt.IsZero() {
+		t.Errorf("HeartbeatInterval = %+v, want the changed value pending a reboot", keys[1])
+	}
+	if !slices.Equal(snapshot.UnknownKey, []string{"Bar"}) {
+		t.Errorf("UnknownKey = %v, want Foo dropped once it was read", snapshot.UnknownKey)
+	}
+
+	service.Record(ctx, "a:cp-1", []domain.ConfigurationKey{{Key: "HeartbeatInterval", Value: "300"}}, nil, true)
+	snapshot, _ = service.Get(ctx, "a:cp-1")
+	if len(snapshot.ConfigurationKey) != 1 || snapshot.ConfigurationKey[0].RebootRequired || len(snapshot.UnknownKey) != 0 {
+		t.Errorf("snapshot = %+v, want a full read to replace it", snapshot)
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// streamSink publishes events to a Redis Stream under IDs Redis assigns; the
+// event ID is in the payload. Consumers read through a consumer group,
+// acknowledge with XACK and reclaim events of crashed consumers with
+// XAUTOCLAIM.
+type streamSink struct {
+	rdb    redis.UniversalClient
+	stream string
+	maxLen int64
+	group  string
+	format Format
+	// groupReady is set once the consumer group exists, so events published
+	// before the backend first reads are kept for it.
+	groupReady atomic.Bool
+}
+
+// NewStreamSink trims the stream to about maxLen entries; 0 keeps
+// everything.
+func NewStreamSink(rdb redis.UniversalClient, stream string, maxLen int64, group string, format Format) Sink {
+	return &streamSink{
+		rdb:    rdb,
+		stream: stream,
+		maxLen: maxLen,
+		group:  group,
+		format: format,
+	}
+}
+
+func (e *streamSink) ensureGroup(ctx context.Context) error {
+	if e.group == "" || e.groupReady.Load() {
+		return nil
+	}
+	err := e.rdb.XGroupCreateMkStream(ctx, e.stream, e.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	e.groupReady.Store(true)
+	return nil
+}
+
+func (e *streamSink) Name() string {
+	return "redis"
+}
+
+func (e *streamSink) Publish(ctx context.Context, event *domain.Event) error {
+	payload, err := e.format.Encode(event)
+	if err != nil {
+		return err
+	}
+	if err := e.ensureGroup(ctx); err != nil {
+		return sinkError(e, event, err)
+	}
+	err = e.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: e.stream,
+		MaxLen: e.maxLen,
+		Approx: true,
+		Values: map[string]any{
+			"event":   string(event.Event),
+			"payload": payload,
+		},
+	}).Err()
+	return sinkError(e, event, err)
+}
+
+// Backlog is what the consumer group has yet to read plus what it read but
+// did not acknowledge. Without a group it is the stream length.
+func (e *streamSink) Backlog(ctx context.Context, limit int64) (int64, error) {
+	if e.group == "" {
+		return e.rdb.XLen(ctx, e.stream).Result()
+	}
+	if err := e.ensureGroup(ctx); err != nil {
+		return 0, err
+	}
+	groups, err := e.rdb.XInfoGroups(ctx, e.stream).Result()
+	if err != nil {
+		return 0, err
+	}
+	for _, group := range groups {
+		if group.Name != e.group {
+			continue
+		}
+		if group.EntriesRead > 0 {
+			return group.Lag + group.Pending, nil
+		}
+		// Redis cannot tell the lag (before 7.0, or after the stream was
+		// trimmed past what the group read), so count what is left up to
+		// limit, or take the stream length as the bound.
+		if limit <= 0 {
+			length, err := e.rdb.XLen(ctx, e.stream).Result()
+			return length + group.Pending, err
+		}
+		undelivered, err := e.rdb.XRangeN(ctx, e.stream, "("+group.LastDeliveredID, "+", limit).Result()
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(undelivered)) + group.Pending, nil
+	}
+	return 0, nil
+}
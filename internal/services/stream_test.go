@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestStreamSink(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	const stream = "test:events:stream"
+
+	service := NewEventService(Route{Sink: NewStreamSink(rdb, stream, 100, "backend", FormatNative)})
+	for _, charger := range []string{"cp-1", "cp-2"} {
+		service.SendEvent(ctx, &domain.Event{
+			Event: domain.HealthEvent,
+			Data:  domain.Healthcheck{Charger: charger},
+		}, zap.NewNop())
+	}
+	if backlog, err := service.Backlog(ctx, 0); err != nil || backlog != 2 {
+		t.Fatalf("Backlog() = %d, %v, want 2", backlog, err)
+	}
+	// Before the group reads Redis has no lag, and counting stops at limit.
+	if backlog, _ := service.Backlog(ctx, 1); backlog != 1 {
+		t.Errorf("Backlog(1) = %d, want the count cut at 1", backlog)
+	}
+
+	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "backend",
+		Consumer: "worker-1",
+		Streams:  []string{stream, ">"},
+		Count:    10,
+	}).Result()
+	if err != nil {
+		t.Fatalf("XReadGroup() error = %v", err)
+	}
+	messages := streams[0].Messages
+	if len(messages) != 2 || messages[0].Values["event"] != string(domain.HealthEvent) {
+		t.Fatalf("messages = %+v", messages)
+	}
+	var event domain.Event
+	if err := json.Unmarshal([]byte(messages[0].Values["payload"].(string)), &event); err != nil {
+		t.Fatalf("payload error = %v", err)
+	}
+	if event.Event != domain.HealthEvent {
+		t.Errorf("Event = %v, want %v", event.Event, domain.HealthEvent)
+	}
+
+	// Read but unacknowledged events still count.
+	if backlog, _ := service.Backlog(ctx, 10); backlog != 2 {
+		t.Errorf("Backlog() after read = %d, want 2", backlog)
+	}
+	rdb.XAck(ctx, stream, "backend", messages[0].ID)
+	if backlog, _ := service.Backlog(ctx, 10); backlog != 1 {
+		t.Errorf("Backlog() after ack = %d, want 1", backlog)
+	}
+}
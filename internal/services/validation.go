@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// EventValidator checks an event against the schema of its type.
+type EventValidator interface {
+	ValidateEvent(event *domain.Event) error
+}
+
+// validationService checks every event before inner publishes it. In strict
+// mode an invalid event is dropped with the error; otherwise it is logged
+// and published anyway.
+type validationService struct {
+	EventService
+	validator EventValidator
+	strict    bool
+	log       *zap.Logger
+}
+
+func WithValidation(inner EventService, validator EventValidator, strict bool, log *zap.Logger) EventService {
+	return &validationService{EventService: inner, validator: validator, strict: strict, log: log}
+}
+
+func (v *validationService) Publish(ctx context.Context, event *domain.Event) error {
+	if err := v.validator.ValidateEvent(event); err != nil {
+		metrics.EventSchemaViolations.WithLabelValues(string(event.Event)).Inc()
+		if v.strict {
+			return err
+		}
+		v.log.Warn("Event does not match its schema", zap.String("event", string(event.Event)), zap.String("id", event.ID), zap.Error(err))
+	}
+	return v.EventService.Publish(ctx, event)
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"github.com/JscorpTech/ocpp/internal/schema"
+	"go.uber.org/zap"
+)
+
+func TestWithValidation(t *testing.T) {
+	registry, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	valid := func() *domain.Event {
+		return &domain.Event{
+			Event:  domain.StopTransactionEvent,
+			Domain: "example.com",
+			CpID:   "cp-1",
+			Data:   domain.StopTransaction{Charger: "cp-1", TransactionId: 7},
+		}
+	}
+	// Data of another event type.
+	invalid := func() *domain.Event {
+		event := valid()
+		event.Data = domain.ChargerOnline{Charger: "cp-1"}
+		return event
+	}
+
+	tests := []struct {
+		name    string
+		strict  bool
+		event   *domain.Event
+		wantErr bool
+		wantOut int
+	}{
+		{"valid", true, valid(), false, 1},
+		{"warn publishes anyway", false, invalid(), false, 1},
+		{"strict drops", true, invalid(), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &fakeSink{}
+			service := WithEnvelope(WithValidation(NewEventService(Route{Sink: sink}), registry, tt.strict, zap.NewNop()), newTestRedis(t), "ocpp-1", zap.NewNop())
+			err := service.Publish(context.Background(), tt.event)
+			var schemaErr *schema.Error
+			if tt.wantErr != (err != nil) || (tt.wantErr && !errors.As(err, &schemaErr)) {
+				t.Errorf("Publish() error = %v, want schema error %v", err, tt.wantErr)
+			}
+			if got := len(sink.published()); got != tt.wantOut {
+				t.Errorf("published %d events, want %d", got, tt.wantOut)
+			}
+		})
+	}
+}
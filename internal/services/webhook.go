@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	WebhookSignatureHeader = "X-OCPP-Signature"
+	WebhookTimestampHeader = "X-OCPP-Timestamp"
+	WebhookEventHeader     = "X-OCPP-Event"
+)
+
+type WebhookOptions struct {
+	URL string
+	// Secret signs every request; empty sends them unsigned.
+	Secret  string
+	Timeout time.Duration
+	Format  Format
+}
+
+// webhookSink POSTs every event as JSON, once: it runs in the charger's
+// request, so retries are left to the outbox. Statuses other than 429 and 5xx
+// are ErrRejected and not worth resending.
+type webhookSink struct {
+	opts   WebhookOptions
+	client *http.Client
+}
+
+func NewWebhookSink(opts WebhookOptions) Sink {
+	return &webhookSink{
+		opts: opts,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+// SignWebhook is the X-OCPP-Signature value of a request: HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the secret. Receivers should also reject
+// timestamps too far from their own clock.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (w *webhookSink) Publish(ctx context.Context, event *domain.Event) error {
+	body, err := w.opts.Format.Encode(event)
+	if err != nil {
+		return err
+	}
+	return sinkError(w, event, w.post(ctx, event, body))
+}
+
+func (w *webhookSink) post(ctx context.Context, event *domain.Event, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", w.opts.Format.ContentType())
+	req.Header.Set(WebhookEventHeader, string(event.Event))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if w.opts.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(w.opts.Secret, timestamp, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return fmt.Errorf("%w: webhook returned %s", ErrRejected, resp.Status)
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/domain"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var calls atomic.Int32
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(WebhookOptions{URL: server.URL, Secret: "s3cret", Timeout: time.Second})
+	if err := sink.Publish(context.Background(), stopEvent(1)); err == nil || errors.Is(err, ErrRejected) {
+		t.Fatalf("Publish() error = %v, want a retryable 503", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want no retry in Publish()", calls.Load())
+	}
+	if err := sink.Publish(context.Background(), stopEvent(1)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if header.Get(WebhookEventHeader) != string(domain.StopTransactionEvent) {
+		t.Errorf("%s = %q", WebhookEventHeader, header.Get(WebhookEventHeader))
+	}
+	want := SignWebhook("s3cret", header.Get(WebhookTimestampHeader), body)
+	if header.Get(WebhookSignatureHeader) != want {
+		t.Errorf("%s = %q, want %q", WebhookSignatureHeader, header.Get(WebhookSignatureHeader), want)
+	}
+	var event domain.Event
+	if err := json.Unmarshal(body, &event); err != nil || event.Event != domain.StopTransactionEvent {
+		t.Errorf("body = %s, %v", body, err)
+	}
+}
+
+func TestWebhookSink_RejectedOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(WebhookOptions{URL: server.URL, Timeout: time.Second})
+	if err := sink.Publish(context.Background(), stopEvent(1)); !errors.Is(err, ErrRejected) {
+		t.Errorf("Publish() error = %v, want ErrRejected after 400", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want one", calls.Load())
+	}
+}
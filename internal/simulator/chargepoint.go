@@ -0,0 +1,565 @@
+// Package simulator acts as OCPP 1.6J charge points against a central system,
+// for running the server end to end without hardware.
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"github.com/gorilla/websocket"
+	"github.com/voltbras/go-ocpp/messages/v1x/cpreq"
+	"github.com/voltbras/go-ocpp/messages/v1x/cpresp"
+	"github.com/voltbras/go-ocpp/messages/v1x/csreq"
+	"go.uber.org/zap"
+)
+
+var ErrClosed = errors.New("charge point connection closed")
+
+type Options struct {
+	// URL is the central system, e.g. ws://localhost:10800.
+	URL string
+	// ID is the charger path to connect as.
+	ID string
+	// Connectors is the number of connectors, numbered from 1.
+	Connectors int
+	// Timeout bounds the wait for each answer.
+	Timeout time.Duration
+	// MeterInterval is how often a remotely started transaction sends
+	// meter values; 0 sends none.
+	MeterInterval time.Duration
+	// MeterEnergy is the Wh added to the meter per sample.
+	MeterEnergy int
+	// Heartbeat overrides the interval the central system asks for at boot.
+	Heartbeat time.Duration
+	Vendor    string
+	Model     string
+	Log       *zap.Logger
+	// OnCall, if set, is told how long every CALL took to be answered.
+	OnCall func(action string, took time.Duration, err error)
+}
+
+// ChargePoint is one simulated charger. It answers RemoteStartTransaction,
+// RemoteStopTransaction, GetConfiguration, ChangeConfiguration and Reset from
+// the central system, and runs remotely started transactions by itself.
+type ChargePoint struct {
+	opts   Options
+	log    *zap.Logger
+	socket *websocket.Conn
+	nextID atomic.Int64
+
+	writeMu sync.Mutex
+
+	mux          sync.Mutex
+	pending      map[string]chan *ocpp.Frame
+	meters       map[int]int
+	transactions map[int]*transaction
+	config       map[string]string
+	heartbeat    *time.Ticker
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type transaction struct {
+	id  int32
+	tag string
+	// stop ends the meter sampling of a remotely started transaction.
+	stop chan struct{}
+}
+
+// Dial connects as opts.ID with the ocpp1.6 subprotocol.
+func Dial(ctx context.Context, opts Options) (*ChargePoint, error) {
+	if opts.Connectors <= 0 {
+		opts.Connectors = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.Vendor == "" {
+		opts.Vendor = "Simulator"
+	}
+	if opts.Model == "" {
+		opts.Model = "SIM-1"
+	}
+	if opts.Log == nil {
+		opts.Log = zap.NewNop()
+	}
+	dialer := websocket.Dialer{Subprotocols: []string{string(ocpp.V16)}, HandshakeTimeout: opts.Timeout}
+	socket, _, err := dialer.DialContext(ctx, strings.TrimRight(opts.URL, "/")+"/"+opts.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	cp := &ChargePoint{
+		opts:         opts,
+		log:          opts.Log.With(zap.String("cp_id", opts.ID)),
+		socket:       socket,
+		pending:      make(map[string]chan *ocpp.Frame),
+		meters:       make(map[int]int),
+		transactions: make(map[int]*transaction),
+		config: map[string]string{
+			"HeartbeatInterval":        "0",
+			"MeterValueSampleInterval": strconv.Itoa(int(opts.MeterInterval.Seconds())),
+			"NumberOfConnectors":       strconv.Itoa(opts.Connectors),
+		},
+		done: make(chan struct{}),
+	}
+	go cp.read()
+	return cp, nil
+}
+
+func (cp *ChargePoint) ID() string {
+	return cp.opts.ID
+}
+
+// Done is closed once the connection is gone.
+func (cp *ChargePoint) Done() <-chan struct{} {
+	return cp.done
+}
+
+func (cp *ChargePoint) Close() error {
+	cp.shutdown()
+	return cp.socket.Close()
+}
+
+func (cp *ChargePoint) shutdown() {
+	cp.closeOnce.Do(func() {
+		close(cp.done)
+		cp.mux.Lock()
+		defer cp.mux.Unlock()
+		if cp.heartbeat != nil {
+			cp.heartbeat.Stop()
+		}
+		for _, tx := range cp.transactions {
+			if tx.stop != nil {
+				close(tx.stop)
+				tx.stop = nil
+			}
+		}
+	})
+}
+
+// Call sends a CALL and decodes its CALLRESULT into resp. A CALLERROR comes
+// back as *ocpp.CallErr.
+func (cp *ChargePoint) Call(ctx context.Context, action string, req, resp any) error {
+	if cp.opts.OnCall == nil {
+		return cp.call(ctx, action, req, resp)
+	}
+	start := time.Now()
+	err := cp.call(ctx, action, req, resp)
+	cp.opts.OnCall(action, time.Since(start), err)
+	return err
+}
+
+func (cp *ChargePoint) call(ctx context.Context, action string, req, resp any) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	id := strconv.FormatInt(cp.nextID.Add(1), 10)
+	answer := make(chan *ocpp.Frame, 1)
+	cp.mux.Lock()
+	cp.pending[id] = answer
+	cp.mux.Unlock()
+	defer func() {
+		cp.mux.Lock()
+		delete(cp.pending, id)
+		cp.mux.Unlock()
+	}()
+	if err := cp.write(&ocpp.Frame{Type: ocpp.Call, ID: id, Action: action, Payload: payload}); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(cp.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case frame := <-answer:
+		if frame.Type == ocpp.CallError {
+			return &ocpp.CallErr{Code: frame.ErrorCode, Description: frame.ErrorDescription}
+		}
+		if resp == nil {
+			return nil
+		}
+		return json.Unmarshal(frame.Payload, resp)
+	case <-timer.C:
+		return errors.New(action + ": no answer within " + cp.opts.Timeout.String())
+	case <-cp.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Boot sends BootNotification and, once accepted, heartbeats at the interval
+// the central system asked for.
+func (cp *ChargePoint) Boot(ctx context.Context) (*cpresp.BootNotification, error) {
+	var resp cpresp.BootNotification
+	err := cp.Call(ctx, "BootNotification", &cpreq.BootNotification{
+		ChargePointVendor: cp.opts.Vendor,
+		ChargePointModel:  cp.opts.Model,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	cp.log.Info("Booted", zap.String("status", resp.Status), zap.Float64("interval", resp.Interval))
+	interval := time.Duration(resp.Interval) * time.Second
+	if cp.opts.Heartbeat > 0 {
+		interval = cp.opts.Heartbeat
+	}
+	if resp.Status == "Accepted" && interval > 0 {
+		cp.setHeartbeat(interval)
+	}
+	return &resp, nil
+}
+
+func (cp *ChargePoint) Heartbeat(ctx context.Context) error {
+	return cp.Call(ctx, "Heartbeat", &cpreq.Heartbeat{}, nil)
+}
+
+func (cp *ChargePoint) StatusNotification(ctx context.Context, connector int, status string) error {
+	now := time.Now().UTC()
+	err := cp.Call(ctx, "StatusNotification", &cpreq.StatusNotification{
+		ConnectorId: connector,
+		ErrorCode:   "NoError",
+		Status:      status,
+		Timestamp:   &now,
+	}, nil)
+	if err == nil {
+		cp.log.Info("Status", zap.Int("connector", connector), zap.String("status", status))
+	}
+	return err
+}
+
+// Authorize returns the idTagInfo status, such as "Accepted".
+func (cp *ChargePoint) Authorize(ctx context.Context, tag string) (string, error) {
+	var resp cpresp.Authorize
+	if err := cp.Call(ctx, "Authorize", &cpreq.Authorize{IdTag: tag}, &resp); err != nil {
+		return "", err
+	}
+	if resp.IdTagInfo == nil {
+		return "", errors.New("Authorize: no idTagInfo")
+	}
+	cp.log.Info("Authorized", zap.String("tag", tag), zap.String("status", resp.IdTagInfo.Status))
+	return resp.IdTagInfo.Status, nil
+}
+
+// StartTransaction starts a transaction on connector at its current meter
+// reading and returns the transaction id.
+func (cp *ChargePoint) StartTransaction(ctx context.Context, connector int, tag string) (int32, error) {
+	cp.mux.Lock()
+	_, busy := cp.transactions[connector]
+	meter := cp.meters[connector]
+	cp.mux.Unlock()
+	if busy {
+		return 0, errors.New("connector " + strconv.Itoa(connector) + " already has a transaction")
+	}
+	var resp cpresp.StartTransaction
+	err := cp.Call(ctx, "StartTransaction", &cpreq.StartTransaction{
+		ConnectorId: connector,
+		IdTag:       tag,
+		MeterStart:  meter,
+		Timestamp:   time.Now().UTC(),
+	}, &resp)
+	if err != nil {
+		return 0, err
+	}
+	if resp.IdTagInfo != nil && resp.IdTagInfo.Status != "Accepted" {
+		return 0, errors.New("StartTransaction: tag " + resp.IdTagInfo.Status)
+	}
+	cp.mux.Lock()
+	cp.transactions[connector] = &transaction{id: resp.TransactionId, tag: tag}
+	cp.mux.Unlock()
+	cp.log.Info("Transaction started", zap.Int("connector", connector), zap.Int32("transaction_id", resp.TransactionId))
+	return resp.TransactionId, nil
+}
+
+// MeterValues adds energy Wh to the meter of connector and reports it, with
+// the transaction id while one is running.
+func (cp *ChargePoint) MeterValues(ctx context.Context, connector int, energy int) error {
+	cp.mux.Lock()
+	cp.meters[connector] += energy
+	meter := cp.meters[connector]
+	var transactionID int32
+	if tx, ok := cp.transactions[connector]; ok {
+		transactionID = tx.id
+	}
+	cp.mux.Unlock()
+	return cp.Call(ctx, "MeterValues", &cpreq.MeterValues{
+		ConnectorId:   connector,
+		TransactionId: transactionID,
+		MeterValue: []*cpreq.MeterValueItems{{
+			Timestamp: time.Now().UTC(),
+			SampledValues: []*cpreq.SampledValue{{
+				Value:     strconv.Itoa(meter),
+				Measurand: "Energy.Active.Import.Register",
+				Unit:      "Wh",
+			}},
+		}},
+	}, nil)
+}
+
+// StopTransaction stops the transaction on connector at its current meter
+// reading.
+func (cp *ChargePoint) StopTransaction(ctx context.Context, connector int, reason string) error {
+	cp.mux.Lock()
+	tx, ok := cp.transactions[connector]
+	meter := cp.meters[connector]
+	if ok {
+		delete(cp.transactions, connector)
+		if tx.stop != nil {
+			close(tx.stop)
+			tx.stop = nil
+		}
+	}
+	cp.mux.Unlock()
+	if !ok {
+		return errors.New("connector " + strconv.Itoa(connector) + " has no transaction")
+	}
+	err := cp.Call(ctx, "StopTransaction", &cpreq.StopTransaction{
+		TransactionId: int(tx.id),
+		IdTag:         tx.tag,
+		MeterStop:     meter,
+		Reason:        reason,
+		Timestamp:     time.Now().UTC(),
+	}, nil)
+	if err == nil {
+		cp.log.Info("Transaction stopped", zap.Int("connector", connector), zap.Int32("transaction_id", tx.id), zap.String("reason", reason))
+	}
+	return err
+}
+
+func (cp *ChargePoint) setHeartbeat(interval time.Duration) {
+	cp.mux.Lock()
+	defer cp.mux.Unlock()
+	cp.config["HeartbeatInterval"] = strconv.Itoa(int(interval.Seconds()))
+	if cp.heartbeat != nil {
+		cp.heartbeat.Reset(interval)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	cp.heartbeat = ticker
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := cp.Heartbeat(context.Background()); err != nil {
+					cp.log.Warn("Heartbeat failed", zap.Error(err))
+				}
+			case <-cp.done:
+				return
+			}
+		}
+	}()
+}
+
+func (cp *ChargePoint) write(frame *ocpp.Frame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	cp.writeMu.Lock()
+	defer cp.writeMu.Unlock()
+	return cp.socket.WriteMessage(websocket.TextMessage, data)
+}
+
+func (cp *ChargePoint) read() {
+	defer cp.shutdown()
+	for {
+		_, data, err := cp.socket.ReadMessage()
+		if err != nil {
+			return
+		}
+		frame, err := ocpp.ParseFrame(data)
+		if err != nil {
+			cp.log.Warn("Invalid frame", zap.ByteString("raw", data), zap.Error(err))
+			continue
+		}
+		if frame.Type == ocpp.Call {
+			go cp.answer(frame)
+			continue
+		}
+		cp.mux.Lock()
+		answer, ok := cp.pending[frame.ID]
+		cp.mux.Unlock()
+		if ok {
+			select {
+			case answer <- frame:
+			default:
+			}
+		}
+	}
+}
+
+// answer replies to a central system CALL, then does what it asked for.
+func (cp *ChargePoint) answer(call *ocpp.Frame) {
+	cp.log.Info("Remote command", zap.String("action", call.Action), zap.ByteString("payload", call.Payload))
+	resp, then, err := cp.handle(call)
+	reply := &ocpp.Frame{Type: ocpp.CallResult, ID: call.ID}
+	if err == nil {
+		reply.Payload, err = json.Marshal(resp)
+	}
+	if err != nil {
+		var callErr *ocpp.CallErr
+		if !errors.As(err, &callErr) {
+			callErr = &ocpp.CallErr{Code: ocpp.FormationViolation, Description: err.Error()}
+		}
+		reply = &ocpp.Frame{Type: ocpp.CallError, ID: call.ID, ErrorCode: callErr.Code, ErrorDescription: callErr.Description}
+	}
+	if err := cp.write(reply); err != nil {
+		return
+	}
+	if then != nil {
+		then(context.Background())
+	}
+}
+
+type status struct {
+	Status string `json:"status"`
+}
+
+func (cp *ChargePoint) handle(call *ocpp.Frame) (any, func(context.Context), error) {
+	switch call.Action {
+	case "RemoteStartTransaction":
+		var req csreq.RemoteStartTransaction
+		if err := json.Unmarshal(call.Payload, &req); err != nil {
+			return nil, nil, err
+		}
+		connector := max(int(req.ConnectorId), 1)
+		cp.mux.Lock()
+		_, busy := cp.transactions[connector]
+		cp.mux.Unlock()
+		if busy || connector > cp.opts.Connectors {
+			return status{"Rejected"}, nil, nil
+		}
+		return status{"Accepted"}, func(ctx context.Context) { cp.remoteStart(ctx, connector, req.IdTag) }, nil
+	case "RemoteStopTransaction":
+		var req csreq.RemoteStopTransaction
+		if err := json.Unmarshal(call.Payload, &req); err != nil {
+			return nil, nil, err
+		}
+		connector, ok := cp.connectorOf(req.TransactionId)
+		if !ok {
+			return status{"Rejected"}, nil, nil
+		}
+		return status{"Accepted"}, func(ctx context.Context) { cp.remoteStop(ctx, connector) }, nil
+	case "GetConfiguration":
+		var req csreq.GetConfiguration
+		if err := json.Unmarshal(call.Payload, &req); err != nil {
+			return nil, nil, err
+		}
+		return cp.configuration(req.Key), nil, nil
+	case "ChangeConfiguration":
+		var req csreq.ChangeConfiguration
+		if err := json.Unmarshal(call.Payload, &req); err != nil {
+			return nil, nil, err
+		}
+		if req.Key == "HeartbeatInterval" {
+			seconds, err := strconv.Atoi(req.Value)
+			if err != nil || seconds <= 0 {
+				return status{"Rejected"}, nil, nil
+			}
+			cp.setHeartbeat(time.Duration(seconds) * time.Second)
+			return status{"Accepted"}, nil, nil
+		}
+		cp.mux.Lock()
+		cp.config[req.Key] = req.Value
+		cp.mux.Unlock()
+		return status{"Accepted"}, nil, nil
+	case "Reset":
+		return status{"Accepted"}, nil, nil
+	}
+	return nil, nil, &ocpp.CallErr{Code: ocpp.NotImplemented, Description: call.Action + " is not simulated"}
+}
+
+type configurationKey struct {
+	Key      string `json:"key"`
+	Readonly bool   `json:"readonly"`
+	Value    string `json:"value"`
+}
+
+type configuration struct {
+	ConfigurationKey []configurationKey `json:"configurationKey"`
+	UnknownKey       []string           `json:"unknownKey,omitempty"`
+}
+
+func (cp *ChargePoint) configuration(keys []string) configuration {
+	cp.mux.Lock()
+	defer cp.mux.Unlock()
+	result := configuration{ConfigurationKey: []configurationKey{}}
+	if len(keys) == 0 {
+		for key := range cp.config {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		value, ok := cp.config[key]
+		if !ok {
+			result.UnknownKey = append(result.UnknownKey, key)
+			continue
+		}
+		result.ConfigurationKey = append(result.ConfigurationKey, configurationKey{Key: key, Value: value, Readonly: key == "NumberOfConnectors"})
+	}
+	return result
+}
+
+func (cp *ChargePoint) connectorOf(transactionID int32) (int, bool) {
+	cp.mux.Lock()
+	defer cp.mux.Unlock()
+	for connector, tx := range cp.transactions {
+		if tx.id == transactionID {
+			return connector, true
+		}
+	}
+	return 0, false
+}
+
+// remoteStart plugs in and charges like a driver would, sampling the meter
+// until the transaction is stopped.
+func (cp *ChargePoint) remoteStart(ctx context.Context, connector int, tag string) {
+	cp.StatusNotification(ctx, connector, "Preparing")
+	if _, err := cp.StartTransaction(ctx, connector, tag); err != nil {
+		cp.log.Warn("Remote start failed", zap.Int("connector", connector), zap.Error(err))
+		cp.StatusNotification(ctx, connector, "Available")
+		return
+	}
+	cp.StatusNotification(ctx, connector, "Charging")
+	if cp.opts.MeterInterval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	cp.mux.Lock()
+	tx, ok := cp.transactions[connector]
+	if ok {
+		tx.stop = stop
+	}
+	cp.mux.Unlock()
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(cp.opts.MeterInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := cp.MeterValues(ctx, connector, cp.opts.MeterEnergy); err != nil {
+				cp.log.Warn("MeterValues failed", zap.Error(err))
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (cp *ChargePoint) remoteStop(ctx context.Context, connector int) {
+	cp.StatusNotification(ctx, connector, "Finishing")
+	if err := cp.StopTransaction(ctx, connector, "Remote"); err != nil {
+		cp.log.Warn("Remote stop failed", zap.Int("connector", connector), zap.Error(err))
+	}
+	cp.StatusNotification(ctx, connector, "Available")
+}
@@ -0,0 +1,127 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JscorpTech/ocpp/internal/ocpp"
+	"go.uber.org/zap"
+)
+
+// centralSystem answers the Core CALLs and records them in order.
+type centralSystem struct {
+	mux     sync.Mutex
+	actions []string
+	conns   chan *ocpp.Conn
+	calls   chan string
+}
+
+func newCentralSystem(t *testing.T) (*centralSystem, string) {
+	c := &centralSystem{conns: make(chan *ocpp.Conn, 1), calls: make(chan string, 100)}
+	csys := ocpp.NewCentralSystem(zap.NewNop(), c.handle)
+	csys.SetConnectionListener(func(conn *ocpp.Conn) { c.conns <- conn })
+	server := httptest.NewServer(csys)
+	t.Cleanup(server.Close)
+	return c, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func (c *centralSystem) handle(ctx context.Context, conn *ocpp.Conn, action string, payload json.RawMessage) (any, error) {
+	c.mux.Lock()
+	c.actions = append(c.actions, action)
+	c.mux.Unlock()
+	c.calls <- action
+	switch action {
+	case "BootNotification":
+		return map[string]any{"status": "Accepted", "currentTime": time.Now().UTC(), "interval": 300}, nil
+	case "Authorize":
+		return map[string]any{"idTagInfo": map[string]string{"status": "Accepted"}}, nil
+	case "StartTransaction":
+		return map[string]any{"transactionId": 42, "idTagInfo": map[string]string{"status": "Accepted"}}, nil
+	case "Heartbeat":
+		return map[string]any{"currentTime": time.Now().UTC()}, nil
+	case "StatusNotification", "MeterValues", "StopTransaction":
+		return map[string]any{}, nil
+	}
+	return nil, &ocpp.CallErr{Code: ocpp.NotImplemented}
+}
+
+func (c *centralSystem) recorded() []string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return append([]string(nil), c.actions...)
+}
+
+func (c *centralSystem) await(t *testing.T, action string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-c.calls:
+			if got == action {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no %s, got %v", action, c.recorded())
+		}
+	}
+}
+
+func TestChargePoint_RemoteCommands(t *testing.T) {
+	c, url := newCentralSystem(t)
+	ctx := context.Background()
+	cp, err := Dial(ctx, Options{URL: url, ID: "CP-1", MeterInterval: 10 * time.Millisecond, MeterEnergy: 10})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer cp.Close()
+	conn := <-c.conns
+
+	result, err := conn.Call(ctx, "RemoteStartTransaction", map[string]any{"idTag": "TAG", "connectorId": 1})
+	if err != nil || string(result) != `{"status":"Accepted"}` {
+		t.Fatalf("RemoteStartTransaction = %s, %v", result, err)
+	}
+	c.await(t, "StartTransaction")
+	c.await(t, "MeterValues")
+
+	result, err = conn.Call(ctx, "RemoteStopTransaction", map[string]any{"transactionId": 42})
+	if err != nil || string(result) != `{"status":"Accepted"}` {
+		t.Fatalf("RemoteStopTransaction = %s, %v", result, err)
+	}
+	c.await(t, "StopTransaction")
+
+	result, err = conn.Call(ctx, "RemoteStopTransaction", map[string]any{"transactionId": 42})
+	if err != nil || string(result) != `{"status":"Rejected"}` {
+		t.Errorf("second RemoteStopTransaction = %s, %v", result, err)
+	}
+
+	if _, err := conn.Call(ctx, "ChangeConfiguration", map[string]string{"key": "Foo", "value": "bar"}); err != nil {
+		t.Fatalf("ChangeConfiguration error = %v", err)
+	}
+	result, err = conn.Call(ctx, "GetConfiguration", map[string]any{"key": []string{"Foo", "Missing"}})
+	if err != nil || string(result) != `{"configurationKey":[{"key":"Foo","readonly":false,"value":"bar"}],"unknownKey":["Missing"]}` {
+		t.Errorf("GetConfiguration = %s, %v", result, err)
+	}
+
+	_, err = conn.Call(ctx, "UnlockConnector", map[string]int{"connectorId": 1})
+	if callErr, ok := err.(*ocpp.CallErr); !ok || callErr.Code != ocpp.NotImplemented {
+		t.Errorf("UnlockConnector error = %v, want NotImplemented", err)
+	}
+}
+
+func TestChargePoint_CallError(t *testing.T) {
+	_, url := newCentralSystem(t)
+	cp, err := Dial(context.Background(), Options{URL: url, ID: "CP-1"})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer cp.Close()
+	err = cp.Call(context.Background(), "FirmwareStatusNotification", map[string]string{"status": "Idle"}, nil)
+	if callErr, ok := err.(*ocpp.CallErr); !ok || callErr.Code != ocpp.NotImplemented {
+		t.Errorf("Call() error = %v, want NotImplemented", err)
+	}
+}
@@ -0,0 +1,168 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Step is one scripted action of a charge point. Connector defaults to 1.
+type Step struct {
+	// Action is an OCPP action (BootNotification, StatusNotification,
+	// Authorize, StartTransaction, MeterValues, StopTransaction, Heartbeat)
+	// or Wait or Disconnect.
+	Action    string `json:"action"`
+	Connector int    `json:"connector,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// Energy is the Wh added per MeterValues sample.
+	Energy int `json:"energy,omitempty"`
+	// Count is the number of MeterValues samples, Interval apart. Wait
+	// waits for Interval.
+	Count    int      `json:"count,omitempty"`
+	Interval Duration `json:"interval,omitempty"`
+}
+
+// Duration reads "30s" style durations from a scenario file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Scenarios are the built-in scenarios: "charge" runs one full charging
+// session, "idle" boots and waits for remote commands.
+var Scenarios = map[string][]Step{
+	"charge": {
+		{Action: "BootNotification"},
+		{Action: "StatusNotification", Status: "Available"},
+		{Action: "Authorize", Tag: "SIM-TAG"},
+		{Action: "StatusNotification", Status: "Preparing"},
+		{Action: "StartTransaction", Tag: "SIM-TAG"},
+		{Action: "StatusNotification", Status: "Charging"},
+		{Action: "MeterValues", Energy: 500, Count: 5, Interval: Duration(time.Second)},
+		{Action: "StatusNotification", Status: "Finishing"},
+		{Action: "StopTransaction", Reason: "Local"},
+		{Action: "StatusNotification", Status: "Available"},
+		{Action: "Disconnect"},
+	},
+	"idle": {
+		{Action: "BootNotification"},
+		{Action: "StatusNotification", Status: "Available"},
+	},
+}
+
+// LoadScenario returns a built-in scenario by name, or reads a JSON array of
+// steps from a file.
+func LoadScenario(name string) ([]Step, error) {
+	if steps, ok := Scenarios[name]; ok {
+		return steps, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var steps []Step
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	for i, step := range steps {
+		if !knownActions[step.Action] {
+			return nil, fmt.Errorf("%s: step %d: unknown action %q", name, i+1, step.Action)
+		}
+	}
+	return steps, nil
+}
+
+var knownActions = map[string]bool{
+	"BootNotification":   true,
+	"StatusNotification": true,
+	"Authorize":          true,
+	"StartTransaction":   true,
+	"MeterValues":        true,
+	"StopTransaction":    true,
+	"Heartbeat":          true,
+	"Wait":               true,
+	"Disconnect":         true,
+}
+
+// Run plays steps in order and stops at the first that fails.
+func (cp *ChargePoint) Run(ctx context.Context, steps []Step) error {
+	for i, step := range steps {
+		if err := cp.step(ctx, step); err != nil {
+			return fmt.Errorf("step %d %s: %w", i+1, step.Action, err)
+		}
+	}
+	return nil
+}
+
+func (cp *ChargePoint) step(ctx context.Context, step Step) error {
+	connector := max(step.Connector, 1)
+	switch step.Action {
+	case "BootNotification":
+		resp, err := cp.Boot(ctx)
+		if err == nil && resp.Status != "Accepted" {
+			err = fmt.Errorf("boot %s", resp.Status)
+		}
+		return err
+	case "StatusNotification":
+		return cp.StatusNotification(ctx, connector, step.Status)
+	case "Authorize":
+		status, err := cp.Authorize(ctx, step.Tag)
+		if err == nil && status != "Accepted" {
+			err = fmt.Errorf("tag %s", status)
+		}
+		return err
+	case "StartTransaction":
+		_, err := cp.StartTransaction(ctx, connector, step.Tag)
+		return err
+	case "MeterValues":
+		for i := range max(step.Count, 1) {
+			if i > 0 {
+				if err := sleep(ctx, time.Duration(step.Interval)); err != nil {
+					return err
+				}
+			}
+			if err := cp.MeterValues(ctx, connector, step.Energy); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "StopTransaction":
+		return cp.StopTransaction(ctx, connector, step.Reason)
+	case "Heartbeat":
+		return cp.Heartbeat(ctx)
+	case "Wait":
+		return sleep(ctx, time.Duration(step.Interval))
+	case "Disconnect":
+		return cp.Close()
+	}
+	return fmt.Errorf("unknown action %q", step.Action)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
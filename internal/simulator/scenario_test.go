@@ -0,0 +1,56 @@
+package simulator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRun_Charge(t *testing.T) {
+	c, url := newCentralSystem(t)
+	cp, err := Dial(context.Background(), Options{URL: url, ID: "CP-1"})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	steps := slices.Clone(Scenarios["charge"])
+	steps[6].Interval = Duration(time.Millisecond)
+	if err := cp.Run(context.Background(), steps); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	select {
+	case <-cp.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("still connected after Disconnect")
+	}
+	want := []string{
+		"BootNotification", "StatusNotification", "Authorize", "StatusNotification", "StartTransaction", "StatusNotification",
+		"MeterValues", "MeterValues", "MeterValues", "MeterValues", "MeterValues",
+		"StatusNotification", "StopTransaction", "StatusNotification",
+	}
+	if got := c.recorded(); !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %v, want %v", got, want)
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	if steps, err := LoadScenario("idle"); err != nil || len(steps) != 2 {
+		t.Errorf("LoadScenario(idle) = %v, %v", steps, err)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "scenario.json")
+	os.WriteFile(file, []byte(`[{"action":"BootNotification"},{"action":"Wait","interval":"2s"}]`), 0o644)
+	steps, err := LoadScenario(file)
+	if err != nil || len(steps) != 2 || time.Duration(steps[1].Interval) != 2*time.Second {
+		t.Errorf("LoadScenario(file) = %+v, %v", steps, err)
+	}
+
+	os.WriteFile(file, []byte(`[{"action":"Reboot"}]`), 0o644)
+	if _, err := LoadScenario(file); err == nil {
+		t.Error("LoadScenario() should reject an unknown action")
+	}
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentRedis adds a span for every command and pipeline sent by rdb.
+func InstrumentRedis(rdb redis.UniversalClient) {
+	rdb.AddHook(redisHook{})
+}
+
+type redisHook struct{}
+
+func (redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := startRedisSpan(ctx, "redis "+cmd.Name(), semconv.DBOperationName(cmd.Name()))
+		defer span.End()
+		err := next(ctx, cmd)
+		endRedisSpan(span, err)
+		return err
+	}
+}
+
+func (redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := startRedisSpan(ctx, "redis pipeline", attribute.Int("db.redis.num_cmd", len(cmds)))
+		defer span.End()
+		err := next(ctx, cmds)
+		endRedisSpan(span, err)
+		return err
+	}
+}
+
+func startRedisSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, semconv.DBSystemRedis)
+	return Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endRedisSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
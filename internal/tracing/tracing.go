@@ -0,0 +1,89 @@
+// Package tracing sets up OpenTelemetry and holds the span attributes shared
+// by the OCPP layer, the backend client and Redis.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/JscorpTech/ocpp/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/JscorpTech/ocpp"
+
+const (
+	ChargerID = attribute.Key("ocpp.charger_id")
+	Action    = attribute.Key("ocpp.action")
+	MessageID = attribute.Key("ocpp.message_id")
+	Version   = attribute.Key("ocpp.version")
+)
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Setup installs the global tracer provider for cfg.TraceExporter. The
+// returned function flushes pending spans and must be called on exit.
+func Setup(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	closeFile := func() error { return nil }
+	switch cfg.TraceExporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		// Endpoint, headers and TLS come from the standard OTEL_EXPORTER_OTLP_* variables.
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		file, ferr := os.OpenFile(cfg.TraceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if ferr != nil {
+			return nil, ferr
+		}
+		closeFile = file.Close
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("unknown TRACE_EXPORTER %q", cfg.TraceExporter)
+	}
+	if err != nil {
+		closeFile()
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("ocpp"),
+			semconv.ServiceInstanceID(cfg.InstanceID),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if cerr := closeFile(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
+
+// TraceParent returns the W3C traceparent of the span in ctx, or "" when
+// there is none.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
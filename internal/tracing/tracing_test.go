@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JscorpTech/ocpp/internal/config"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestSetup_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	shutdown, err := Setup(context.Background(), &config.Config{TraceExporter: "file", TraceFile: path, InstanceID: "test"})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	_, span := Tracer().Start(context.Background(), "ocpp BootNotification")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.Contains(string(data), "ocpp BootNotification") {
+		t.Errorf("trace file = %s, want the span", data)
+	}
+}
+
+func TestSetup_Unknown(t *testing.T) {
+	if _, err := Setup(context.Background(), &config.Config{TraceExporter: "jaeger"}); err == nil {
+		t.Error("Setup() expected error for an unknown exporter")
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	if got := TraceParent(context.Background()); got != "" {
+		t.Errorf("TraceParent() = %q without a span, want empty", got)
+	}
+	recordSpans(t)
+	ctx, span := Tracer().Start(context.Background(), "test")
+	defer span.End()
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got := TraceParent(ctx); got != want {
+		t.Errorf("TraceParent() = %q, want %q", got, want)
+	}
+}
+
+func TestInstrumentRedis(t *testing.T) {
+	recorder := recordSpans(t)
+	// Nothing listens on port 1, so the command fails.
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+	InstrumentRedis(rdb)
+
+	rdb.Ping(context.Background())
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "redis ping" {
+		t.Fatalf("spans = %v, want one redis ping span", spans)
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("status = %v, want error", spans[0].Status())
+	}
+}